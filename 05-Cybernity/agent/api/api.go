@@ -6,6 +6,8 @@ import (
 
 	"cybernity/internal/config"
 	"cybernity/internal/handler/agent"
+	"cybernity/internal/handler/answer"
+	"cybernity/internal/handler/pin"
 	"cybernity/internal/handler/sd"
 
 	"github.com/gin-gonic/gin"
@@ -36,6 +38,8 @@ func Load(e *gin.Engine, mw ...gin.HandlerFunc) *gin.Engine {
 		svcd := v1.Group("/sd")
 		{
 			svcd.GET("/health", sd.HealthCheck)
+			svcd.GET("/gateways", sd.Gateways)
+			svcd.GET("/llm", sd.LLMClients)
 		}
 		agentRouter := v1.Group("/agent")
 		{
@@ -43,6 +47,23 @@ func Load(e *gin.Engine, mw ...gin.HandlerFunc) *gin.Engine {
 			agentRouter.GET("/list", agent.List)
 			agentRouter.GET("/detail", agent.Detail)
 			agentRouter.PUT("/on_chain", agent.OnChain)
+			agentRouter.POST("/keys", agent.Keys)
+			agentRouter.GET("/public_key", agent.PublicKey)
+			agentRouter.POST("/generate_encrypted", agent.GenerateEncrypted)
+			agentRouter.POST("/knowledge", agent.UpdateKnowledge)
+			agentRouter.GET("/versions", agent.Versions)
+			agentRouter.GET("/persona", agent.Persona)
+			agentRouter.POST("/persona", agent.SavePersona)
+		}
+		answerRouter := v1.Group("/answer")
+		{
+			answerRouter.POST("/verify", answer.Verify)
+			answerRouter.GET("/schema", answer.Schema)
+		}
+		pinRouter := v1.Group("/pin")
+		{
+			pinRouter.GET("/usage", pin.Usage)
+			pinRouter.GET("/list", pin.List)
 		}
 
 	}
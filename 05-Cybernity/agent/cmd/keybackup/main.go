@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"cybernity/internal/config"
+	"cybernity/pkg/core/pg"
+	"cybernity/pkg/services"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// keybackup exports agent keys as Shamir shares for custodians and restores them.
+//
+//	keybackup export -c config.yaml -threshold 2 -custodian alice=<pubkey hex> -custodian bob=<pubkey hex> -custodian carol=<pubkey hex> -out ./backup
+//	keybackup restore -c config.yaml -share ./backup/alice.share.json=./alice.key -share ./backup/bob.share.json=./bob.key
+//
+// Custodian public keys are secp256k1 keys (compressed or uncompressed hex), key files hold the hex private key.
+
+type pairs []string
+
+func (p *pairs) String() string     { return strings.Join(*p, ",") }
+func (p *pairs) Set(v string) error { *p = append(*p, v); return nil }
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalf("usage: keybackup <export|restore> [flags]")
+	}
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	configFile := fs.String("c", "deployment/config.yaml", "config file path")
+	threshold := fs.Int("threshold", 2, "number of shares required to restore")
+	outDir := fs.String("out", "backup", "directory to write share files to")
+	dryRun := fs.Bool("dry-run", false, "reconstruct keys without writing to the database")
+	var custodians, shares pairs
+	fs.Var(&custodians, "custodian", "custodian as name=public key hex, repeatable")
+	fs.Var(&shares, "share", "share file as path=private key file, repeatable")
+	fs.Parse(os.Args[2:])
+
+	if envConfig := os.Getenv("CONFIG_FILE"); envConfig != "" {
+		*configFile = envConfig
+	}
+	if err := config.InitConfig(*configFile); err != nil {
+		log.Fatalf("Failed to initialize config: %v", err)
+	}
+	if err := pg.GetManager().Init(&config.AppConfig.Postgres); err != nil {
+		log.Fatalf("Failed to initialize postgres: %v", err)
+	}
+
+	ctx := context.Background()
+	switch os.Args[1] {
+	case "export":
+		if err := export(ctx, custodians, *threshold, *outDir); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+	case "restore":
+		if err := restore(ctx, shares, *dryRun); err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+	default:
+		log.Fatalf("unknown command %s, expected export or restore", os.Args[1])
+	}
+}
+
+func export(ctx context.Context, custodianFlags []string, threshold int, outDir string) error {
+	var custodians []services.Custodian
+	for _, c := range custodianFlags {
+		name, pubHex, ok := strings.Cut(c, "=")
+		if !ok {
+			return fmt.Errorf("invalid custodian %q, expected name=public key", c)
+		}
+		// The name becomes the share file name, it must not leave the output directory
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || filepath.Base(name) != name {
+			return fmt.Errorf("invalid custodian name %q, it is used as a file name", name)
+		}
+		if slices.ContainsFunc(custodians, func(other services.Custodian) bool { return other.Name == name }) {
+			return fmt.Errorf("duplicate custodian name %q", name)
+		}
+		publicKey, err := parsePublicKey(pubHex)
+		if err != nil {
+			return fmt.Errorf("invalid public key for custodian %s: %w", name, err)
+		}
+		custodians = append(custodians, services.Custodian{Name: name, PublicKey: publicKey})
+	}
+
+	files, err := services.NewBackupService().Export(ctx, custodians, threshold)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outDir, 0o700); err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := json.MarshalIndent(file, "", "  ")
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(outDir, file.Custodian+".share.json")
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return err
+		}
+		log.Printf("Wrote share file for %s to %s", file.Custodian, path)
+	}
+	return nil
+}
+
+func restore(ctx context.Context, shareFlags []string, dryRun bool) error {
+	backupSvc := services.NewBackupService()
+
+	var custodianShares [][]services.BackupShare
+	for _, s := range shareFlags {
+		path, keyPath, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("invalid share %q, expected path=private key file", s)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var file services.BackupShareFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("invalid share file %s: %w", path, err)
+		}
+
+		privateKey, err := crypto.LoadECDSA(keyPath)
+		if err != nil {
+			return fmt.Errorf("invalid private key file %s: %w", keyPath, err)
+		}
+		shares, err := backupSvc.OpenShareFile(&file, privateKey)
+		if err != nil {
+			return err
+		}
+		custodianShares = append(custodianShares, shares)
+	}
+
+	wallets, err := backupSvc.Reconstruct(custodianShares)
+	if err != nil {
+		return err
+	}
+	log.Printf("Reconstructed keys of %d agents", len(wallets))
+	if dryRun {
+		return nil
+	}
+
+	restored, err := backupSvc.Restore(ctx, wallets)
+	if err != nil {
+		return err
+	}
+	log.Printf("Restored %d wallets, %d already present", restored, len(wallets)-restored)
+	return nil
+}
+
+func parsePublicKey(pubHex string) (*ecdsa.PublicKey, error) {
+	pub, err := hex.DecodeString(strings.TrimPrefix(pubHex, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(pub) == 33 {
+		return crypto.DecompressPubkey(pub)
+	}
+	return crypto.UnmarshalPubkey(pub)
+}
@@ -5,12 +5,22 @@ import (
 	"cybernity/api"
 	"cybernity/internal/config"
 	"cybernity/internal/listener"
+	"cybernity/pkg/core/cache"
+	"cybernity/pkg/core/knowledge"
 	"cybernity/pkg/core/llm"
 	"cybernity/pkg/core/logger"
 	"cybernity/pkg/core/pg"
+	"cybernity/pkg/core/prompt"
+	"cybernity/pkg/core/rag"
+	"cybernity/pkg/core/storage"
+	"cybernity/pkg/core/tool"
+	"cybernity/pkg/services"
 	"flag"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"log"
@@ -49,6 +59,27 @@ func main() {
 	if err := pg.GetManager().Init(&config.AppConfig.Postgres); err != nil {
 		log.Fatalf("Failed to initialize postgres: %v", err)
 	}
+	if err := storage.InitWithConfig(&config.AppConfig.Storage, &config.AppConfig.Pinata); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	if err := cache.InitWithConfig(&config.AppConfig.Cache); err != nil {
+		log.Fatalf("Failed to initialize cache: %v", err)
+	}
+	if err := knowledge.InitWithConfig(&config.AppConfig.Knowledge); err != nil {
+		log.Fatalf("Failed to initialize knowledge limits: %v", err)
+	}
+	if err := rag.InitWithConfig(&config.AppConfig.RAG); err != nil {
+		log.Fatalf("Failed to initialize rag: %v", err)
+	}
+	if err := prompt.InitWithConfig(&config.AppConfig.Prompt); err != nil {
+		log.Fatalf("Failed to initialize prompt: %v", err)
+	}
+	if err := tool.InitWithConfig(&config.AppConfig.Tool); err != nil {
+		log.Fatalf("Failed to initialize tools: %v", err)
+	}
+
+	// Tools look agents up on-chain while answering, before the listener would create the service
+	services.NewEthService(config.AppConfig.Eth)
 
 	// 现在可以使用 config.AppConfig 访问配置
 	logger.Infof(context.Background(), "Server Name: %s", config.AppConfig.Name)
@@ -60,8 +91,32 @@ func main() {
 		g,
 	)
 	go listener.EventListener(context.Background(), config.AppConfig.Eth)
+	if config.AppConfig.Storage.Pins.Enabled {
+		go services.NewPinService().RunPolicies(context.Background(), &config.AppConfig.Storage.Pins)
+	}
+
+	// The server context is done on SIGINT or SIGTERM, stopping the background indexing with the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var indexer sync.WaitGroup
+	indexer.Add(1)
+	go func() {
+		defer indexer.Done()
+		services.NewRagService().RunIndexer(ctx, config.AppConfig.RAG.IndexWorkers)
+	}()
 
 	addr := config.AppConfig.Addr // Assuming the address is stored in the Log.Path for demonstration
+	server := &http.Server{Addr: addr, Handler: g}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Errorf(context.Background(), "Failed to shut down the server: %v", err)
+		}
+	}()
 	logger.Infof(context.Background(), "Start to listening the incoming requests on http address: %s", addr)
-	logger.Info(context.Background(), http.ListenAndServe(addr, g).Error())
+	logger.Info(context.Background(), server.ListenAndServe().Error())
+	stop()
+	indexer.Wait()
 }
@@ -1,26 +1,38 @@
 package config
 
 import (
+	"cybernity/pkg/core/cache"
 	"cybernity/pkg/core/eth"
+	"cybernity/pkg/core/knowledge"
 	"cybernity/pkg/core/llm"
 	"cybernity/pkg/core/logger"
 	"cybernity/pkg/core/pg"
 	"cybernity/pkg/core/pinata"
+	"cybernity/pkg/core/prompt"
+	"cybernity/pkg/core/rag"
+	"cybernity/pkg/core/storage"
+	"cybernity/pkg/core/tool"
 	"os"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	Name     string           `yaml:"name"`
-	Addr     string           `yaml:"addr"`
-	RunMode  string           `yaml:"run_mode"`
-	Timezone string           `yaml:"timezone"`
-	Log      logger.Config    `yaml:"log"`
-	LLM      llm.LLMConfig    `yaml:"llm"`
-	Eth      eth.Config       `yaml:"eth"`
-	Postgres pg.ProjectConfig `yaml:"postgres"`
-	Pinata   pinata.Config    `yaml:"pinata"`
+	Name      string           `yaml:"name"`
+	Addr      string           `yaml:"addr"`
+	RunMode   string           `yaml:"run_mode"`
+	Timezone  string           `yaml:"timezone"`
+	Log       logger.Config    `yaml:"log"`
+	LLM       llm.LLMConfig    `yaml:"llm"`
+	Eth       eth.Config       `yaml:"eth"`
+	Postgres  pg.ProjectConfig `yaml:"postgres"`
+	Pinata    pinata.Config    `yaml:"pinata"`
+	Storage   storage.Config   `yaml:"storage"`
+	Cache     cache.Config     `yaml:"cache"`
+	Knowledge knowledge.Config `yaml:"knowledge"`
+	RAG       rag.Config       `yaml:"rag"`
+	Prompt    prompt.Config    `yaml:"prompt"`
+	Tool      tool.Config      `yaml:"tool"`
 }
 
 var AppConfig Config
@@ -2,11 +2,21 @@ package agent
 
 import (
 	"crypto/ecdsa"
+	"cybernity/pkg/core/authz"
+	"cybernity/pkg/core/knowledge"
+	"cybernity/pkg/core/logger"
 	"cybernity/pkg/core/result"
 	"cybernity/pkg/models"
 	"cybernity/pkg/services"
-	"encoding/hex"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 
@@ -23,54 +33,14 @@ func Generate(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("file")
+	// Several "file" fields make a multi-file knowledge base
+	files, err := readUploadedFiles(c)
 	if err != nil {
-		result.UError(c, "file upload failed: "+err.Error())
+		uploadError(c, err)
 		return
 	}
 
-	// Check file size limit (5MB)
-	if file.Size > 5*1024*1024 {
-		result.UError(c, "file size exceeds 5MB limit")
-		return
-	}
-
-	openedFile, err := file.Open()
-	if err != nil {
-		result.UError(c, "failed to open file: "+err.Error())
-		return
-	}
-	defer openedFile.Close()
-
-	fileContent, err := io.ReadAll(openedFile)
-	if err != nil {
-		result.UError(c, "failed to read file: "+err.Error())
-		return
-	}
-
-	// Generate key pair
-	encryptSvc := services.NewEncryptService()
-	privateKey, err := encryptSvc.GenerateKeyPair(2048)
-	if err != nil {
-		result.UError(c, "failed to generate key pair: "+err.Error())
-		return
-	}
-	publicKey := &privateKey.PublicKey
-
-	// Encrypt file content
-	encryptedFileContent, err := encryptSvc.EncryptHybrid(fileContent, publicKey)
-	if err != nil {
-		result.UError(c, "failed to encrypt file: "+err.Error())
-		return
-	}
-
-	// Upload encrypted content to IPFS
-	ipfsService := services.NewIpfsService()
-	cid, err := ipfsService.UploadFileRaw(c.Request.Context(), encryptedFileContent, file.Filename)
-	if err != nil {
-		result.UError(c, "failed to upload to IPFS: "+err.Error())
-		return
-	}
+	scheme := c.DefaultPostForm("encryption_scheme", models.EncryptionSchemeRSAOAEP)
 
 	// Generate Ethereum wallet for the agent
 	ethPrivateKey, err := crypto.GenerateKey()
@@ -86,15 +56,28 @@ func Generate(c *gin.Context) {
 	}
 	agentAddress := crypto.PubkeyToAddress(*publicKeyECDSA).Hex()
 
-	// Save wallet with keys
-	privateKeyBytes := encryptSvc.PrivateKeyToBytes(privateKey)
-	ethPrivateKeyBytes := crypto.FromECDSA(ethPrivateKey)
+	// Generate encryption keys for the selected scheme
+	agentKeys, publicKey, err := services.NewEncryptService().GenerateAgentKeys(scheme, ethPrivateKey)
+	if err != nil {
+		result.UError(c, "failed to generate key pair: "+err.Error())
+		return
+	}
 
-	agentKeys := &models.AgentKeys{
-		EncryptionPrivateKey: string(privateKeyBytes),
-		BlockchainPrivateKey: hex.EncodeToString(ethPrivateKeyBytes),
+	// Encrypt and upload the knowledge to IPFS
+	published, err := services.NewKnowledgeService().Publish(c.Request.Context(), &services.PublishKnowledgeSvcRequest{
+		Scheme:         scheme,
+		PublicKey:      publicKey,
+		Name:           name,
+		CreatorAddress: address,
+		Files:          files,
+	})
+	if err != nil {
+		uploadError(c, err)
+		return
 	}
+	cid := published.CID
 
+	// Save wallet with keys
 	agentKeysJSON, err := agentKeys.ToJSON()
 	if err != nil {
 		result.UError(c, "failed to serialize agent keys: "+err.Error())
@@ -114,29 +97,109 @@ func Generate(c *gin.Context) {
 
 	// Save agent
 	err = services.AgentService.CreateAgent(c.Request.Context(), &services.CreateAgentSvcRequest{
-		Name:           name,
-		Description:    description,
-		CID:            cid,
-		CreatorAddress: address,
-		AgentAddress:   agentAddress,
+		Name:            name,
+		Description:     description,
+		CID:             cid,
+		CreatorAddress:  address,
+		AgentAddress:    agentAddress,
+		KnowledgeFormat: published.Format,
+		KnowledgeFiles:  published.Files,
+		KnowledgeSize:   published.Size,
 	})
 	if err != nil {
 		result.UError(c, "failed to create agent: "+err.Error())
 		return
 	}
+	indexInBackground(c, cid)
 	result.Success(c, GenerateResponse{
-		AgentAddress: agentAddress,
-		CID:          cid,
-		Name:         name,
-		Description:  description,
+		AgentAddress:     agentAddress,
+		CID:              cid,
+		Name:             name,
+		Description:      description,
+		EncryptionScheme: scheme,
+		KnowledgeFormat:  published.Format,
+		Files:            published.Files,
 	})
 }
 
+// readUploadedFiles reads every "file" form field, rejecting documents that are unsafe,
+// over their limits or that the agent couldn't read text from
+func readUploadedFiles(c *gin.Context) ([]knowledge.Document, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, fmt.Errorf("file upload failed: %w", err)
+	}
+	fileHeaders := form.File["file"]
+	if len(fileHeaders) == 0 {
+		return nil, knowledge.CheckLimits(nil)
+	}
+
+	limits := knowledge.GetConfig()
+	var docs []knowledge.Document
+	for _, file := range fileHeaders {
+		// Check the size before reading the file, the limit of its format is checked once it is sniffed
+		if file.Size > limits.UploadSizeLimit() {
+			return nil, knowledge.FileTooLarge(file.Filename, limits.UploadSizeLimit())
+		}
+		fileContent, err := readFormFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		contentType := file.Header.Get("Content-Type")
+		if contentType == "" || contentType == "application/octet-stream" {
+			contentType = http.DetectContentType(fileContent)
+		}
+		docs = append(docs, knowledge.Document{Name: file.Filename, ContentType: contentType, Content: fileContent})
+	}
+	return docs, knowledge.ValidateDocuments(docs)
+}
+
+// uploadError fails with the code of a validation error, so clients can explain why an upload was refused
+func uploadError(c *gin.Context, err error) {
+	var verr *knowledge.ValidationError
+	if errors.As(err, &verr) {
+		result.UErrorData(c, err.Error(), verr)
+		return
+	}
+	result.UError(c, err.Error())
+}
+
+// formSignature reads the creator's signature of a request from the timestamp and signature form fields
+func formSignature(c *gin.Context) authz.Signature {
+	timestamp, _ := strconv.ParseInt(c.PostForm("timestamp"), 10, 64)
+	return authz.Signature{Timestamp: timestamp, Value: c.PostForm("signature")}
+}
+
+// indexInBackground queues the agent's knowledge to be indexed, answering indexes it on demand when the queue is full
+func indexInBackground(c *gin.Context, cid string) {
+	if err := services.NewRagService().IndexInBackground(cid); err != nil {
+		logger.Warnf(c.Request.Context(), "Knowledge of agent %s will be indexed on demand: %v", cid, err)
+	}
+}
+
+func readFormFile(file *multipart.FileHeader) ([]byte, error) {
+	openedFile, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer openedFile.Close()
+
+	content, err := io.ReadAll(openedFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return content, nil
+}
+
 type GenerateResponse struct {
-	AgentAddress string `json:"agent_address"`
-	CID          string `json:"cid"`
-	Name         string `json:"name"`
-	Description  string `json:"description"`
+	AgentAddress     string `json:"agent_address"`
+	CID              string `json:"cid"`
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	EncryptionScheme string `json:"encryption_scheme"`
+	KnowledgeFormat  string `json:"knowledge_format"`
+	Files            int    `json:"files"`
 }
 
 type AgentResponse struct {
@@ -178,19 +241,22 @@ func OnChain(c *gin.Context) {
 }
 
 type DetailResponse struct {
-	ID             uint               `json:"id"`
-	Name           string             `json:"name"`
-	Description    string             `json:"description"`
-	CID            string             `json:"cid"`
-	CreatorAddress string             `json:"creator_address"`
-	AgentAddress   string             `json:"agent_address"`
-	Questions      []QuestionResponse `json:"questions"`
+	ID               uint               `json:"id"`
+	Name             string             `json:"name"`
+	Description      string             `json:"description"`
+	CID              string             `json:"cid"`
+	CreatorAddress   string             `json:"creator_address"`
+	AgentAddress     string             `json:"agent_address"`
+	KnowledgeCID     string             `json:"knowledge_cid"`
+	KnowledgeVersion int                `json:"knowledge_version"`
+	Questions        []QuestionResponse `json:"questions"`
 }
 type QuestionResponse struct {
 	ID              uint   `json:"id"`
 	Question        string `json:"question"`
 	Answer          string `json:"answer"`
 	AnswerCID       string `json:"answer_cid"`
+	AnswerEncrypted bool   `json:"answer_encrypted"`
 	TransactionHash string `json:"transaction_hash"`
 }
 
@@ -213,16 +279,249 @@ func Detail(c *gin.Context) {
 			Question:        question.Question,
 			Answer:          question.Answer,
 			AnswerCID:       question.AnswerCID,
+			AnswerEncrypted: question.AnswerEncrypted,
 			TransactionHash: question.TransactionHash,
 		}
 	}
 	result.Success(c, DetailResponse{
-		ID:             agent.ID,
-		Name:           agent.Name,
-		Description:    agent.Description,
-		CID:            agent.CID,
-		CreatorAddress: agent.CreatorAddress,
-		AgentAddress:   agent.AgentAddress,
-		Questions:      questionResponses,
+		ID:               agent.ID,
+		Name:             agent.Name,
+		Description:      agent.Description,
+		CID:              agent.CID,
+		CreatorAddress:   agent.CreatorAddress,
+		AgentAddress:     agent.AgentAddress,
+		KnowledgeCID:     agent.ContentCID(),
+		KnowledgeVersion: agent.CurrentVersion(),
+		Questions:        questionResponses,
+	})
+}
+
+type PublicKeyResponse struct {
+	AgentAddress     string `json:"agent_address"`
+	EncryptionScheme string `json:"encryption_scheme"`
+	PublicKey        string `json:"public_key"` // base64 of the serialized public key
+}
+
+// Keys creates an agent wallet without knowledge and publishes its public key,
+// so the creator can encrypt the knowledge in the browser before calling GenerateEncrypted.
+// The creator signs the reservation, see authz.ActionReserveKeys.
+func Keys(c *gin.Context) {
+	address := c.PostForm("creator_address")
+	scheme := c.DefaultPostForm("encryption_scheme", models.EncryptionSchemeECIESX25519)
+
+	if address == "" {
+		result.UError(c, "creator_address is required")
+		return
+	}
+	auth := &authz.Request{
+		Action:    authz.ActionReserveKeys,
+		Subject:   address,
+		Fields:    []authz.Field{{Name: "encryption_scheme", Value: scheme}},
+		Signature: formSignature(c),
+	}
+	if err := auth.Verify(address, time.Now()); err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+
+	ethPrivateKey, err := crypto.GenerateKey()
+	if err != nil {
+		result.UError(c, "failed to generate ethereum key: "+err.Error())
+		return
+	}
+	agentAddress := crypto.PubkeyToAddress(ethPrivateKey.PublicKey).Hex()
+
+	agentKeys, publicKey, err := services.NewEncryptService().GenerateAgentKeys(scheme, ethPrivateKey)
+	if err != nil {
+		result.UError(c, "failed to generate key pair: "+err.Error())
+		return
+	}
+
+	agentKeysJSON, err := agentKeys.ToJSON()
+	if err != nil {
+		result.UError(c, "failed to serialize agent keys: "+err.Error())
+		return
+	}
+
+	// The CID is filled in once the encrypted knowledge is uploaded
+	err = services.AgentService.CreateWallet(c.Request.Context(), &services.CreateWalletSvcRequest{
+		CreatorAddress:  address,
+		AgentPrivateKey: agentKeysJSON,
+		AgentAddress:    agentAddress,
+	})
+	if err != nil {
+		result.UError(c, "failed to create wallet: "+err.Error())
+		return
+	}
+
+	result.Success(c, PublicKeyResponse{
+		AgentAddress:     agentAddress,
+		EncryptionScheme: scheme,
+		PublicKey:        base64.StdEncoding.EncodeToString(publicKey),
+	})
+}
+
+// PublicKey returns the public key knowledge for an agent is encrypted to, looked up by agent address or CID
+func PublicKey(c *gin.Context) {
+	agentAddress := c.Query("agent_address")
+	if agentAddress == "" {
+		cid := c.Query("cid")
+		if cid == "" {
+			result.UError(c, "agent_address or cid is required")
+			return
+		}
+		agent, err := services.AgentService.GetAgent(c.Request.Context(), cid)
+		if err != nil {
+			result.UError(c, err.Error())
+			return
+		}
+		agentAddress = agent.AgentAddress
+	}
+
+	scheme, publicKey, err := services.NewWalletService().GetPublicKeyForAgent(c.Request.Context(), agentAddress)
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+
+	result.Success(c, PublicKeyResponse{
+		AgentAddress:     agentAddress,
+		EncryptionScheme: scheme,
+		PublicKey:        base64.StdEncoding.EncodeToString(publicKey),
+	})
+}
+
+// GenerateEncrypted creates an agent from knowledge the creator already encrypted.
+// Without wrapped_key the file must be encrypted to the agent's public key;
+// with wrapped_key the file is AES-256-GCM (nonce + ciphertext) under the creator's content key,
+// and wrapped_key is that content key encrypted to the agent's public key.
+// The creator who reserved the keys signs the upload, see authz.ActionCreateAgent.
+func GenerateEncrypted(c *gin.Context) {
+	name := c.PostForm("name")
+	description := c.PostForm("description")
+	address := c.PostForm("creator_address")
+	agentAddress := c.PostForm("agent_address")
+	wrappedKey := c.PostForm("wrapped_key")
+
+	if name == "" || address == "" || agentAddress == "" {
+		result.UError(c, "name, creator_address and agent_address are required")
+		return
+	}
+
+	wallet, err := services.NewWalletService().GetWalletForAgent(c.Request.Context(), agentAddress)
+	if err != nil {
+		result.UError(c, "agent keys not found: "+err.Error())
+		return
+	}
+	if !strings.EqualFold(wallet.CreatorAddress, address) {
+		result.UError(c, "agent keys belong to another creator")
+		return
+	}
+	if wallet.CID != "" {
+		result.UError(c, models.ErrWalletUsed.Error())
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		result.UError(c, "file upload failed: "+err.Error())
+		return
+	}
+
+	// The content can't be sniffed, so only the limit of the largest format applies
+	if limit := knowledge.GetConfig().UploadSizeLimit(); file.Size > limit {
+		uploadError(c, knowledge.FileTooLarge(file.Filename, limit))
+		return
+	}
+
+	openedFile, err := file.Open()
+	if err != nil {
+		result.UError(c, "failed to open file: "+err.Error())
+		return
+	}
+	defer openedFile.Close()
+
+	encryptedFileContent, err := io.ReadAll(openedFile)
+	if err != nil {
+		result.UError(c, "failed to read file: "+err.Error())
+		return
+	}
+
+	// The creator address is public, only the creator's signature binds the reserved keys to this knowledge
+	auth := &authz.Request{
+		Action:    authz.ActionCreateAgent,
+		Subject:   agentAddress,
+		Fields:    []authz.Field{{Name: "file", Value: authz.Hash(encryptedFileContent)}},
+		Signature: formSignature(c),
+	}
+	if wrappedKey != "" {
+		auth.Fields = append(auth.Fields, authz.Field{Name: "wrapped_key", Value: authz.Hash([]byte(wrappedKey))})
+	}
+	if err := auth.Verify(wallet.CreatorAddress, time.Now()); err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+
+	mode := models.EncryptionModeClient
+	if wrappedKey != "" {
+		mode = models.EncryptionModeWrappedKey
+	}
+	agent := &models.Agents{
+		AgentAddress:   agentAddress,
+		EncryptionMode: mode,
+		WrappedKey:     wrappedKey,
+	}
+
+	// Reject uploads the agent could never decrypt or read text from
+	plaintext, err := services.AgentService.DecryptKnowledge(c.Request.Context(), agent, encryptedFileContent)
+	if err != nil {
+		result.UError(c, "encrypted file cannot be decrypted by the agent: "+err.Error())
+		return
+	}
+	if err := knowledge.ValidateDocuments([]knowledge.Document{{Name: file.Filename, Content: plaintext}}); err != nil {
+		uploadError(c, err)
+		return
+	}
+
+	cid, err := services.NewPinService().Pin(c.Request.Context(), &services.PinSvcRequest{
+		Content:        encryptedFileContent,
+		Name:           file.Filename,
+		Purpose:        models.PinPurposeKnowledge,
+		CreatorAddress: address,
+	})
+	if err != nil {
+		result.UError(c, "failed to upload to IPFS: "+err.Error())
+		return
+	}
+
+	err = services.AgentService.CreateAgent(c.Request.Context(), &services.CreateAgentSvcRequest{
+		Name:           name,
+		Description:    description,
+		CID:            cid,
+		CreatorAddress: address,
+		AgentAddress:   agentAddress,
+		EncryptionMode: mode,
+		WrappedKey:     wrappedKey,
+		KnowledgeFiles: 1,
+		KnowledgeSize:  int64(len(encryptedFileContent)),
+		ReservedWallet: true,
+	})
+	if err != nil {
+		result.UError(c, "failed to create agent: "+err.Error())
+		return
+	}
+
+	scheme, _, err := services.NewWalletService().GetPublicKeyForAgent(c.Request.Context(), agentAddress)
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+	indexInBackground(c, cid)
+	result.Success(c, GenerateResponse{
+		AgentAddress:     agentAddress,
+		CID:              cid,
+		Name:             name,
+		Description:      description,
+		EncryptionScheme: scheme,
 	})
 }
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"cybernity/pkg/core/result"
+	"cybernity/pkg/models"
+	"cybernity/pkg/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KnowledgeVersionResponse struct {
+	Version         int    `json:"version"`
+	CID             string `json:"cid"`
+	KnowledgeFormat string `json:"knowledge_format"`
+	Files           int    `json:"files"`
+	Size            int64  `json:"size"`
+	Change          string `json:"change"`
+	CreatedAt       int64  `json:"created_at"`
+}
+
+func newKnowledgeVersionResponse(v *models.KnowledgeVersions) KnowledgeVersionResponse {
+	return KnowledgeVersionResponse{
+		Version:         v.Version,
+		CID:             v.CID,
+		KnowledgeFormat: v.KnowledgeFormat,
+		Files:           v.Files,
+		Size:            v.Size,
+		Change:          v.Change,
+		CreatedAt:       v.CreatedAt.Unix(),
+	}
+}
+
+// UpdateKnowledge publishes a new knowledge version for an existing agent.
+// change=append (default) adds the uploaded files to the current documents, change=replace publishes only them.
+// The agent's creator signs the change against base_version, see authz.ActionUpdateKnowledge.
+func UpdateKnowledge(c *gin.Context) {
+	cid := c.PostForm("cid")
+	change := c.DefaultPostForm("change", models.KnowledgeChangeAppend)
+	baseVersion, err := strconv.Atoi(c.PostForm("base_version"))
+
+	if cid == "" || err != nil {
+		result.UError(c, "cid and base_version are required")
+		return
+	}
+
+	files, err := readUploadedFiles(c)
+	if err != nil {
+		uploadError(c, err)
+		return
+	}
+
+	version, err := services.NewKnowledgeService().Update(c.Request.Context(), &services.UpdateKnowledgeSvcRequest{
+		AgentCID:    cid,
+		BaseVersion: baseVersion,
+		Change:      change,
+		Files:       files,
+		Signature:   formSignature(c),
+	})
+	if err != nil {
+		uploadError(c, err)
+		return
+	}
+	indexInBackground(c, cid)
+	result.Success(c, newKnowledgeVersionResponse(version))
+}
+
+// Versions lists the knowledge versions of an agent, latest first.
+// With version set only that version is returned.
+func Versions(c *gin.Context) {
+	cid := c.Query("cid")
+	if cid == "" {
+		result.UError(c, "cid is required")
+		return
+	}
+
+	if v := c.Query("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			result.UError(c, "invalid version")
+			return
+		}
+		version, err := services.NewKnowledgeService().GetVersion(c.Request.Context(), cid, n)
+		if err != nil {
+			result.UError(c, err.Error())
+			return
+		}
+		result.Success(c, newKnowledgeVersionResponse(version))
+		return
+	}
+
+	versions, err := services.NewKnowledgeService().ListVersions(c.Request.Context(), cid)
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+	resp := make([]KnowledgeVersionResponse, len(versions))
+	for i, v := range versions {
+		resp[i] = newKnowledgeVersionResponse(v)
+	}
+	result.Success(c, resp)
+}
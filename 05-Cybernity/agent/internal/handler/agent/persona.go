@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"cybernity/pkg/core/authz"
+	"cybernity/pkg/core/prompt"
+	"cybernity/pkg/core/result"
+	"cybernity/pkg/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PersonaRequest struct {
+	CID string `json:"cid"`
+	prompt.Persona
+	// Signature is the creator's, of the persona as sent
+	authz.Signature
+}
+
+type PersonaResponse struct {
+	CID             string `json:"cid"`
+	Configured      bool   `json:"configured"` // false when the agent uses the default persona
+	DefaultTemplate string `json:"default_template"`
+	AnswerSchema    string `json:"answer_schema"` // an example response schema
+	prompt.Persona
+}
+
+// Persona returns the prompt template and answering style of an agent
+func Persona(c *gin.Context) {
+	cid := c.Query("cid")
+	if cid == "" {
+		result.UError(c, "cid is required")
+		return
+	}
+
+	persona, configured, err := services.NewPersonaService().Get(c.Request.Context(), cid)
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+	result.Success(c, PersonaResponse{
+		CID:             cid,
+		Configured:      configured,
+		DefaultTemplate: prompt.DefaultTemplate,
+		AnswerSchema:    prompt.AnswerSchema,
+		Persona:         *persona,
+	})
+}
+
+// SavePersona configures the prompt template and answering style of an agent.
+// The template can use {{.Name}}, {{.Description}}, {{.Knowledge}} and {{.Question}}, and must include the knowledge.
+// The persona must be signed by the agent's creator.
+func SavePersona(c *gin.Context) {
+	var req PersonaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		result.UError(c, "invalid request: "+err.Error())
+		return
+	}
+	if req.CID == "" {
+		result.UError(c, "cid is required")
+		return
+	}
+
+	err := services.NewPersonaService().Save(c.Request.Context(), &services.SavePersonaSvcRequest{
+		AgentCID:  req.CID,
+		Persona:   req.Persona,
+		Signature: req.Signature,
+	})
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+	result.Success(c, nil)
+}
@@ -0,0 +1,58 @@
+package answer
+
+import (
+	"cybernity/pkg/core/answerdoc"
+	"cybernity/pkg/core/result"
+	"cybernity/pkg/services"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VerifyRequest struct {
+	AnswerCID string          `json:"answer_cid"`
+	Document  json.RawMessage `json:"document"`
+}
+
+// Verify validates an answer document and checks its attestation against the agent's on-chain operator.
+// The document is either posted directly or fetched from IPFS by answer_cid.
+func Verify(c *gin.Context) {
+	var req VerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		result.UError(c, "invalid request: "+err.Error())
+		return
+	}
+
+	content := []byte(req.Document)
+	if len(content) == 0 {
+		if req.AnswerCID == "" {
+			result.UError(c, "answer_cid or document is required")
+			return
+		}
+		downloaded, err := services.NewIpfsService().DownloadFile(c.Request.Context(), req.AnswerCID)
+		if err != nil {
+			result.UError(c, "failed to download answer: "+err.Error())
+			return
+		}
+		content = []byte(downloaded)
+	}
+
+	doc, err := answerdoc.Parse(content)
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+
+	verification, err := services.NewAttestationService().Verify(c.Request.Context(), doc)
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+	result.Success(c, verification)
+}
+
+// Schema serves the JSON Schema of answer documents
+func Schema(c *gin.Context) {
+	c.Data(http.StatusOK, "application/schema+json", answerdoc.JSONSchema)
+}
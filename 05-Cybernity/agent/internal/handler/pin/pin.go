@@ -0,0 +1,54 @@
+package pin
+
+import (
+	"cybernity/pkg/core/result"
+	"cybernity/pkg/models"
+	"cybernity/pkg/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UsageResponse struct {
+	CreatorAddress string             `json:"creator_address"`
+	Count          int64              `json:"count"`
+	Size           int64              `json:"size"`
+	Purposes       []*models.PinUsage `json:"purposes"`
+}
+
+// Usage returns how much content is pinned on behalf of a creator
+func Usage(c *gin.Context) {
+	address := c.Query("creator_address")
+	if address == "" {
+		result.UError(c, "creator_address is required")
+		return
+	}
+
+	usage, err := services.NewPinService().Usage(c.Request.Context(), address)
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+
+	resp := UsageResponse{CreatorAddress: address, Purposes: usage}
+	for _, u := range usage {
+		resp.Count += u.Count
+		resp.Size += u.Size
+	}
+	result.Success(c, resp)
+}
+
+// List returns the content still pinned on behalf of a creator
+func List(c *gin.Context) {
+	address := c.Query("creator_address")
+	if address == "" {
+		result.UError(c, "creator_address is required")
+		return
+	}
+
+	pins, err := services.NewPinService().ListPins(c.Request.Context(), address)
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+	result.Success(c, pins)
+}
@@ -1,7 +1,9 @@
 package sd
 
 import (
+	"cybernity/pkg/core/llm"
 	"cybernity/pkg/core/result"
+	"cybernity/pkg/core/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -9,3 +11,45 @@ import (
 func HealthCheck(c *gin.Context) {
 	result.Success(c, "ok")
 }
+
+type GatewaysResponse struct {
+	Gateways []storage.GatewayStat  `json:"gateways"`
+	Recent   []storage.GatewayFetch `json:"recent"`
+}
+
+// Gateways reports IPFS gateway health and which gateway served recent CIDs
+func Gateways(c *gin.Context) {
+	backend, err := storage.GetBackend()
+	if err != nil {
+		result.UError(c, err.Error())
+		return
+	}
+	gatewayBackend, ok := backend.(*storage.GatewayBackend)
+	if !ok {
+		result.Success(c, GatewaysResponse{})
+		return
+	}
+	result.Success(c, GatewaysResponse{
+		Gateways: gatewayBackend.Fetcher().Stats(),
+		Recent:   gatewayBackend.Fetcher().RecentFetches(),
+	})
+}
+
+type LLMClientsResponse struct {
+	Clients []llm.ClientStat  `json:"clients"`
+	Local   []llm.LocalStatus `json:"local"`
+}
+
+// LLMClients reports the health and circuit state of the LLM clients,
+// along with the health and models of self-hosted inference servers
+func LLMClients(c *gin.Context) {
+	router := llm.GetRouter()
+	if router == nil {
+		result.Success(c, LLMClientsResponse{})
+		return
+	}
+	result.Success(c, LLMClientsResponse{
+		Clients: router.Stats(),
+		Local:   llm.GetManager().CheckLocal(c.Request.Context()),
+	})
+}
@@ -2,8 +2,10 @@ package listener
 
 import (
 	"context"
+	"cybernity/pkg/core/answerdoc"
 	"cybernity/pkg/core/eth"
 	"cybernity/pkg/services"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -89,56 +91,104 @@ func EventListener(ctx context.Context, ethConfig eth.Config) {
 				fmt.Printf("Question Content: %s\n", questionAskedEvent.QuestionContent)
 				fmt.Println("-------------------------------------------------")
 
-				// get file from ipfs
-				ipfsService := services.NewIpfsService()
-
 				agent, err := services.AgentService.GetAgent(ctx, questionAskedEvent.Cid)
 				if err != nil {
 					log.Printf("Failed to get agent: %v", err)
 					continue
 				}
-				knowledge, err := ipfsService.DownloadFile(ctx, agent.CID)
+
+				walletService := services.NewWalletService()
+
+				// Only the parts of the knowledge relevant to the question go into the prompt
+				chunks, err := services.NewRagService().Retrieve(ctx, agent, questionAskedEvent.QuestionContent)
 				if err != nil {
-					log.Printf("Failed to download file from IPFS: %v", err)
+					log.Printf("Failed to retrieve knowledge: %v", err)
 					continue
 				}
 
-				encryptSvc := services.NewEncryptService()
-				walletService := services.NewWalletService()
+				persona, _, err := services.NewPersonaService().Get(ctx, agent.CID)
+				if err != nil {
+					log.Printf("Failed to get persona: %v", err)
+					continue
+				}
 
-				privateKey, err := walletService.GetPrivateKeyForAgent(ctx, agent.AgentAddress)
+				answer, err := services.LLMService.GetAnswer(ctx, &services.GetAnswerSvcRequest{
+					Name:        agent.Name,
+					Description: agent.Description,
+					Question:    questionAskedEvent.QuestionContent,
+					Chunks:      chunks,
+					Persona:     persona,
+					Tools:       services.NewToolService().ForAgent(agent, persona.Tools),
+				})
 				if err != nil {
-					log.Printf("Failed to get private key: %v", err)
+					log.Printf("Failed to get answer from LLM: %v", err)
 					continue
 				}
+				// The answer itself isn't printed, it may only be for the questioner
+				fmt.Printf("Answer: %d bytes by %s\n", len(answer.Content), answer.Model)
+				for _, step := range answer.Trace {
+					log.Printf("Tool call %d %s(%s) took %v, error: %q", step.Iteration, step.Tool, step.Arguments, step.Duration, step.Error)
+				}
 
-				decryptedKnowledge, err := encryptSvc.DecryptHybrid([]byte(knowledge), privateKey)
+				ethSvc := services.NewEthService(ethConfig)
+
+				agentBlockchainKey, err := walletService.GetBlockchainPrivateKeyForAgent(ctx, agent.AgentAddress)
 				if err != nil {
-					log.Printf("Failed to decrypt knowledge: %v", err)
+					log.Printf("Failed to get agent blockchain private key: %v", err)
 					continue
 				}
 
-				answer, err := services.LLMService.GetAnswer(ctx, agent.Name, agent.Description, questionAskedEvent.QuestionContent, string(decryptedKnowledge))
+				attestReq := &services.AttestSvcRequest{
+					AgentBlockchainKey: agentBlockchainKey,
+					QuestionID:         questionId,
+					AgentCID:           agent.CID,
+					KnowledgeCID:       agent.ContentCID(),
+					Question:           questionAskedEvent.QuestionContent,
+					Answer:             []byte(answer.Content),
+					Model:              answer.Model,
+					Usage:              answer.Usage,
+				}
+				// Sources only, excerpts would publish the paid knowledge
+				for _, c := range chunks {
+					attestReq.Citations = append(attestReq.Citations, answerdoc.Citation{Source: c.Source()})
+				}
+				storedAnswer := answer.Content
+				// Only the questioner can read an encrypted answer, so it is neither stored nor pinned in plaintext
+				if ethConfig.EncryptAnswers {
+					attestReq.Ciphertext, err = services.NewAnswerService().EncryptForQuestioner(ctx, ethSvc, vLog.TxHash, questioner, attestReq.Answer)
+					if err != nil {
+						log.Printf("Failed to encrypt answer: %v", err)
+						continue
+					}
+					storedAnswer = ""
+				}
+
+				// Publish the answer as a document attested by the agent's operator key
+				answerDoc, err := services.NewAttestationService().Attest(ctx, attestReq)
 				if err != nil {
-					log.Printf("Failed to get answer from LLM: %v", err)
+					log.Printf("Failed to attest answer: %v", err)
 					continue
 				}
-				fmt.Printf("Answer: %s\n", answer)
-				answerCID, err := ipfsService.UploadFileRaw(ctx, []byte(answer), agent.CID+"_answer.txt")
+				answerJSON, err := json.Marshal(answerDoc)
 				if err != nil {
-					log.Printf("Failed to upload file to IPFS: %v", err)
+					log.Printf("Failed to marshal answer document: %v", err)
 					continue
 				}
-				fmt.Printf("Answer CID: %s\n", answerCID)
 
-				// upload answer to contract
-				agentBlockchainKey, err := walletService.GetBlockchainPrivateKeyForAgent(ctx, agent.AgentAddress)
+				answerCID, err := services.NewPinService().Pin(ctx, &services.PinSvcRequest{
+					Content:        answerJSON,
+					Name:           agent.CID + "_answer.json",
+					Purpose:        models.PinPurposeAnswer,
+					AgentCID:       agent.CID,
+					CreatorAddress: agent.CreatorAddress,
+				})
 				if err != nil {
-					log.Printf("Failed to get agent blockchain private key: %v", err)
+					log.Printf("Failed to upload file to IPFS: %v", err)
 					continue
 				}
+				fmt.Printf("Answer CID: %s\n", answerCID)
 
-				ethSvc := services.NewEthService(ethConfig)
+				// upload answer to contract
 				txHash, err := ethSvc.SubmitAnswer(ctx, agentBlockchainKey, questionId, answerCID)
 				if err != nil {
 					log.Printf("Failed to submit answer to contract: %v", err)
@@ -156,7 +206,8 @@ func EventListener(ctx context.Context, ethConfig eth.Config) {
 					AgentAddress:    agent.AgentAddress,
 					TransactionHash: txHash.Hex(),
 					Question:        questionAskedEvent.QuestionContent,
-					Answer:          answer,
+					Answer:          storedAnswer,
+					AnswerEncrypted: ethConfig.EncryptAnswers,
 				}
 
 				if err := questionRecord.Create(ctx); err != nil {
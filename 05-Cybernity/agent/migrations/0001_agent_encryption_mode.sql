@@ -0,0 +1,3 @@
+-- Agents whose knowledge the creator encrypted in the browser
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS encryption_mode TEXT NOT NULL DEFAULT '';
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS wrapped_key TEXT NOT NULL DEFAULT '';
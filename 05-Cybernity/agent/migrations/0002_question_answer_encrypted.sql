@@ -0,0 +1,2 @@
+-- Answers on IPFS encrypted to the questioner
+ALTER TABLE questions ADD COLUMN IF NOT EXISTS answer_encrypted BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,20 @@
+-- Every CID we pinned, so it can be accounted for and unpinned by the pin policies
+CREATE TABLE IF NOT EXISTS pins (
+    id              BIGSERIAL PRIMARY KEY,
+    cid             TEXT NOT NULL,
+    name            TEXT NOT NULL DEFAULT '',
+    purpose         TEXT NOT NULL DEFAULT '',
+    agent_cid       TEXT NOT NULL DEFAULT '',
+    creator_address TEXT NOT NULL DEFAULT '',
+    size            BIGINT NOT NULL DEFAULT 0,
+    backend         TEXT NOT NULL DEFAULT '',
+    superseded_at   TIMESTAMPTZ,
+    unpinned_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ,
+    deleted_at      TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_pins_cid ON pins (cid);
+CREATE INDEX IF NOT EXISTS idx_pins_creator_address ON pins (lower(creator_address)) WHERE unpinned_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_pins_agent_cid ON pins (agent_cid);
+CREATE INDEX IF NOT EXISTS idx_pins_deleted_at ON pins (deleted_at);
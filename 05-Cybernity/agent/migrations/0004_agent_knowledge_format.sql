@@ -0,0 +1,2 @@
+-- Knowledge published as an IPFS directory with a manifest
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS knowledge_format TEXT NOT NULL DEFAULT '';
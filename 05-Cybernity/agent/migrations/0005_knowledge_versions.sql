@@ -0,0 +1,20 @@
+-- Latest knowledge of an agent, its CID stays the agent's on-chain identity
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS knowledge_cid TEXT NOT NULL DEFAULT '';
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS knowledge_version INTEGER NOT NULL DEFAULT 0;
+
+-- History of the knowledge published for each agent
+CREATE TABLE IF NOT EXISTS knowledge_versions (
+    id               BIGSERIAL PRIMARY KEY,
+    agent_cid        TEXT NOT NULL,
+    version          INTEGER NOT NULL,
+    cid              TEXT NOT NULL,
+    knowledge_format TEXT NOT NULL DEFAULT '',
+    files            INTEGER NOT NULL DEFAULT 0,
+    size             BIGINT NOT NULL DEFAULT 0,
+    change           TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ,
+    deleted_at       TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_versions_agent_version ON knowledge_versions (agent_cid, version) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_knowledge_versions_deleted_at ON knowledge_versions (deleted_at);
@@ -0,0 +1,14 @@
+-- Retrieval indexes of knowledge CIDs, sealed to the agent since their chunks are the plaintext knowledge
+CREATE TABLE IF NOT EXISTS knowledge_indexes (
+    id            BIGSERIAL PRIMARY KEY,
+    agent_cid     TEXT NOT NULL,
+    knowledge_cid TEXT NOT NULL,
+    embedder      TEXT NOT NULL,
+    chunks        INTEGER NOT NULL DEFAULT 0,
+    sealed        BYTEA NOT NULL,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ,
+    deleted_at    TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_indexes_knowledge_embedder ON knowledge_indexes (knowledge_cid, embedder);
+CREATE INDEX IF NOT EXISTS idx_knowledge_indexes_deleted_at ON knowledge_indexes (deleted_at);
@@ -0,0 +1,16 @@
+-- Prompt template and answering style a creator configured for an agent
+CREATE TABLE IF NOT EXISTS agent_personas (
+    id             BIGSERIAL PRIMARY KEY,
+    agent_cid      TEXT NOT NULL,
+    template       TEXT NOT NULL DEFAULT '',
+    language       TEXT NOT NULL DEFAULT '',
+    tone           TEXT NOT NULL DEFAULT '',
+    refusal_policy TEXT NOT NULL DEFAULT '',
+    temperature    REAL,
+    model          TEXT NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ,
+    updated_at     TIMESTAMPTZ,
+    deleted_at     TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_personas_agent_cid ON agent_personas (agent_cid);
+CREATE INDEX IF NOT EXISTS idx_agent_personas_deleted_at ON agent_personas (deleted_at);
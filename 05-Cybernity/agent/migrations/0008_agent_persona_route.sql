@@ -0,0 +1,2 @@
+-- LLM route an agent is pinned to, empty for the shared one
+ALTER TABLE agent_personas ADD COLUMN IF NOT EXISTS route TEXT NOT NULL DEFAULT '';
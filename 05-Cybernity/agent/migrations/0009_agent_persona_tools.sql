@@ -0,0 +1,2 @@
+-- Built-in tools an agent may call, comma separated
+ALTER TABLE agent_personas ADD COLUMN IF NOT EXISTS tools TEXT NOT NULL DEFAULT '';
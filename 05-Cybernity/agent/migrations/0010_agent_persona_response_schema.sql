@@ -0,0 +1,2 @@
+-- JSON Schema an agent's answers have to match, empty to answer in text
+ALTER TABLE agent_personas ADD COLUMN IF NOT EXISTS response_schema TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,136 @@
+package answerdoc
+
+import (
+	"cybernity/pkg/core/attestation"
+	"cybernity/pkg/core/storage"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SchemaV1 identifies the first version of the answer document schema.
+// Breaking changes get a new identifier, readers switch on Document.Schema.
+const SchemaV1 = "cybernity.answer.v1"
+
+// JSONSchema is the JSON Schema describing SchemaV1 documents, for third party validators
+//
+//go:embed schema.v1.json
+var JSONSchema []byte
+
+var hashPattern = regexp.MustCompile(`^0x[0-9a-f]{64}$`)
+
+// Document is the JSON answer document the listener uploads to IPFS for each answer
+type Document struct {
+	Schema       string                   `json:"schema"`
+	QuestionID   string                   `json:"question_id"`
+	QuestionHash string                   `json:"question_hash"` // keccak256 of the question text
+	AgentCID     string                   `json:"agent_cid"`
+	KnowledgeCID string                   `json:"knowledge_cid,omitempty"` // knowledge version the answer was drawn from
+	Answer       string                   `json:"answer"`                  // plaintext, or base64 ciphertext when Encrypted
+	Encrypted    bool                     `json:"encrypted"`
+	Model        string                   `json:"model"`
+	Usage        *Usage                   `json:"usage,omitempty"`
+	CreatedAt    time.Time                `json:"created_at"`
+	Citations    []Citation               `json:"citations,omitempty"`
+	Attestation  *attestation.Attestation `json:"attestation,omitempty"`
+}
+
+// Usage is the token usage of the model call that produced the answer
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Citation points at the part of the knowledge an answer relies on
+type Citation struct {
+	Source  string `json:"source"`            // file name or chunk id within the agent's knowledge
+	Excerpt string `json:"excerpt,omitempty"` // omitted for encrypted answers
+}
+
+// HashQuestion returns the hex keccak256 hash of the question text
+func HashQuestion(question string) string {
+	return crypto.Keccak256Hash([]byte(question)).Hex()
+}
+
+// PublishedAnswer returns the answer as published, the ciphertext for encrypted answers
+func (d *Document) PublishedAnswer() ([]byte, error) {
+	if !d.Encrypted {
+		return []byte(d.Answer), nil
+	}
+	return base64.StdEncoding.DecodeString(d.Answer)
+}
+
+// Parse decodes and validates an answer document
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid answer document: %w", err)
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Validate checks the document against the schema it declares
+func (d *Document) Validate() error {
+	if d.Schema != SchemaV1 {
+		return fmt.Errorf("unsupported answer schema %q", d.Schema)
+	}
+
+	var errs []error
+	if id, ok := new(big.Int).SetString(d.QuestionID, 10); !ok || id.Sign() < 0 {
+		errs = append(errs, errors.New("question_id must be a non-negative decimal integer"))
+	}
+	if !hashPattern.MatchString(d.QuestionHash) {
+		errs = append(errs, errors.New("question_hash must be a 0x prefixed keccak256 hash"))
+	}
+	if _, err := storage.ParseCID(d.AgentCID); err != nil {
+		errs = append(errs, fmt.Errorf("agent_cid: %w", err))
+	}
+	if d.KnowledgeCID != "" {
+		if _, err := storage.ParseCID(d.KnowledgeCID); err != nil {
+			errs = append(errs, fmt.Errorf("knowledge_cid: %w", err))
+		}
+	}
+	if d.Answer == "" {
+		errs = append(errs, errors.New("answer is required"))
+	} else if d.Encrypted {
+		if _, err := base64.StdEncoding.DecodeString(d.Answer); err != nil {
+			errs = append(errs, errors.New("encrypted answer must be base64"))
+		}
+	}
+	if d.Model == "" {
+		errs = append(errs, errors.New("model is required"))
+	}
+	if d.CreatedAt.IsZero() {
+		errs = append(errs, errors.New("created_at is required"))
+	}
+	if u := d.Usage; u != nil {
+		if u.PromptTokens < 0 || u.CompletionTokens < 0 || u.TotalTokens < 0 {
+			errs = append(errs, errors.New("usage must not be negative"))
+		}
+	}
+	for i, c := range d.Citations {
+		if c.Source == "" {
+			errs = append(errs, fmt.Errorf("citations[%d].source is required", i))
+		}
+	}
+	if a := d.Attestation; a != nil {
+		if a.QuestionID != d.QuestionID || a.AgentCID != d.AgentCID || a.KnowledgeCID != d.KnowledgeCID || a.Model != d.Model {
+			errs = append(errs, errors.New("attestation does not match the document"))
+		}
+		if answer, err := d.PublishedAnswer(); err == nil && a.AnswerHash != attestation.HashAnswer(answer) {
+			errs = append(errs, errors.New("answer does not match the attested answer hash"))
+		}
+	}
+	return errors.Join(errs...)
+}
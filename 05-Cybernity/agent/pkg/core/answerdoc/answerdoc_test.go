@@ -0,0 +1,119 @@
+package answerdoc
+
+import (
+	"cybernity/pkg/core/attestation"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func validDocument(t *testing.T) *Document {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	doc := &Document{
+		Schema:       SchemaV1,
+		QuestionID:   "42",
+		QuestionHash: HashQuestion("what is cybernity?"),
+		AgentCID:     "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+		KnowledgeCID: "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+		Answer:       "a knowledge market",
+		Model:        "gpt-4o",
+		Usage:        &Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		CreatedAt:    time.Unix(1700000000, 0).UTC(),
+		Citations:    []Citation{{Source: "whitepaper.md", Excerpt: "a knowledge market"}},
+		Attestation: &attestation.Attestation{
+			Version:      attestation.Version,
+			QuestionID:   "42",
+			AgentCID:     "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+			KnowledgeCID: "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+			AnswerHash:   attestation.HashAnswer([]byte("a knowledge market")),
+			Model:        "gpt-4o",
+			Timestamp:    1700000000,
+		},
+	}
+	if err := doc.Attestation.Sign(key); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return doc
+}
+
+// encrypt publishes the document's answer as ciphertext, attested with answerHash
+func encrypt(d *Document, answerHash string) {
+	d.Encrypted = true
+	d.Answer = base64.StdEncoding.EncodeToString([]byte("ciphertext"))
+	d.Attestation.AnswerHash = answerHash
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	doc := validDocument(t)
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if parsed.Answer != doc.Answer || !parsed.CreatedAt.Equal(doc.CreatedAt) || parsed.Usage.TotalTokens != 15 {
+		t.Errorf("Parse() = %+v, want %+v", parsed, doc)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(d *Document)
+		wantErr string
+	}{
+		{"valid", func(d *Document) {}, ""},
+		{"no attestation", func(d *Document) { d.Attestation = nil }, ""},
+		{"unknown schema", func(d *Document) { d.Schema = "cybernity.answer.v0" }, "unsupported answer schema"},
+		{"bad question id", func(d *Document) { d.QuestionID = "0x2a" }, "question_id"},
+		{"bad question hash", func(d *Document) { d.QuestionHash = "abc" }, "question_hash"},
+		{"bad agent cid", func(d *Document) { d.AgentCID = "not-a-cid" }, "agent_cid"},
+		{"missing created_at", func(d *Document) { d.CreatedAt = time.Time{} }, "created_at"},
+		{"negative usage", func(d *Document) { d.Usage.PromptTokens = -1 }, "usage"},
+		{"citation without source", func(d *Document) { d.Citations[0].Source = "" }, "citations[0]"},
+		{"tampered answer", func(d *Document) { d.Answer = "something else" }, "attested answer hash"},
+		{"mismatched attestation", func(d *Document) { d.Attestation.QuestionID = "43" }, "attestation does not match"},
+		{"swapped knowledge", func(d *Document) { d.KnowledgeCID = d.AgentCID }, "attestation does not match"},
+		{"encrypted not base64", func(d *Document) { d.Encrypted = true; d.Answer = "%%%" }, "base64"},
+		{"encrypted", func(d *Document) { encrypt(d, attestation.HashAnswer([]byte("ciphertext"))) }, ""},
+		{"encrypted hashed as plaintext", func(d *Document) { encrypt(d, attestation.HashAnswer([]byte("a knowledge market"))) }, "attested answer hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := validDocument(t)
+			tt.modify(doc)
+			err := doc.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaEmbedded(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(JSONSchema, &schema); err != nil {
+		t.Fatalf("embedded schema is not JSON: %v", err)
+	}
+	if schema["$id"] != SchemaV1 {
+		t.Errorf("schema $id = %v, want %s", schema["$id"], SchemaV1)
+	}
+}
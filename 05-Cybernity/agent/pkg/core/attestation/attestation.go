@@ -0,0 +1,83 @@
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const Version = 1
+
+// Attestation is the statement an agent's operator key signs for every answer it publishes
+type Attestation struct {
+	Version      int    `json:"version"`
+	QuestionID   string `json:"question_id"`
+	AgentCID     string `json:"agent_cid"`
+	KnowledgeCID string `json:"knowledge_cid"` // empty when the agent has no knowledge base
+	AgentAddress string `json:"agent_address"`
+	AnswerHash   string `json:"answer_hash"` // keccak256 of the published answer, the ciphertext when encrypted
+	Model        string `json:"model"`
+	Timestamp    int64  `json:"timestamp"`
+	Signature    string `json:"signature"` // EIP-191 personal_sign signature over Message()
+}
+
+// HashAnswer returns the hex keccak256 hash of a published answer. Encrypted answers are hashed as ciphertext,
+// a hash of a short plaintext answer would give it away.
+func HashAnswer(answer []byte) string {
+	return crypto.Keccak256Hash(answer).Hex()
+}
+
+// Message returns the human readable text that is signed, so wallets can display it
+func (a *Attestation) Message() string {
+	var b strings.Builder
+	b.WriteString("Cybernity answer attestation\n")
+	fmt.Fprintf(&b, "version: %d\n", a.Version)
+	fmt.Fprintf(&b, "question_id: %s\n", a.QuestionID)
+	fmt.Fprintf(&b, "agent_cid: %s\n", a.AgentCID)
+	fmt.Fprintf(&b, "knowledge_cid: %s\n", a.KnowledgeCID)
+	fmt.Fprintf(&b, "agent_address: %s\n", a.AgentAddress)
+	fmt.Fprintf(&b, "answer_hash: %s\n", a.AnswerHash)
+	fmt.Fprintf(&b, "model: %s\n", a.Model)
+	fmt.Fprintf(&b, "timestamp: %d", a.Timestamp)
+	return b.String()
+}
+
+// Sign signs the attestation with the operator key using EIP-191 personal_sign
+func (a *Attestation) Sign(privateKey *ecdsa.PrivateKey) error {
+	a.AgentAddress = crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+
+	sig, err := crypto.Sign(accounts.TextHash([]byte(a.Message())), privateKey)
+	if err != nil {
+		return err
+	}
+	// Use 27/28 for V as wallets do
+	sig[crypto.RecoveryIDOffset] += 27
+	a.Signature = hexutil.Encode(sig)
+	return nil
+}
+
+// Signer recovers the address that signed the attestation
+func (a *Attestation) Signer() (common.Address, error) {
+	sig, err := hexutil.Decode(a.Signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, errors.New("invalid signature length")
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := crypto.SigToPub(accounts.TextHash([]byte(a.Message())), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
@@ -0,0 +1,53 @@
+package attestation
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSignAndRecover(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	a := &Attestation{
+		Version:      Version,
+		QuestionID:   "42",
+		AgentCID:     "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+		KnowledgeCID: "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+		AnswerHash:   HashAnswer([]byte("answer")),
+		Model:        "gpt-4o",
+		Timestamp:    1700000000,
+	}
+	if err := a.Sign(key); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	signer, err := a.Signer()
+	if err != nil {
+		t.Fatalf("Signer() error = %v", err)
+	}
+	if signer != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("Signer() = %s, want %s", signer.Hex(), crypto.PubkeyToAddress(key.PublicKey).Hex())
+	}
+
+	tampered := []struct {
+		name   string
+		modify func(a *Attestation)
+	}{
+		{"answer hash", func(a *Attestation) { a.AnswerHash = HashAnswer([]byte("forged answer")) }},
+		{"knowledge cid", func(a *Attestation) { a.KnowledgeCID = "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG" }},
+	}
+	for _, tt := range tampered {
+		t.Run(tt.name, func(t *testing.T) {
+			forged := *a
+			tt.modify(&forged)
+			signer, err := forged.Signer()
+			if err == nil && signer == crypto.PubkeyToAddress(key.PublicKey) {
+				t.Error("Signer() recovered the operator for a modified attestation")
+			}
+		})
+	}
+}
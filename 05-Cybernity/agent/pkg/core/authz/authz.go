@@ -0,0 +1,138 @@
+package authz
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Changes a creator signs
+const (
+	ActionReserveKeys     = "reserve_keys"
+	ActionCreateAgent     = "create_agent"
+	ActionUpdateKnowledge = "update_knowledge"
+	ActionSavePersona     = "save_persona"
+)
+
+// MaxAge is how far a request's timestamp may be from now, which bounds how long a signature can be replayed
+const MaxAge = 5 * time.Minute
+
+var (
+	// ErrUnauthorized is returned when a request isn't signed by the agent's creator
+	ErrUnauthorized = errors.New("request is not signed by the agent's creator")
+	// ErrExpired is returned when a request was signed too long ago, or claims to be signed in the future
+	ErrExpired = errors.New("signed request has expired")
+)
+
+// Signature is a creator's EIP-191 personal_sign signature of a request, made at Timestamp (unix seconds)
+type Signature struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"signature"`
+}
+
+// Field is a named value of the change being signed
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Request is a change to an agent that only its creator may make. The server builds it from the change
+// it is about to apply, so a signature only authorizes exactly that change.
+type Request struct {
+	Action  string
+	Subject string  // agent CID, agent address for keys not bound to knowledge yet, or creator address to reserve keys
+	Fields  []Field // in the order they are signed
+	Signature
+}
+
+// Hash returns the hex keccak256 hash of content, signed in place of free text and files
+func Hash(content []byte) string {
+	return crypto.Keccak256Hash(content).Hex()
+}
+
+// Message returns the human readable text that is signed, so wallets can display it
+func (r *Request) Message() string {
+	var b strings.Builder
+	b.WriteString("Cybernity creator request\n")
+	fmt.Fprintf(&b, "action: %s\n", r.Action)
+	fmt.Fprintf(&b, "subject: %s\n", r.Subject)
+	for _, f := range r.Fields {
+		fmt.Fprintf(&b, "%s: %s\n", f.Name, f.Value)
+	}
+	fmt.Fprintf(&b, "timestamp: %d", r.Timestamp)
+	return b.String()
+}
+
+// Sign signs the request with the creator's key, as a wallet does for personal_sign
+func (r *Request) Sign(privateKey *ecdsa.PrivateKey) error {
+	sig, err := crypto.Sign(accounts.TextHash([]byte(r.Message())), privateKey)
+	if err != nil {
+		return err
+	}
+	// Use 27/28 for V as wallets do
+	sig[crypto.RecoveryIDOffset] += 27
+	r.Value = hexutil.Encode(sig)
+	return nil
+}
+
+// Signer recovers the address that signed the request
+func (r *Request) Signer() (common.Address, error) {
+	if err := r.check(); err != nil {
+		return common.Address{}, err
+	}
+	sig, err := hexutil.Decode(r.Value)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, errors.New("invalid signature length")
+	}
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := crypto.SigToPub(accounts.TextHash([]byte(r.Message())), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// Verify checks that the creator signed the request within MaxAge of now
+func (r *Request) Verify(creatorAddress string, now time.Time) error {
+	if r.Value == "" {
+		return fmt.Errorf("%w: signature is required", ErrUnauthorized)
+	}
+	if d := now.Sub(time.Unix(r.Timestamp, 0)); d > MaxAge || d < -MaxAge {
+		return ErrExpired
+	}
+	signer, err := r.Signer()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+	if !common.IsHexAddress(creatorAddress) || signer != common.HexToAddress(creatorAddress) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// check rejects values that would spill into other lines of the message, making two requests sign alike
+func (r *Request) check() error {
+	values := []string{r.Action, r.Subject}
+	for _, f := range r.Fields {
+		values = append(values, f.Name, f.Value)
+	}
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New("signed values must not contain line breaks")
+		}
+	}
+	return nil
+}
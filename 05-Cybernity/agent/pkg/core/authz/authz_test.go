@@ -0,0 +1,58 @@
+package authz
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestVerify(t *testing.T) {
+	creator, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	other, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(creator.PublicKey).Hex()
+	now := time.Unix(1700000000, 0)
+
+	signed := func(key func(*Request)) *Request {
+		r := &Request{
+			Action:    ActionUpdateKnowledge,
+			Subject:   "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+			Fields:    []Field{{"base_version", "2"}, {"file", Hash([]byte("doc"))}},
+			Signature: Signature{Timestamp: now.Unix()},
+		}
+		if err := r.Sign(creator); err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		key(r)
+		return r
+	}
+
+	if err := signed(func(*Request) {}).Verify(address, now.Add(time.Minute)); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		change func(*Request)
+		want   error
+	}{
+		{"other signer", func(r *Request) { r.Sign(other) }, ErrUnauthorized},
+		{"changed field", func(r *Request) { r.Fields[0].Value = "1" }, ErrUnauthorized},
+		{"other action", func(r *Request) { r.Action = ActionSavePersona }, ErrUnauthorized},
+		{"unsigned", func(r *Request) { r.Value = "" }, ErrUnauthorized},
+		{"garbage", func(r *Request) { r.Value = "0x1234" }, ErrUnauthorized},
+		{"line break", func(r *Request) { r.Fields[1].Value = "x\nbase_version: 1"; r.Sign(creator) }, ErrUnauthorized},
+		{"expired", func(r *Request) { r.Timestamp -= int64(MaxAge/time.Second) + 1; r.Sign(creator) }, ErrExpired},
+		{"future", func(r *Request) { r.Timestamp += int64(MaxAge/time.Second) + 1; r.Sign(creator) }, ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := signed(tt.change).Verify(address, now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
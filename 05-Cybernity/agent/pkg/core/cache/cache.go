@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is a two tier cache of decrypted content: an LRU in memory backed by an
+// optional encrypted disk tier. Every entry carries a version, such as a fingerprint
+// of the key that decrypted it, and a lookup with a different version is a miss,
+// so rotating keys invalidates stale plaintext.
+// A nil *Cache is a valid, always empty cache.
+type Cache struct {
+	cfg  *Config
+	mu   sync.Mutex
+	lru  *list.List
+	idx  map[string]*list.Element
+	size int64
+	disk *diskTier
+	now  func() time.Time
+}
+
+type entry struct {
+	key       string
+	version   string
+	value     []byte
+	expiresAt time.Time
+}
+
+// New creates a cache from the configuration
+func New(cfg *Config) (*Cache, error) {
+	cfg.MergeDefault()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		cfg: cfg,
+		lru: list.New(),
+		idx: make(map[string]*list.Element),
+		now: time.Now,
+	}
+	if cfg.Disk.Enabled {
+		disk, err := newDiskTier(&cfg.Disk)
+		if err != nil {
+			return nil, err
+		}
+		c.disk = disk
+	}
+	return c, nil
+}
+
+// Get returns the value cached for key at the given version
+func (c *Cache) Get(key, version string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	if el, ok := c.idx[key]; ok {
+		e := el.Value.(*entry)
+		if e.version == version && c.now().Before(e.expiresAt) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return e.value, true
+		}
+		c.removeElement(el)
+	}
+	c.mu.Unlock()
+
+	if c.disk == nil {
+		return nil, false
+	}
+	value, expiresAt, ok := c.disk.get(key, version, c.now())
+	if !ok {
+		return nil, false
+	}
+
+	// Promote to memory
+	c.mu.Lock()
+	c.setMemory(&entry{key: key, version: version, value: value, expiresAt: expiresAt})
+	c.mu.Unlock()
+	return value, true
+}
+
+// Set caches value for key at the given version
+func (c *Cache) Set(key, version string, value []byte) {
+	if c == nil {
+		return
+	}
+
+	e := &entry{key: key, version: version, value: value, expiresAt: c.now().Add(c.cfg.TTL)}
+	c.mu.Lock()
+	c.setMemory(e)
+	c.mu.Unlock()
+
+	if c.disk != nil {
+		c.disk.set(e)
+	}
+}
+
+// Invalidate removes key from every tier
+func (c *Cache) Invalidate(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	if el, ok := c.idx[key]; ok {
+		c.removeElement(el)
+	}
+	c.mu.Unlock()
+
+	if c.disk != nil {
+		c.disk.remove(key)
+	}
+}
+
+// Len returns the number of entries in memory
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) setMemory(e *entry) {
+	if el, ok := c.idx[e.key]; ok {
+		c.removeElement(el)
+	}
+	// Values bigger than the whole cache are only kept on disk
+	if int64(len(e.value)) > c.cfg.MaxMemoryBytes {
+		return
+	}
+
+	c.idx[e.key] = c.lru.PushFront(e)
+	c.size += int64(len(e.value))
+	for c.size > c.cfg.MaxMemoryBytes {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *Cache) removeElement(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.idx, e.key)
+	c.size -= int64(len(e.value))
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, cfg *Config) (*Cache, *time.Time) {
+	t.Helper()
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestMemoryLRU(t *testing.T) {
+	c, _ := newTestCache(t, &Config{MaxMemoryBytes: 10, TTL: time.Hour})
+
+	c.Set("a", "v1", []byte("aaaa"))
+	c.Set("b", "v1", []byte("bbbb"))
+	// Touch a so b is the least recently used
+	if _, ok := c.Get("a", "v1"); !ok {
+		t.Fatal("expected hit for a")
+	}
+	c.Set("c", "v1", []byte("cccc"))
+
+	if _, ok := c.Get("b", "v1"); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if v, ok := c.Get("a", "v1"); !ok || string(v) != "aaaa" {
+		t.Fatalf("unexpected a: %q %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestTTLAndVersion(t *testing.T) {
+	c, now := newTestCache(t, &Config{MaxMemoryBytes: 1024, TTL: time.Minute})
+
+	c.Set("cid", "key1", []byte("plain"))
+	if _, ok := c.Get("cid", "key2"); ok {
+		t.Fatal("expected miss after key rotation")
+	}
+	if _, ok := c.Get("cid", "key1"); ok {
+		t.Fatal("stale entry should have been dropped")
+	}
+
+	c.Set("cid", "key1", []byte("plain"))
+	*now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("cid", "key1"); ok {
+		t.Fatal("expected miss after ttl")
+	}
+}
+
+func TestDiskTier(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		MaxMemoryBytes: 1024,
+		TTL:            time.Hour,
+		Disk:           DiskConfig{Enabled: true, Path: dir, MaxBytes: 1 << 20},
+	}
+	c, _ := newTestCache(t, cfg)
+
+	secret := []byte("decrypted knowledge")
+	c.Set("cid", "v1", secret)
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+	raw, _ := os.ReadFile(dir + "/" + files[0].Name())
+	if bytes.Contains(raw, secret) {
+		t.Fatal("disk entry is not encrypted")
+	}
+
+	// Drop the memory tier, the entry should come back from disk
+	c.mu.Lock()
+	c.removeElement(c.idx["cid"])
+	c.mu.Unlock()
+	if v, ok := c.Get("cid", "v1"); !ok || !bytes.Equal(v, secret) {
+		t.Fatalf("expected disk hit, got %q %v", v, ok)
+	}
+
+	c.Invalidate("cid")
+	if _, ok := c.Get("cid", "v1"); ok {
+		t.Fatal("expected miss after invalidate")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected disk entry removed, got %d files", len(files))
+	}
+
+	// A cache with another key can't read the entry
+	c.Set("cid", "v1", secret)
+	other, _ := newTestCache(t, &Config{
+		MaxMemoryBytes: 1024,
+		TTL:            time.Hour,
+		Disk:           DiskConfig{Enabled: true, Path: dir, MaxBytes: 1 << 20},
+	})
+	if _, ok := other.Get("cid", "v1"); ok {
+		t.Fatal("expected miss with a different disk key")
+	}
+}
+
+func TestDiskEvictionKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	// Sharing the directory with something else, older and bigger than the cache budget
+	other := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(other, bytes.Repeat([]byte("x"), 2048), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(other, old, old)
+
+	c, _ := newTestCache(t, &Config{
+		MaxMemoryBytes: 1024,
+		TTL:            time.Hour,
+		Disk:           DiskConfig{Enabled: true, Path: dir, MaxBytes: 1024},
+	})
+	c.Set("a", "v1", []byte("aaaa"))
+
+	if _, err := os.Stat(other); err != nil {
+		t.Fatalf("eviction removed a file the cache doesn't own: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+diskEntryExt)); len(files) != 1 {
+		t.Fatalf("expected 1 cache entry, got %d", len(files))
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	c.Set("a", "v", []byte("x"))
+	if _, ok := c.Get("a", "v"); ok {
+		t.Fatal("nil cache should always miss")
+	}
+	c.Invalidate("a")
+}
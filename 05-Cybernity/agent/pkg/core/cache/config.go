@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+type Config struct {
+	Enabled        bool          `yaml:"enabled"`
+	MaxMemoryBytes int64         `yaml:"max_memory_bytes"`
+	TTL            time.Duration `yaml:"ttl"`
+	Disk           DiskConfig    `yaml:"disk"`
+}
+
+type DiskConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Path     string `yaml:"path"`
+	MaxBytes int64  `yaml:"max_bytes"`
+	// Key is the hex AES-256 key entries are encrypted with at rest.
+	// When empty a random key is generated, so entries don't survive restarts.
+	Key string `yaml:"key"`
+}
+
+// DefaultConfig returns a default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MaxMemoryBytes: 256 * 1024 * 1024,
+		TTL:            24 * time.Hour,
+		Disk: DiskConfig{
+			Path:     "./deployment/cache",
+			MaxBytes: 2 * 1024 * 1024 * 1024,
+		},
+	}
+}
+
+// MergeDefault merges the default configuration with the current configuration
+func (c *Config) MergeDefault() *Config {
+	def := DefaultConfig()
+	if c.MaxMemoryBytes == 0 {
+		c.MaxMemoryBytes = def.MaxMemoryBytes
+	}
+	if c.TTL == 0 {
+		c.TTL = def.TTL
+	}
+	if c.Disk.Path == "" {
+		c.Disk.Path = def.Disk.Path
+	}
+	if c.Disk.MaxBytes == 0 {
+		c.Disk.MaxBytes = def.Disk.MaxBytes
+	}
+	return c
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	if c.MaxMemoryBytes <= 0 {
+		return fmt.Errorf("max memory bytes must be greater than 0")
+	}
+	if c.TTL <= 0 {
+		return fmt.Errorf("ttl must be greater than 0")
+	}
+	if c.Disk.Enabled && c.Disk.MaxBytes <= 0 {
+		return fmt.Errorf("disk max bytes must be greater than 0")
+	}
+	if c.Disk.Key != "" {
+		key, err := hex.DecodeString(c.Disk.Key)
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("disk key must be 32 hex encoded bytes")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskEntryExt marks the files the disk tier owns, eviction leaves anything else in the directory alone
+const diskEntryExt = ".entry"
+
+// diskTier stores entries AES-GCM encrypted, one file per key named by the key's hash
+type diskTier struct {
+	cfg *DiskConfig
+	gcm cipher.AEAD
+	mu  sync.Mutex
+}
+
+type diskEntry struct {
+	Version   string    `json:"version"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Sealed    []byte    `json:"sealed"` // nonce + ciphertext
+}
+
+func newDiskTier(cfg *DiskConfig) (*diskTier, error) {
+	key := make([]byte, 32)
+	if cfg.Key != "" {
+		var err error
+		if key, err = hex.DecodeString(cfg.Key); err != nil {
+			return nil, err
+		}
+	} else if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate disk cache key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	if err := os.MkdirAll(cfg.Path, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &diskTier{cfg: cfg, gcm: gcm}, nil
+}
+
+func (d *diskTier) get(key, version string, now time.Time) ([]byte, time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	var e diskEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Version != version || !now.Before(e.ExpiresAt) || len(e.Sealed) < d.gcm.NonceSize() {
+		os.Remove(path)
+		return nil, time.Time{}, false
+	}
+
+	nonceSize := d.gcm.NonceSize()
+	value, err := d.gcm.Open(nil, e.Sealed[:nonceSize], e.Sealed[nonceSize:], d.additionalData(key, version))
+	if err != nil {
+		// Written with another key, e.g. before a restart without a configured key
+		os.Remove(path)
+		return nil, time.Time{}, false
+	}
+	return value, e.ExpiresAt, true
+}
+
+func (d *diskTier) set(e *entry) {
+	nonce := make([]byte, d.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+	data, err := json.Marshal(diskEntry{
+		Version:   e.version,
+		ExpiresAt: e.expiresAt,
+		Sealed:    d.gcm.Seal(nonce, nonce, e.value, d.additionalData(e.key, e.version)),
+	})
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The disk tier is best effort, a failed write is just a future miss
+	if err := os.WriteFile(d.path(e.key), data, 0o600); err != nil {
+		return
+	}
+	d.evict()
+}
+
+func (d *diskTier) remove(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	os.Remove(d.path(key))
+}
+
+// evict removes the least recently written files until the tier fits in MaxBytes
+func (d *diskTier) evict() {
+	entries, err := os.ReadDir(d.cfg.Path)
+	if err != nil {
+		return
+	}
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	var total int64
+	for _, de := range entries {
+		if !isDiskEntry(de.Name()) {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, file{path: filepath.Join(d.cfg.Path, de.Name()), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= d.cfg.MaxBytes {
+			return
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.size
+		}
+	}
+}
+
+func (d *diskTier) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.cfg.Path, hex.EncodeToString(sum[:])+diskEntryExt)
+}
+
+// isDiskEntry reports whether name is one of path's file names
+func isDiskEntry(name string) bool {
+	hash, ok := strings.CutSuffix(name, diskEntryExt)
+	if !ok || len(hash) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// additionalData binds the ciphertext to its key and version so files can't be swapped
+func (d *diskTier) additionalData(key, version string) []byte {
+	return []byte(key + "\x00" + version)
+}
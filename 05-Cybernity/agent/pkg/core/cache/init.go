@@ -0,0 +1,32 @@
+package cache
+
+import "sync"
+
+var (
+	defaultCache *Cache
+	mu           sync.RWMutex
+)
+
+// InitWithConfig initializes the default cache, leaving it nil when disabled
+func InitWithConfig(cfg *Config) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	c, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	defaultCache = c
+	return nil
+}
+
+// GetCache returns the default cache, nil when caching is disabled
+func GetCache() *Cache {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultCache
+}
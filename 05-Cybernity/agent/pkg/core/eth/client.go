@@ -3,10 +3,13 @@ package eth
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
+	"fmt"
 	"log"
 	"math/big"
 	"strings"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -80,3 +83,98 @@ func (s *Service) SubmitAnswer(ctx context.Context, agentPrivateKey string, ques
 
 	return signedTx.Hash(), nil
 }
+
+// SenderPublicKey recovers the public key of the account that signed the transaction
+func (s *Service) SenderPublicKey(ctx context.Context, txHash common.Hash) (*ecdsa.PublicKey, common.Address, error) {
+	client, err := ethclient.Dial(s.cfg.WsURL)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	defer client.Close()
+
+	tx, _, err := client.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+
+	chainID := tx.ChainId()
+	if chainID == nil || chainID.Sign() == 0 {
+		if chainID, err = client.NetworkID(ctx); err != nil {
+			return nil, common.Address{}, err
+		}
+	}
+
+	return RecoverPublicKey(tx, chainID)
+}
+
+// RecoverPublicKey recovers the signer's public key from a signed transaction
+func RecoverPublicKey(tx *types.Transaction, chainID *big.Int) (*ecdsa.PublicKey, common.Address, error) {
+	var signer types.Signer = types.LatestSignerForChainID(chainID)
+	v, r, sv := tx.RawSignatureValues()
+	if v == nil || r == nil || sv == nil {
+		return nil, common.Address{}, errors.New("transaction is not signed")
+	}
+
+	// Normalize V to the 0/1 recovery id
+	recoveryID := new(big.Int).Set(v)
+	if tx.Type() == types.LegacyTxType {
+		if tx.Protected() {
+			recoveryID.Sub(recoveryID, new(big.Int).Add(new(big.Int).Mul(chainID, big.NewInt(2)), big.NewInt(35)))
+		} else {
+			// Pre-EIP-155 transactions are signed over a hash without the chain ID
+			signer = types.HomesteadSigner{}
+			recoveryID.Sub(recoveryID, big.NewInt(27))
+		}
+	}
+	if !recoveryID.IsUint64() || recoveryID.Uint64() > 1 {
+		return nil, common.Address{}, fmt.Errorf("invalid signature recovery id: %s", v)
+	}
+
+	sig := make([]byte, crypto.SignatureLength)
+	r.FillBytes(sig[:32])
+	sv.FillBytes(sig[32:64])
+	sig[crypto.RecoveryIDOffset] = byte(recoveryID.Uint64())
+
+	publicKey, err := crypto.SigToPub(signer.Hash(tx).Bytes(), sig)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return publicKey, crypto.PubkeyToAddress(*publicKey), nil
+}
+
+// GetAgentOperator returns the operator address registered on-chain for the agent CID
+func (s *Service) GetAgentOperator(ctx context.Context, cid string) (common.Address, error) {
+	client, err := ethclient.Dial(s.cfg.WsURL)
+	if err != nil {
+		return common.Address{}, err
+	}
+	defer client.Close()
+
+	parsedABI, err := abi.JSON(strings.NewReader(PublicKnowledgeAgentABI))
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	data, err := parsedABI.Pack("getAgentDetails", cid)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	contractAddress := common.HexToAddress(s.cfg.ContractAddress)
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &contractAddress, Data: data}, nil)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	var details struct {
+		Creator     common.Address
+		Operator    common.Address
+		Name        string
+		Description string
+		Price       *big.Int
+	}
+	if err := parsedABI.UnpackIntoInterface(&details, "getAgentDetails", output); err != nil {
+		return common.Address{}, err
+	}
+	return details.Operator, nil
+}
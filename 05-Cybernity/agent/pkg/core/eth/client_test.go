@@ -0,0 +1,68 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestRecoverPublicKey(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	chainID := big.NewInt(11155111)
+	to := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	tests := []struct {
+		name   string
+		tx     *types.Transaction
+		signer types.Signer
+	}{
+		{
+			name:   "legacy eip155",
+			tx:     types.NewTransaction(0, to, big.NewInt(0), 21000, big.NewInt(1), nil),
+			signer: types.NewEIP155Signer(chainID),
+		},
+		{
+			name:   "legacy homestead",
+			tx:     types.NewTransaction(0, to, big.NewInt(0), 21000, big.NewInt(1), nil),
+			signer: types.HomesteadSigner{},
+		},
+		{
+			name: "dynamic fee",
+			tx: types.NewTx(&types.DynamicFeeTx{
+				ChainID:   chainID,
+				To:        &to,
+				Gas:       21000,
+				GasTipCap: big.NewInt(1),
+				GasFeeCap: big.NewInt(2),
+				Data:      []byte("askQuestion"),
+			}),
+			signer: types.NewLondonSigner(chainID),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signedTx, err := types.SignTx(tt.tx, tt.signer, key)
+			if err != nil {
+				t.Fatalf("SignTx() error = %v", err)
+			}
+
+			publicKey, address, err := RecoverPublicKey(signedTx, chainID)
+			if err != nil {
+				t.Fatalf("RecoverPublicKey() error = %v", err)
+			}
+			if address != crypto.PubkeyToAddress(key.PublicKey) {
+				t.Errorf("RecoverPublicKey() address = %s, want %s", address.Hex(), crypto.PubkeyToAddress(key.PublicKey).Hex())
+			}
+			if !publicKey.Equal(&key.PublicKey) {
+				t.Error("RecoverPublicKey() returned a different public key")
+			}
+		})
+	}
+}
@@ -4,4 +4,5 @@ type Config struct {
 	WsURL           string `yaml:"ws_url"`
 	ContractAddress string `yaml:"contract_address"`
 	PrivateKey      string `yaml:"private_key"`
+	EncryptAnswers  bool   `yaml:"encrypt_answers"` // encrypt answers to the questioner's public key
 }
@@ -0,0 +1,143 @@
+package knowledge
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Config struct {
+	MaxFiles     int   `yaml:"max_files"`
+	MaxTotalSize int64 `yaml:"max_total_size"`
+	// MaxFileSize limits each document, MaxFileSizes overrides it for some formats
+	MaxFileSize  int64            `yaml:"max_file_size"`
+	MaxFileSizes map[Format]int64 `yaml:"max_file_sizes"`
+	// MaxTextLength limits the characters of text extracted from a knowledge base
+	MaxTextLength int           `yaml:"max_text_length"`
+	Archive       ArchiveConfig `yaml:"archive"`
+}
+
+// ArchiveConfig bounds the zip container of DOCX documents
+type ArchiveConfig struct {
+	MaxEntries          int     `yaml:"max_entries"`
+	MaxUncompressedSize int64   `yaml:"max_uncompressed_size"`
+	MaxCompressionRatio float64 `yaml:"max_compression_ratio"`
+}
+
+var (
+	config   = DefaultConfig()
+	configMu sync.RWMutex
+)
+
+// DefaultConfig returns a default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MaxFiles:     20,
+		MaxTotalSize: 20 * 1024 * 1024,
+		MaxFileSize:  5 * 1024 * 1024,
+		MaxFileSizes: map[Format]int64{
+			FormatPDF:  10 * 1024 * 1024,
+			FormatDOCX: 10 * 1024 * 1024,
+		},
+		MaxTextLength: 2_000_000,
+		Archive: ArchiveConfig{
+			MaxEntries:          1000,
+			MaxUncompressedSize: 64 * 1024 * 1024,
+			MaxCompressionRatio: 100,
+		},
+	}
+}
+
+// MergeDefault merges the default configuration with the current configuration
+func (c *Config) MergeDefault() *Config {
+	def := DefaultConfig()
+	if c.MaxFiles == 0 {
+		c.MaxFiles = def.MaxFiles
+	}
+	if c.MaxTotalSize == 0 {
+		c.MaxTotalSize = def.MaxTotalSize
+	}
+	if c.MaxFileSize == 0 {
+		c.MaxFileSize = def.MaxFileSize
+	}
+	if c.MaxFileSizes == nil {
+		c.MaxFileSizes = def.MaxFileSizes
+	}
+	if c.MaxTextLength == 0 {
+		c.MaxTextLength = def.MaxTextLength
+	}
+	if c.Archive.MaxEntries == 0 {
+		c.Archive.MaxEntries = def.Archive.MaxEntries
+	}
+	if c.Archive.MaxUncompressedSize == 0 {
+		c.Archive.MaxUncompressedSize = def.Archive.MaxUncompressedSize
+	}
+	if c.Archive.MaxCompressionRatio == 0 {
+		c.Archive.MaxCompressionRatio = def.Archive.MaxCompressionRatio
+	}
+	return c
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	if c.MaxFiles <= 0 {
+		return fmt.Errorf("max files must be greater than 0")
+	}
+	if c.MaxTotalSize <= 0 || c.MaxFileSize <= 0 {
+		return fmt.Errorf("max file and total sizes must be greater than 0")
+	}
+	for format, size := range c.MaxFileSizes {
+		switch format {
+		case FormatPDF, FormatDOCX, FormatHTML, FormatMarkdown, FormatCSV, FormatText:
+		default:
+			return fmt.Errorf("unknown format %q in max file sizes", format)
+		}
+		if size <= 0 {
+			return fmt.Errorf("max file size of %s must be greater than 0", format)
+		}
+	}
+	if c.MaxTextLength <= 0 {
+		return fmt.Errorf("max text length must be greater than 0")
+	}
+	if c.Archive.MaxEntries <= 0 || c.Archive.MaxUncompressedSize <= 0 || c.Archive.MaxCompressionRatio <= 0 {
+		return fmt.Errorf("archive limits must be greater than 0")
+	}
+	return nil
+}
+
+// FileSizeLimit returns the size limit of a document in the given format
+func (c *Config) FileSizeLimit(format Format) int64 {
+	if size, ok := c.MaxFileSizes[format]; ok {
+		return size
+	}
+	return c.MaxFileSize
+}
+
+// UploadSizeLimit returns the size no document may exceed whatever its format,
+// which can be checked before reading it
+func (c *Config) UploadSizeLimit() int64 {
+	limit := c.MaxFileSize
+	for _, size := range c.MaxFileSizes {
+		limit = max(limit, size)
+	}
+	return limit
+}
+
+// InitWithConfig sets the limits documents are validated with
+func InitWithConfig(cfg *Config) error {
+	cfg.MergeDefault()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = cfg
+	return nil
+}
+
+// GetConfig returns the limits documents are validated with
+func GetConfig() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
@@ -0,0 +1,202 @@
+package knowledge
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	docxDocument = "word/document.xml"
+	// minBombSize is the uncompressed size under which an archive is too small to be a zip bomb,
+	// however well it compresses
+	minBombSize = 1024 * 1024
+)
+
+func isDOCX(content []byte) bool {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return false
+	}
+	for _, f := range r.File {
+		if f.Name == docxDocument {
+			return true
+		}
+	}
+	return false
+}
+
+// extractDOCX returns the paragraphs of a Word document, with headings, list items and tables marked up
+func extractDOCX(content []byte, limits ArchiveConfig) (string, error) {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	if err := checkArchive(r, int64(len(content)), limits); err != nil {
+		return "", err
+	}
+
+	var document *zip.File
+	for _, f := range r.File {
+		if f.Name == docxDocument {
+			document = f
+		}
+	}
+	if document == nil {
+		return "", fmt.Errorf("%w: missing %s", ErrInvalidDocument, docxDocument)
+	}
+	rc, err := document.Open()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	defer rc.Close()
+
+	// The sizes in the zip headers may lie, so the document is read within the limit too
+	text, err := docxText(&limitedReader{r: rc, n: limits.MaxUncompressedSize})
+	if errors.Is(err, ErrArchiveBomb) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	return text, nil
+}
+
+// checkArchive rejects archives with zip bomb characteristics: too many entries,
+// too much uncompressed data or an unlikely compression ratio
+func checkArchive(r *zip.Reader, size int64, limits ArchiveConfig) error {
+	if len(r.File) > limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrArchiveBomb, limits.MaxEntries)
+	}
+	var uncompressed uint64
+	for _, f := range r.File {
+		uncompressed += f.UncompressedSize64
+		if uncompressed > uint64(limits.MaxUncompressedSize) {
+			return fmt.Errorf("%w: uncompressed size exceeds %d bytes", ErrArchiveBomb, limits.MaxUncompressedSize)
+		}
+	}
+	if uncompressed > minBombSize && float64(uncompressed) > float64(size)*limits.MaxCompressionRatio {
+		return fmt.Errorf("%w: compression ratio exceeds %g", ErrArchiveBomb, limits.MaxCompressionRatio)
+	}
+	return nil
+}
+
+// limitedReader fails once more than n bytes are read
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, fmt.Errorf("%w: uncompressed size exceeds the limit", ErrArchiveBomb)
+	}
+	return n, err
+}
+
+func docxText(r io.Reader) (string, error) {
+	var (
+		out       strings.Builder
+		para      strings.Builder
+		prefix    string
+		inText    bool
+		tableRows int // nesting depth of tables
+		cells     []string
+	)
+
+	decoder := xml.NewDecoder(r)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				prefix = ""
+			case "pStyle":
+				prefix = docxStylePrefix(xmlAttr(t, "val"))
+			case "numPr":
+				if prefix == "" {
+					prefix = "- "
+				}
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				para.WriteString("\n")
+			case "tbl":
+				tableRows++
+			case "tr":
+				cells = cells[:0]
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if tableRows > 0 {
+					// Paragraphs of a cell are joined into the cell
+					if len(cells) == 0 {
+						cells = append(cells, "")
+					}
+					last := len(cells) - 1
+					cells[last] = strings.TrimSpace(cells[last] + " " + para.String())
+				} else {
+					out.WriteString(prefix + para.String() + "\n\n")
+				}
+				para.Reset()
+			case "tc":
+				cells = append(cells, "")
+			case "tr":
+				// Every cell ends with an empty placeholder for the next one
+				if len(cells) > 0 {
+					cells = cells[:len(cells)-1]
+				}
+				out.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+			case "tbl":
+				tableRows--
+				out.WriteString("\n")
+			}
+		}
+	}
+	return out.String(), nil
+}
+
+// docxStylePrefix marks Title and HeadingN paragraphs as Markdown headings
+func docxStylePrefix(style string) string {
+	if style == "Title" {
+		return "# "
+	}
+	if level, err := strconv.Atoi(strings.TrimPrefix(style, "Heading")); err == nil && strings.HasPrefix(style, "Heading") && level > 0 && level <= 6 {
+		return strings.Repeat("#", level) + " "
+	}
+	return ""
+}
+
+func xmlAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,243 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Format is a document format text can be extracted from
+type Format string
+
+const (
+	FormatPDF      Format = "pdf"
+	FormatDOCX     Format = "docx"
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "markdown"
+	FormatCSV      Format = "csv"
+	FormatText     Format = "text"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported document format")
+	ErrInvalidDocument   = errors.New("invalid document")
+
+	ErrExecutable      = fmt.Errorf("%w: executable", ErrUnsupportedFormat)
+	ErrArchive         = fmt.Errorf("%w: archive", ErrUnsupportedFormat)
+	ErrArchiveBomb     = fmt.Errorf("%w: suspicious archive", ErrInvalidDocument)
+	ErrInvalidEncoding = fmt.Errorf("%w: unknown text encoding", ErrInvalidDocument)
+	ErrEmptyDocument   = fmt.Errorf("%w: no text found", ErrInvalidDocument)
+)
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// Detect returns the format of a document from its magic bytes, falling back to its name and content type.
+// Executables and archives are rejected whatever their name.
+func Detect(name, contentType string, content []byte) (Format, error) {
+	if err := sniffUnsafe(content); err != nil {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(content, []byte("%PDF-")):
+		return FormatPDF, nil
+	case bytes.HasPrefix(content, []byte("PK\x03\x04")):
+		if isDOCX(content) {
+			return FormatDOCX, nil
+		}
+		return "", fmt.Errorf("%w: zip archives other than docx are not supported", ErrArchive)
+	}
+
+	sniffed := http.DetectContentType(content)
+	if _, utf16 := utf16Order(content); !strings.HasPrefix(sniffed, "text/") && !hasTextBOM(content) && !utf16 {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, sniffed)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case ext == ".html" || ext == ".htm" || mediaType == "text/html":
+		return FormatHTML, nil
+	case ext == ".md" || ext == ".markdown" || mediaType == "text/markdown":
+		return FormatMarkdown, nil
+	case ext == ".csv" || mediaType == "text/csv":
+		return FormatCSV, nil
+	case strings.HasPrefix(sniffed, "text/html"):
+		return FormatHTML, nil
+	}
+	return FormatText, nil
+}
+
+// Extract converts a document to normalized UTF-8 text, keeping headings, lists and tables readable
+func Extract(name, contentType string, content []byte) (string, Format, error) {
+	format, err := Detect(name, contentType, content)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, params, _ := mime.ParseMediaType(contentType)
+
+	var text string
+	switch format {
+	case FormatPDF:
+		text, err = extractPDF(content)
+	case FormatDOCX:
+		text, err = extractDOCX(content, GetConfig().Archive)
+	default:
+		text, err = decodeText(content, params["charset"])
+		if err != nil {
+			break
+		}
+		switch format {
+		case FormatHTML:
+			text, err = extractHTML(text)
+		case FormatCSV:
+			text, err = extractCSV(text)
+		}
+	}
+	if err != nil {
+		return "", format, err
+	}
+
+	text = Normalize(text)
+	if text == "" {
+		return "", format, fmt.Errorf("%w in %s", ErrEmptyDocument, format)
+	}
+	return text, format, nil
+}
+
+// Normalize returns text in NFC with LF line endings, no control characters,
+// no trailing spaces and at most one blank line in a row
+func Normalize(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || r == '\ufeff' || (r < 0x20 && r != '\n' && r != '\t') || r == 0x7f {
+			return -1
+		}
+		return r
+	}, text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\u00a0")
+	}
+	text = blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return norm.NFC.String(strings.TrimSpace(text))
+}
+
+func hasTextBOM(content []byte) bool {
+	return bytes.HasPrefix(content, []byte{0xef, 0xbb, 0xbf}) ||
+		bytes.HasPrefix(content, []byte{0xff, 0xfe}) ||
+		bytes.HasPrefix(content, []byte{0xfe, 0xff})
+}
+
+// decodeText decodes text in its byte order mark or declared charset, detecting UTF-8, UTF-16
+// and falling back to Windows-1252 for legacy 8-bit text
+func decodeText(content []byte, charset string) (string, error) {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xff, 0xfe}) || bytes.HasPrefix(content, []byte{0xfe, 0xff}):
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), content)
+	case bytes.HasPrefix(content, []byte{0xef, 0xbb, 0xbf}):
+		content, charset = content[3:], ""
+	}
+
+	// A declared UTF-8 charset is often a default, so invalid UTF-8 is still detected
+	if charset != "" && !strings.EqualFold(charset, "utf-8") && !strings.EqualFold(charset, "utf8") {
+		enc, err := htmlindex.Get(charset)
+		if err != nil {
+			return "", fmt.Errorf("%w: unsupported charset %q", ErrInvalidEncoding, charset)
+		}
+		return decodeWith(enc, content)
+	}
+
+	if utf8.Valid(content) {
+		return string(content), nil
+	}
+	if order, ok := utf16Order(content); ok {
+		return decodeWith(unicode.UTF16(order, unicode.IgnoreBOM), content)
+	}
+	if isLegacyText(content) {
+		return decodeWith(charmap.Windows1252, content)
+	}
+	return "", fmt.Errorf("%w: text is neither UTF-8, UTF-16 nor Windows-1252", ErrInvalidEncoding)
+}
+
+func decodeWith(enc encoding.Encoding, content []byte) (string, error) {
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return string(decoded), nil
+}
+
+// utf16Order detects UTF-16 text without a byte order mark from the zero bytes of ASCII characters
+func utf16Order(content []byte) (unicode.Endianness, bool) {
+	sample := content[:min(len(content), 1024)]
+	if len(sample) < 4 || len(sample)%2 != 0 {
+		return unicode.LittleEndian, false
+	}
+	var even, odd int
+	for i := 0; i < len(sample); i += 2 {
+		if sample[i] == 0 {
+			even++
+		}
+		if sample[i+1] == 0 {
+			odd++
+		}
+	}
+	pairs := len(sample) / 2
+	switch {
+	case odd*10 >= pairs*4 && even*10 < pairs:
+		return unicode.LittleEndian, true
+	case even*10 >= pairs*4 && odd*10 < pairs:
+		return unicode.BigEndian, true
+	}
+	return unicode.LittleEndian, false
+}
+
+// isLegacyText reports whether content has no control bytes other than whitespace,
+// as 8-bit text encodings don't use them
+func isLegacyText(content []byte) bool {
+	for _, b := range content {
+		if (b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f') || b == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// extractCSV renders rows as pipe separated lines, the first one being the header
+func extractCSV(text string) (string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	var b strings.Builder
+	for i, record := range records {
+		for j, field := range record {
+			record[j] = strings.Join(strings.Fields(field), " ")
+		}
+		b.WriteString("| " + strings.Join(record, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString(strings.Repeat("| --- ", len(record)) + "|\n")
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,223 @@
+package knowledge
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"rsc.io/pdf"
+)
+
+// minimalPDF builds a one page PDF drawing each line with Helvetica
+func minimalPDF(lines ...string) []byte {
+	var stream strings.Builder
+	stream.WriteString("BT /F1 12 Tf 72 720 Td 14 TL\n")
+	for _, line := range lines {
+		fmt.Fprintf(&stream, "(%s) Tj T*\n", line)
+	}
+	stream.WriteString("ET")
+
+	widths := strings.TrimSpace(strings.Repeat("600 ", 95))
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", stream.Len(), stream.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /FirstChar 32 /LastChar 126 /Widths [" + widths + "] >>",
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+func zipFile(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	f, err := w.Create(name)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	f.Write([]byte(content))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return b.Bytes()
+}
+
+// peFile builds a DOS header pointing to a PE header
+func peFile() []byte {
+	content := make([]byte, 0x80)
+	copy(content, "MZ")
+	content[0x3c] = 0x40
+	copy(content[0x40:], "PE\x00\x00")
+	return content
+}
+
+const docxXML = `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Overview</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Agents answer </w:t></w:r><w:r><w:t>questions.</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t>first point</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>name</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>price</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>basic</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>10</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body></w:document>`
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileName    string
+		contentType string
+		content     []byte
+		format      Format
+		want        []string
+	}{
+		{
+			name:     "pdf",
+			fileName: "paper.pdf",
+			content:  minimalPDF("Hello PDF world", "Second line"),
+			format:   FormatPDF,
+			want:     []string{"Hello PDF world\nSecond line"},
+		},
+		{
+			name:     "docx",
+			fileName: "notes.docx",
+			content:  zipFile(t, docxDocument, docxXML),
+			format:   FormatDOCX,
+			want:     []string{"# Overview", "Agents answer questions.", "- first point", "| name | price |\n| basic | 10 |"},
+		},
+		{
+			name:     "html",
+			fileName: "page.html",
+			content:  []byte("<html><head><title>t</title><style>p{}</style></head><body><h2>Intro</h2><p>Some   <b>bold</b>\ntext</p><script>alert(1)</script><ul><li>one</li><li>two</li></ul></body></html>"),
+			format:   FormatHTML,
+			want:     []string{"## Intro", "Some bold text", "- one\n- two"},
+		},
+		{
+			name:        "markdown",
+			fileName:    "README",
+			contentType: "text/markdown; charset=utf-8",
+			content:     []byte("# Title\r\n\r\n\r\n\r\nbody   \r\n"),
+			format:      FormatMarkdown,
+			want:        []string{"# Title\n\nbody"},
+		},
+		{
+			name:     "csv",
+			fileName: "prices.csv",
+			content:  []byte("name,price\nbasic,10\n\"pro, yearly\",100\n"),
+			format:   FormatCSV,
+			want:     []string{"| name | price |\n| --- | --- |\n| basic | 10 |\n| pro, yearly | 100 |"},
+		},
+		{
+			name:     "utf-16 text",
+			fileName: "notes.txt",
+			content:  []byte{0xff, 0xfe, 'h', 0, 'i', 0, ' ', 0, 0xe9, 0},
+			format:   FormatText,
+			want:     []string{"hi é"},
+		},
+		{
+			name:     "utf-16 text without bom",
+			fileName: "notes.txt",
+			content:  []byte{'h', 0, 'i', 0, ' ', 0, 0xe9, 0},
+			format:   FormatText,
+			want:     []string{"hi é"},
+		},
+		{
+			name:     "windows-1252 text",
+			fileName: "notes.txt",
+			content:  []byte("caf\xe9 \x93quoted\x94"),
+			format:   FormatText,
+			want:     []string{"café “quoted”"},
+		},
+		{
+			name:        "declared charset",
+			fileName:    "notes.txt",
+			contentType: "text/plain; charset=iso-8859-2",
+			content:     []byte("\xb3\xf3d\xbc"),
+			format:      FormatText,
+			want:        []string{"łódź"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, format, err := Extract(tt.fileName, tt.contentType, tt.content)
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if format != tt.format {
+				t.Errorf("Extract() format = %s, want %s", format, tt.format)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("Extract() = %q, want it to contain %q", text, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		err     error
+	}{
+		{"elf", []byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00"), ErrExecutable},
+		{"pe", peFile(), ErrExecutable},
+		{"script", []byte("#!/bin/sh\nrm -rf /\n"), ErrExecutable},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), ErrUnsupportedFormat},
+		{"zip", zipFile(t, "a.txt", "hello"), ErrArchive},
+		{"gzip", []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00"), ErrArchive},
+		{"tar", append(make([]byte, 257), "ustar\x0000"...), ErrArchive},
+		{"docx bomb", zipFile(t, docxDocument, strings.Repeat(" ", 80<<20)), ErrArchiveBomb},
+		{"broken pdf", []byte("%PDF-1.4\ngarbage"), ErrInvalidDocument},
+		{"empty text", []byte("   \n\n  "), ErrEmptyDocument},
+		{"unknown charset", []byte("hello"), ErrInvalidEncoding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType := ""
+			if tt.name == "unknown charset" {
+				contentType = "text/plain; charset=klingon"
+			}
+			if _, _, err := Extract("file", contentType, tt.content); !errors.Is(err, tt.err) {
+				t.Errorf("Extract() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestPDFPageText(t *testing.T) {
+	glyph := func(s string, x, y float64) pdf.Text {
+		return pdf.Text{S: s, X: x, Y: y, W: 5, FontSize: 10}
+	}
+	// Scrambled as content streams often are, with a superscript above the first baseline
+	glyphs := []pdf.Text{
+		glyph("d", 10, 688),
+		glyph("2", 10, 703),
+		glyph("b", 5, 700),
+		glyph("c", 5, 688),
+		glyph("a", 0, 700),
+		glyph("x", 30, 700),
+		glyph("e", 0, 694), // as close to both lines, it starts one rather than chaining them
+	}
+	want := "ab2 x\ne\ncd"
+	if got := pdfPageText(glyphs); got != want {
+		t.Errorf("pdfPageText() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+package knowledge
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// extractHTML returns the visible text of a page, with headings, list items and table rows on their own lines
+func extractHTML(text string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	var b strings.Builder
+	walkHTML(&b, doc, false)
+	return b.String(), nil
+}
+
+func walkHTML(b *strings.Builder, n *html.Node, pre bool) {
+	switch n.Type {
+	case html.TextNode:
+		if pre {
+			b.WriteString(n.Data)
+			return
+		}
+		// Collapse whitespace like a browser would
+		words := strings.Fields(n.Data)
+		if len(words) == 0 {
+			if n.Data != "" {
+				writeSpace(b)
+			}
+			return
+		}
+		if n.Data[0] == ' ' || n.Data[0] == '\n' || n.Data[0] == '\t' {
+			writeSpace(b)
+		}
+		b.WriteString(strings.Join(words, " "))
+		if last := n.Data[len(n.Data)-1]; last == ' ' || last == '\n' || last == '\t' {
+			writeSpace(b)
+		}
+		return
+	case html.ElementNode:
+		switch n.Data {
+		case "script", "style", "noscript", "template", "head", "svg":
+			return
+		case "br":
+			b.WriteString("\n")
+			return
+		case "pre":
+			pre = true
+		}
+	}
+
+	breaks, prefix, suffix := htmlBlock(n)
+	lineBreak(b, breaks)
+	b.WriteString(prefix)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkHTML(b, c, pre)
+	}
+	b.WriteString(suffix)
+	lineBreak(b, breaks)
+}
+
+// htmlBlock returns how many line breaks surround an element and the text written around it
+func htmlBlock(n *html.Node) (int, string, string) {
+	if n.Type != html.ElementNode {
+		return 0, "", ""
+	}
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return 2, strings.Repeat("#", int(n.Data[1]-'0')) + " ", ""
+	case "li":
+		return 1, "- ", ""
+	case "tr":
+		return 1, "|", ""
+	case "td", "th":
+		return 0, " ", " |"
+	case "p", "div", "section", "article", "header", "footer", "main", "nav", "aside",
+		"ul", "ol", "table", "blockquote", "pre", "dl", "dt", "dd", "figure", "hr", "form":
+		return 2, "", ""
+	}
+	return 0, "", ""
+}
+
+// lineBreak ends the output with at least n line breaks, unless nothing was written yet
+func lineBreak(b *strings.Builder, n int) {
+	s := strings.TrimRight(b.String(), " ")
+	if s == "" {
+		return
+	}
+	for i := len(s) - 1; i >= 0 && s[i] == '\n' && n > 0; i-- {
+		n--
+	}
+	b.WriteString(strings.Repeat("\n", n))
+}
+
+func writeSpace(b *strings.Builder) {
+	s := b.String()
+	if s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		b.WriteString(" ")
+	}
+}
@@ -0,0 +1,157 @@
+package knowledge
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Validation error codes, returned to clients so they can explain why an upload was refused
+const (
+	CodeNoFiles           = "no_files"
+	CodeTooManyFiles      = "too_many_files"
+	CodeFileTooLarge      = "file_too_large"
+	CodeTotalTooLarge     = "total_too_large"
+	CodeUnsupportedFormat = "unsupported_format"
+	CodeExecutable        = "executable"
+	CodeArchive           = "archive"
+	CodeArchiveBomb       = "archive_bomb"
+	CodeInvalidEncoding   = "invalid_encoding"
+	CodeInvalidDocument   = "invalid_document"
+	CodeEmptyDocument     = "empty_document"
+	CodeTextTooLong       = "text_too_long"
+)
+
+// errorCodes maps extraction errors to codes, the most specific first
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrExecutable, CodeExecutable},
+	{ErrArchive, CodeArchive},
+	{ErrArchiveBomb, CodeArchiveBomb},
+	{ErrInvalidEncoding, CodeInvalidEncoding},
+	{ErrEmptyDocument, CodeEmptyDocument},
+	{ErrUnsupportedFormat, CodeUnsupportedFormat},
+	{ErrInvalidDocument, CodeInvalidDocument},
+}
+
+// ValidationError explains why documents were refused
+type ValidationError struct {
+	Code    string `json:"code"`
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+	// Limit is the limit exceeded, in bytes or characters
+	Limit int64 `json:"limit,omitempty"`
+
+	err error
+}
+
+func (e *ValidationError) Error() string {
+	if e.File != "" {
+		return e.File + ": " + e.Message
+	}
+	return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+// NewValidationError returns the validation error of a file from an extraction error
+func NewValidationError(file string, err error) *ValidationError {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return verr
+	}
+	code := CodeInvalidDocument
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			code = c.code
+			break
+		}
+	}
+	return &ValidationError{Code: code, File: file, Message: err.Error(), err: err}
+}
+
+// CheckLimits checks the number, formats and sizes of a set of documents fit in one knowledge base
+func CheckLimits(docs []Document) error {
+	cfg := GetConfig()
+	if len(docs) == 0 {
+		return &ValidationError{Code: CodeNoFiles, Message: "knowledge base has no documents"}
+	}
+	if len(docs) > cfg.MaxFiles {
+		return &ValidationError{
+			Code:    CodeTooManyFiles,
+			Message: fmt.Sprintf("at most %d files can be uploaded", cfg.MaxFiles),
+			Limit:   int64(cfg.MaxFiles),
+		}
+	}
+
+	var total int64
+	for _, doc := range docs {
+		format, err := Detect(doc.Name, doc.ContentType, doc.Content)
+		if err != nil {
+			return NewValidationError(doc.Name, err)
+		}
+		if limit := cfg.FileSizeLimit(format); int64(len(doc.Content)) > limit {
+			return FileTooLarge(doc.Name, limit)
+		}
+		total += int64(len(doc.Content))
+	}
+	if total > cfg.MaxTotalSize {
+		return &ValidationError{
+			Code:    CodeTotalTooLarge,
+			Message: fmt.Sprintf("total file size exceeds %s limit", formatSize(cfg.MaxTotalSize)),
+			Limit:   cfg.MaxTotalSize,
+		}
+	}
+	return nil
+}
+
+// ValidateDocuments checks the limits of a set of documents and that text can be extracted from each of them,
+// within the maximum text length of a knowledge base
+func ValidateDocuments(docs []Document) error {
+	if err := CheckLimits(docs); err != nil {
+		return err
+	}
+
+	cfg := GetConfig()
+	var length int
+	for _, doc := range docs {
+		text, _, err := Extract(doc.Name, doc.ContentType, doc.Content)
+		if err != nil {
+			return NewValidationError(doc.Name, err)
+		}
+		length += utf8.RuneCountInString(text)
+		if length > cfg.MaxTextLength {
+			return &ValidationError{
+				Code:    CodeTextTooLong,
+				File:    doc.Name,
+				Message: fmt.Sprintf("extracted text exceeds %d characters", cfg.MaxTextLength),
+				Limit:   int64(cfg.MaxTextLength),
+			}
+		}
+	}
+	return nil
+}
+
+// FileTooLarge returns the validation error of a file over its size limit
+func FileTooLarge(file string, limit int64) *ValidationError {
+	return &ValidationError{
+		Code:    CodeFileTooLarge,
+		File:    file,
+		Message: fmt.Sprintf("file size exceeds %s limit", formatSize(limit)),
+		Limit:   limit,
+	}
+}
+
+func formatSize(size int64) string {
+	if size >= 1<<20 && size%(1<<20) == 0 {
+		return fmt.Sprintf("%dMB", size>>20)
+	}
+	if size >= 1<<10 && size%(1<<10) == 0 {
+		return fmt.Sprintf("%dKB", size>>10)
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
@@ -0,0 +1,78 @@
+package knowledge
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCheckLimits(t *testing.T) {
+	cfg := GetConfig()
+	tests := []struct {
+		name string
+		docs []Document
+		code string
+	}{
+		{"ok", []Document{{Name: "a", Content: []byte("x")}}, ""},
+		{"no documents", nil, CodeNoFiles},
+		{"oversized file", []Document{{Name: "big.txt", Content: bytes.Repeat([]byte("a"), int(cfg.MaxFileSize)+1)}}, CodeFileTooLarge},
+		{"too many files", make([]Document, cfg.MaxFiles+1), CodeTooManyFiles},
+		{"executable", []Document{{Name: "a.txt", Content: peFile()}}, CodeExecutable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckLimits(tt.docs)
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("CheckLimits() error = %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || verr.Code != tt.code {
+				t.Errorf("CheckLimits() error = %v, want code %s", err, tt.code)
+			}
+		})
+	}
+
+	// Limits apply per format
+	pdf := minimalPDF("hello")
+	pdf = append(pdf, make([]byte, cfg.MaxFileSize)...)
+	if err := CheckLimits([]Document{{Name: "big.pdf", Content: pdf}}); err != nil {
+		t.Errorf("CheckLimits() error = %v for a PDF within its limit", err)
+	}
+}
+
+func TestValidateDocuments(t *testing.T) {
+	old := GetConfig()
+	defer InitWithConfig(old)
+	if err := InitWithConfig(&Config{MaxTextLength: 10}); err != nil {
+		t.Fatalf("InitWithConfig() error = %v", err)
+	}
+
+	if err := ValidateDocuments([]Document{{Name: "a.txt", Content: []byte("short")}}); err != nil {
+		t.Errorf("ValidateDocuments() error = %v", err)
+	}
+
+	var verr *ValidationError
+	err := ValidateDocuments([]Document{{Name: "a.txt", Content: []byte("first")}, {Name: "b.txt", Content: []byte("second")}})
+	if !errors.As(err, &verr) || verr.Code != CodeTextTooLong || verr.File != "b.txt" || verr.Limit != 10 {
+		t.Errorf("ValidateDocuments() error = %#v, want %s for b.txt", err, CodeTextTooLong)
+	}
+
+	err = ValidateDocuments([]Document{{Name: "empty.txt", Content: []byte("  ")}})
+	if !errors.As(err, &verr) || verr.Code != CodeEmptyDocument || !errors.Is(err, ErrInvalidDocument) {
+		t.Errorf("ValidateDocuments() error = %v, want %s", err, CodeEmptyDocument)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	cfg.MaxFileSizes = map[Format]int64{"exe": 1}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() accepted an unknown format")
+	}
+}
@@ -0,0 +1,100 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ManifestName is the name of the manifest in a knowledge directory
+const ManifestName = "manifest.json"
+
+// ManifestVersion is the version of the manifest format written by this package
+const ManifestVersion = 1
+
+// Manifest lists the documents of a multi-file knowledge base published as an IPFS directory
+type Manifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Files     []ManifestFile `json:"files"`
+}
+
+// ManifestFile describes one encrypted document of the directory
+type ManifestFile struct {
+	Name          string `json:"name"` // original file name
+	Path          string `json:"path"` // entry in the directory holding the encrypted document
+	Size          int64  `json:"size"` // plaintext size
+	EncryptedSize int64  `json:"encrypted_size"`
+	ContentType   string `json:"content_type"`
+}
+
+// Document is a decrypted document of a knowledge base
+type Document struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// FilePath returns the directory entry for the i-th document, unique whatever the original names
+func FilePath(i int, name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, name)
+	return fmt.Sprintf("%03d_%s.enc", i, strings.Trim(safe, "."))
+}
+
+// ParseManifest decodes and validates a manifest
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks the manifest can be used to reconstitute the corpus
+func (m *Manifest) Validate() error {
+	if m.Version != ManifestVersion {
+		return fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	if len(m.Files) == 0 {
+		return errors.New("manifest lists no files")
+	}
+	paths := make(map[string]bool, len(m.Files))
+	for i, f := range m.Files {
+		if f.Name == "" || f.Path == "" {
+			return fmt.Errorf("files[%d] needs a name and a path", i)
+		}
+		if f.Path == ManifestName || strings.ContainsAny(f.Path, "/\\") || paths[f.Path] {
+			return fmt.Errorf("files[%d] has an invalid path %q", i, f.Path)
+		}
+		paths[f.Path] = true
+	}
+	return nil
+}
+
+// Corpus joins the documents into the text handed to the model, each under a header naming it
+func Corpus(docs []Document) []byte {
+	if len(docs) == 1 {
+		return docs[0].Content
+	}
+
+	var b bytes.Buffer
+	for i, doc := range docs {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "=== %s ===\n", doc.Name)
+		b.Write(doc.Content)
+	}
+	return b.Bytes()
+}
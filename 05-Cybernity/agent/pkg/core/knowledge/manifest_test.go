@@ -0,0 +1,71 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	m := Manifest{
+		Version:   ManifestVersion,
+		CreatedAt: time.Unix(1700000000, 0).UTC(),
+		Files: []ManifestFile{
+			{Name: "notes.md", Path: FilePath(0, "notes.md"), Size: 10, EncryptedSize: 40, ContentType: "text/markdown"},
+			{Name: "../paper.pdf", Path: FilePath(1, "../paper.pdf"), Size: 20, EncryptedSize: 50, ContentType: "application/pdf"},
+		},
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	parsed, err := ParseManifest(data)
+	if err != nil {
+		t.Fatalf("ParseManifest() error = %v", err)
+	}
+	if len(parsed.Files) != 2 || parsed.Files[1].Path != "001__paper.pdf.enc" {
+		t.Errorf("ParseManifest() = %+v", parsed)
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []ManifestFile
+	}{
+		{"no files", nil},
+		{"missing path", []ManifestFile{{Name: "a"}}},
+		{"nested path", []ManifestFile{{Name: "a", Path: "x/a.enc"}}},
+		{"manifest path", []ManifestFile{{Name: "a", Path: ManifestName}}},
+		{"duplicate path", []ManifestFile{{Name: "a", Path: "a.enc"}, {Name: "b", Path: "a.enc"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manifest{Version: ManifestVersion, Files: tt.files}
+			if err := m.Validate(); err == nil {
+				t.Error("Validate() accepted an invalid manifest")
+			}
+		})
+	}
+
+	if err := (&Manifest{Version: 2, Files: []ManifestFile{{Name: "a", Path: "a.enc"}}}).Validate(); err == nil {
+		t.Error("Validate() accepted an unknown version")
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	single := Corpus([]Document{{Name: "a.txt", Content: []byte("only")}})
+	if string(single) != "only" {
+		t.Errorf("Corpus() of one document = %q, want it unchanged", single)
+	}
+
+	corpus := string(Corpus([]Document{
+		{Name: "a.txt", Content: []byte("first")},
+		{Name: "b.txt", Content: []byte("second")},
+	}))
+	if !strings.HasPrefix(corpus, "=== a.txt ===\nfirst") || !strings.Contains(corpus, "=== b.txt ===\nsecond") {
+		t.Errorf("Corpus() = %q", corpus)
+	}
+}
@@ -0,0 +1,77 @@
+package knowledge
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"rsc.io/pdf"
+)
+
+// extractPDF returns the text of every page, reassembling lines from positioned glyphs
+func extractPDF(content []byte) (text string, err error) {
+	// The PDF reader panics on malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("%w: malformed pdf: %v", ErrInvalidDocument, r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	var b strings.Builder
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		b.WriteString(pdfPageText(page.Content().Text))
+		b.WriteString("\n\n")
+	}
+	return b.String(), nil
+}
+
+// pdfPageText orders glyphs top to bottom and left to right, inserting spaces at gaps between glyphs
+func pdfPageText(glyphs []pdf.Text) string {
+	var b strings.Builder
+	for i, line := range pdfLines(glyphs) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for j, g := range line {
+			if j > 0 && g.X-(line[j-1].X+line[j-1].W) > 0.15*math.Max(g.FontSize, 1) {
+				b.WriteString(" ")
+			}
+			b.WriteString(g.S)
+		}
+	}
+	return b.String()
+}
+
+// pdfLines groups glyphs into lines, top to bottom, each sorted left to right. Baselines of a line
+// differ slightly with superscripts and rounding, so a glyph joins the line when it is within half
+// a font size of the line's first baseline.
+func pdfLines(glyphs []pdf.Text) [][]pdf.Text {
+	sorted := make([]pdf.Text, len(glyphs))
+	copy(sorted, glyphs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Y > sorted[j].Y })
+
+	var lines [][]pdf.Text
+	var baseline, tolerance float64
+	for _, g := range sorted {
+		if len(lines) == 0 || baseline-g.Y >= tolerance {
+			lines = append(lines, nil)
+			baseline, tolerance = g.Y, math.Max(g.FontSize, 1)/2
+		}
+		lines[len(lines)-1] = append(lines[len(lines)-1], g)
+	}
+	for _, line := range lines {
+		sort.SliceStable(line, func(i, j int) bool { return line[i].X < line[j].X })
+	}
+	return lines
+}
@@ -0,0 +1,66 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// magic is a signature at the start of a file
+type magic struct {
+	kind      string
+	signature []byte
+}
+
+var executables = []magic{
+	{"ELF executable", []byte("\x7fELF")},
+	{"Mach-O executable", []byte{0xfe, 0xed, 0xfa, 0xce}},
+	{"Mach-O executable", []byte{0xfe, 0xed, 0xfa, 0xcf}},
+	{"Mach-O executable", []byte{0xce, 0xfa, 0xed, 0xfe}},
+	{"Mach-O executable", []byte{0xcf, 0xfa, 0xed, 0xfe}},
+	{"Mach-O universal binary or Java class", []byte{0xca, 0xfe, 0xba, 0xbe}},
+	{"WebAssembly module", []byte("\x00asm")},
+	{"script", []byte("#!/")},
+}
+
+var archives = []magic{
+	{"gzip", []byte{0x1f, 0x8b}},
+	{"bzip2", []byte("BZh")},
+	{"xz", []byte("\xfd7zXZ\x00")},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{"7z", []byte("7z\xbc\xaf\x27\x1c")},
+	{"rar", []byte("Rar!\x1a\x07")},
+	{"cab", []byte("MSCF\x00\x00\x00\x00")},
+	{"zip", []byte("PK\x05\x06")},
+	{"zip", []byte("PK\x07\x08")},
+}
+
+// sniffUnsafe rejects executables and archives, whatever their name or declared content type
+func sniffUnsafe(content []byte) error {
+	for _, m := range executables {
+		if bytes.HasPrefix(content, m.signature) {
+			return fmt.Errorf("%w: %s", ErrExecutable, m.kind)
+		}
+	}
+	if isPE(content) {
+		return fmt.Errorf("%w: Windows executable", ErrExecutable)
+	}
+	for _, m := range archives {
+		if bytes.HasPrefix(content, m.signature) {
+			return fmt.Errorf("%w: %s", ErrArchive, m.kind)
+		}
+	}
+	if len(content) >= 262 && bytes.Equal(content[257:262], []byte("ustar")) {
+		return fmt.Errorf("%w: tar", ErrArchive)
+	}
+	return nil
+}
+
+// isPE reports whether content is a DOS header pointing to a PE header
+func isPE(content []byte) bool {
+	if !bytes.HasPrefix(content, []byte("MZ")) || len(content) < 0x40 {
+		return false
+	}
+	offset := int64(binary.LittleEndian.Uint32(content[0x3c:]))
+	return offset+4 <= int64(len(content)) && bytes.Equal(content[offset:offset+4], []byte("PE\x00\x00"))
+}
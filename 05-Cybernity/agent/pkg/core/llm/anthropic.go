@@ -0,0 +1,361 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// anthropicVersion is the version of the Messages API the client speaks
+const anthropicVersion = "2023-06-01"
+
+// minThinkingBudget is the least thinking budget the Messages API accepts
+const minThinkingBudget = 1024
+
+// anthropicClient implements the Client interface over the Anthropic Messages API
+type anthropicClient struct {
+	*transport
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float32           `json:"temperature,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Thinking      *Thinking          `json:"thinking,omitempty"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice    *anthropicChoice   `json:"tool_choice,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type anthropicChoice struct {
+	Type string `json:"type"`
+}
+
+// anthropicToolChoices maps tool choices to the Messages API's
+var anthropicToolChoices = map[string]string{
+	ToolChoiceAuto:     "auto",
+	ToolChoiceNone:     "none",
+	ToolChoiceRequired: "any",
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is a content block: text, thinking, tool use or tool result
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicEvent is an event of a streamed message
+type anthropicEvent struct {
+	Type    string             `json:"type"`
+	Message *anthropicResponse `json:"message"`
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		Thinking   string `json:"thinking"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicErrorStatus are the status codes of the error types, which is all an error event has
+var anthropicErrorStatus = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusUnauthorized,
+	"permission_error":      http.StatusForbidden,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"overloaded_error":      529,
+}
+
+// streamError returns the APIError of an error event, the server failing the stream once it started
+func (e *anthropicEvent) streamError() *APIError {
+	status, ok := anthropicErrorStatus[e.Error.Type]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	return &APIError{Class: statusClass(status), StatusCode: status, Type: e.Error.Type, Message: e.Error.Message}
+}
+
+// anthropicStopReasons maps stop reasons to the finish reasons of chat completions
+var anthropicStopReasons = map[string]string{
+	"end_turn":      "stop",
+	"stop_sequence": "stop",
+	"max_tokens":    "length",
+	"tool_use":      "tool_calls",
+	"refusal":       "content_filter",
+}
+
+// ChatCompletion sends a chat completion request as a message to the Messages API
+func (c *anthropicClient) ChatCompletion(ctx context.Context, request ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+
+	req, err := c.newMessageRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp anthropicResponse
+	if err := c.doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	message := Message{Role: "assistant"}
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			message.Content += block.Text
+		case "thinking":
+			message.ReasoningContent += block.Thinking
+		case "tool_use":
+			message.ToolCalls = append(message.ToolCalls, ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: FunctionCall{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+	return &ChatCompletionResponse{
+		ID:     resp.ID,
+		Object: "chat.completion",
+		Model:  resp.Model,
+		Choices: []Choice{{
+			Message:      message,
+			FinishReason: anthropicStopReasons[resp.StopReason],
+		}},
+		Usage: Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// ChatCompletionStream streams a message from the Messages API as chat completion chunks.
+// Tool calls are not streamed, call tools with ChatCompletion.
+func (c *anthropicClient) ChatCompletionStream(ctx context.Context, request ChatCompletionRequest) (<-chan StreamResponse, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	request.Stream = true
+	req, err := c.newMessageRequest(ctx, request)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+
+	// Retries cover getting the stream, not its content once the first event is read
+	resp, err := c.openStream(ctx, req)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+
+	streamChan := make(chan StreamResponse)
+	go func() {
+		defer func() {
+			resp.Body.Close()
+			c.release()
+			close(streamChan)
+		}()
+
+		var id, model string
+		send := func(response StreamResponse) bool {
+			response.ID, response.Object, response.Model = id, "chat.completion.chunk", model
+			select {
+			case streamChan <- response:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		err := readSSE(resp.Body, func(_ string, data []byte) bool {
+			var event anthropicEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				send(StreamResponse{Err: fmt.Errorf("%w: invalid stream event: %w", ErrServer, err)})
+				return false
+			}
+
+			var choice StreamChoice
+			switch event.Type {
+			case "message_start":
+				if event.Message != nil {
+					id, model = event.Message.ID, event.Message.Model
+				}
+				choice.Delta.Role = "assistant"
+			case "content_block_delta":
+				choice.Delta.Content = event.Delta.Text
+				choice.Delta.ReasoningContent = event.Delta.Thinking
+				if choice.Delta.Content == "" && choice.Delta.ReasoningContent == "" {
+					return true
+				}
+			case "message_delta":
+				choice.FinishReason = anthropicStopReasons[event.Delta.StopReason]
+			case "message_stop":
+				return false
+			case "error":
+				send(StreamResponse{Err: event.streamError()})
+				return false
+			default:
+				return true
+			}
+			return send(StreamResponse{Choices: []StreamChoice{choice}})
+		})
+		if err != nil && ctx.Err() == nil {
+			send(StreamResponse{Err: transportError(err)})
+		}
+	}()
+
+	return streamChan, nil
+}
+
+// Embeddings fails, the Messages API offers no embeddings: configure a dedicated embedding client
+func (c *anthropicClient) Embeddings(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	return nil, fmt.Errorf("provider %s offers no embeddings: %w", ProviderAnthropic, ErrInvalidRequest)
+}
+
+// newMessageRequest converts a chat completion request to a Messages API request
+func (c *anthropicClient) newMessageRequest(ctx context.Context, request ChatCompletionRequest) (*http.Request, error) {
+	if len(request.Functions) > 0 {
+		return nil, fmt.Errorf("provider %s does not support functions: %w", ProviderAnthropic, ErrInvalidRequest)
+	}
+
+	message := anthropicRequest{
+		Model:         request.Model,
+		MaxTokens:     request.MaxTokens,
+		StopSequences: request.Stop,
+		Stream:        request.Stream,
+	}
+	if message.Model == "" {
+		message.Model = c.config.Model
+	}
+	// The Messages API requires a limit
+	if message.MaxTokens == 0 {
+		message.MaxTokens = c.config.MaxOutputTokens
+	}
+
+	// System messages go to the system prompt, the others become content blocks
+	var system []string
+	for _, m := range request.Messages {
+		switch {
+		case m.Role == "system":
+			system = append(system, m.Content)
+		case m.Role == "tool":
+			// Results of the calls of an assistant message all go in the next user message
+			result := anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content}
+			if last := len(message.Messages) - 1; last >= 0 && message.Messages[last].Role == "user" &&
+				message.Messages[last].Content[0].Type == "tool_result" {
+				message.Messages[last].Content = append(message.Messages[last].Content, result)
+				continue
+			}
+			message.Messages = append(message.Messages, anthropicMessage{Role: "user", Content: []anthropicBlock{result}})
+		default:
+			var blocks []anthropicBlock
+			// Text blocks must not be empty, an assistant may only call tools
+			if m.Content != "" || len(m.ToolCalls) == 0 {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+			message.Messages = append(message.Messages, anthropicMessage{Role: m.Role, Content: blocks})
+		}
+	}
+	// The Messages API has no response format, ask for it in the system prompt and validate the reply
+	if instruction := request.ResponseFormat.Instruction(); instruction != "" {
+		system = append(system, instruction)
+	}
+	message.System = strings.Join(system, "\n\n")
+
+	for _, tool := range request.Tools {
+		schema := tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		message.Tools = append(message.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	if choice, ok := anthropicToolChoices[request.ToolChoice]; ok && len(message.Tools) > 0 {
+		message.ToolChoice = &anthropicChoice{Type: choice}
+	}
+
+	switch {
+	case request.Thinking == nil || request.Thinking.Type == ThinkingAuto:
+		// The model's default
+	case request.Thinking.Type == ThinkingEnabled:
+		budget := max(request.Thinking.BudgetTokens, minThinkingBudget)
+		message.Thinking = &Thinking{Type: ThinkingEnabled, BudgetTokens: budget}
+		// Thinking counts against max tokens, keep the room asked for the answer
+		if message.MaxTokens <= budget {
+			message.MaxTokens += budget
+		}
+	default:
+		message.Thinking = &Thinking{Type: request.Thinking.Type}
+	}
+	// Temperatures run up to 1, and can't be set while thinking
+	if request.Temperature != nil && (message.Thinking == nil || message.Thinking.Type != ThinkingEnabled) {
+		message.Temperature = Temperature(min(*request.Temperature, 1))
+	}
+
+	req, err := c.newRequest(ctx, "/messages", message)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", c.config.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	if request.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	return req, nil
+}
@@ -0,0 +1,320 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newAnthropicStub serves the Messages API with handle, checking the headers of each request
+func newAnthropicStub(t *testing.T, handle func(w http.ResponseWriter, req anthropicRequest)) Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != anthropicVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+			return
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		handle(w, req)
+	}))
+	t.Cleanup(server.Close)
+
+	config := (&Config{Provider: ProviderAnthropic, APIKey: "test-key", BaseURL: server.URL, Model: "claude-sonnet-4-5"}).
+		MergeDefault().
+		WithRetryDelay(time.Millisecond, 5*time.Millisecond)
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	return NewClient(config)
+}
+
+func TestAnthropicChatCompletion(t *testing.T) {
+	var got anthropicRequest
+	client := newAnthropicStub(t, func(w http.ResponseWriter, req anthropicRequest) {
+		got = req
+		fmt.Fprint(w, `{
+			"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5",
+			"content": [
+				{"type": "thinking", "thinking": "The user greets.", "signature": "sig"},
+				{"type": "text", "text": "Hello"},
+				{"type": "text", "text": "!"}
+			],
+			"stop_reason": "end_turn",
+			"usage": {"input_tokens": 12, "output_tokens": 3}
+		}`)
+	})
+
+	resp, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Hi"},
+		},
+		MaxTokens:   100,
+		Temperature: Temperature(1.5),
+		Stop:        []string{"END"},
+		Thinking:    &Thinking{Type: ThinkingEnabled},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	if got.Model != "claude-sonnet-4-5" || got.System != "Be brief." || len(got.StopSequences) != 1 {
+		t.Errorf("request = %+v, want the configured model, system prompt and stop sequences", got)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Role != "user" || got.Messages[0].Content[0].Type != "text" || got.Messages[0].Content[0].Text != "Hi" {
+		t.Errorf("request messages = %+v, want the user message as a text block", got.Messages)
+	}
+	if got.Thinking == nil || got.Thinking.BudgetTokens != minThinkingBudget || got.MaxTokens != 100+minThinkingBudget || got.Temperature != nil {
+		t.Errorf("request thinking = %+v, max tokens = %d, temperature = %v, want the least budget on top of the answer", got.Thinking, got.MaxTokens, got.Temperature)
+	}
+
+	message := resp.Choices[0].Message
+	if message.Role != "assistant" || message.Content != "Hello!" || message.ReasoningContent != "The user greets." {
+		t.Errorf("message = %+v", message)
+	}
+	if resp.Model != "claude-sonnet-4-5" || resp.Choices[0].FinishReason != "stop" || resp.Usage.TotalTokens != 15 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestAnthropicRequestDefaults(t *testing.T) {
+	var got anthropicRequest
+	client := newAnthropicStub(t, func(w http.ResponseWriter, req anthropicRequest) {
+		got = req
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}],"stop_reason":"max_tokens"}`)
+	})
+
+	resp, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Messages:    []Message{{Role: "user", Content: "Hi"}},
+		Temperature: Temperature(1.5),
+		Thinking:    &Thinking{Type: ThinkingDisabled},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if got.MaxTokens != DefaultConfig().MaxOutputTokens || got.Temperature == nil || *got.Temperature != 1 {
+		t.Errorf("request max tokens = %d, temperature = %v, want the configured limit and temperature capped at 1", got.MaxTokens, got.Temperature)
+	}
+	if got.Thinking == nil || got.Thinking.Type != ThinkingDisabled {
+		t.Errorf("request thinking = %+v, want disabled", got.Thinking)
+	}
+	if resp.Choices[0].FinishReason != "length" {
+		t.Errorf("finish reason = %s, want length", resp.Choices[0].FinishReason)
+	}
+
+	if _, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{Thinking: &Thinking{Type: ThinkingAuto}}); err != nil || got.Thinking != nil {
+		t.Errorf("ChatCompletion(auto) error = %v, thinking = %+v, want the model's default", err, got.Thinking)
+	}
+	if _, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{Functions: []Function{{Name: "f"}}}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("ChatCompletion(functions) error = %v, want ErrInvalidRequest", err)
+	}
+	if _, err := client.Embeddings(context.Background(), EmbeddingRequest{Input: []string{"x"}}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Embeddings() error = %v, want ErrInvalidRequest", err)
+	}
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	var requests atomic.Int32
+	client := newAnthropicStub(t, func(w http.ResponseWriter, req anthropicRequest) {
+		switch req.Messages[0].Content[0].Text {
+		case "overloaded":
+			if requests.Add(1) == 1 {
+				w.WriteHeader(529)
+				fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+				return
+			}
+			fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+		case "long":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`)
+		}
+	})
+	ask := func(content string) error {
+		_, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{Messages: []Message{{Role: "user", Content: content}}})
+		return err
+	}
+
+	if err := ask("overloaded"); err != nil || requests.Load() != 2 {
+		t.Errorf("ChatCompletion(overloaded) error = %v after %d requests, want a retry", err, requests.Load())
+	}
+	if err := ask("long"); !errors.Is(err, ErrContextOverflow) || !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("ChatCompletion(long) error = %v, want ErrContextOverflow", err)
+	}
+
+	unauthorized := NewClient(client.GetConfig().Clone().WithAPIKey("wrong"))
+	if _, err := unauthorized.ChatCompletion(context.Background(), ChatCompletionRequest{}); !errors.Is(err, ErrAuth) {
+		t.Errorf("ChatCompletion(wrong key) error = %v, want ErrAuth", err)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	client := newAnthropicStub(t, func(w http.ResponseWriter, req anthropicRequest) {
+		if !req.Stream {
+			t.Error("request is not streamed")
+		}
+		for _, event := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5","content":[]}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Hmm."}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hel"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"lo"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+			`{"type":"message_stop"}`,
+		} {
+			var e struct{ Type string }
+			json.Unmarshal([]byte(event), &e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, event)
+		}
+	})
+
+	stream, err := client.ChatCompletionStream(context.Background(), ChatCompletionRequest{Messages: []Message{{Role: "user", Content: "Hi"}}})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	var content, reasoning, finish string
+	chunks := 0
+	for chunk := range stream {
+		chunks++
+		if chunk.ID != "msg_1" || chunk.Model != "claude-sonnet-4-5" {
+			t.Errorf("chunk = %+v, want the message id and model", chunk)
+		}
+		content += chunk.Choices[0].Delta.Content
+		reasoning += chunk.Choices[0].Delta.ReasoningContent
+		if chunk.Choices[0].FinishReason != "" {
+			finish = chunk.Choices[0].FinishReason
+		}
+	}
+	if content != "Hello" || reasoning != "Hmm." || finish != "stop" || chunks != 5 {
+		t.Errorf("streamed %q thinking %q finish %q in %d chunks", content, reasoning, finish, chunks)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	client := newAnthropicStub(t, func(w http.ResponseWriter, req anthropicRequest) {
+		for _, event := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4-5","content":[]}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+			`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+		} {
+			var e struct{ Type string }
+			json.Unmarshal([]byte(event), &e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, event)
+		}
+	})
+
+	stream, err := client.ChatCompletionStream(context.Background(), ChatCompletionRequest{Messages: []Message{{Role: "user", Content: "Hi"}}})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	var content string
+	var streamErr error
+	for chunk := range stream {
+		if streamErr != nil {
+			t.Fatalf("chunk %+v after the error", chunk)
+		}
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+			continue
+		}
+		content += chunk.Choices[0].Delta.Content
+	}
+	var apiErr *APIError
+	if !errors.As(streamErr, &apiErr) || apiErr.Type != "overloaded_error" || !errors.Is(streamErr, ErrServer) {
+		t.Fatalf("stream error = %v, want the overloaded APIError", streamErr)
+	}
+	if content != "Hel" {
+		t.Errorf("streamed %q before the error, want %q", content, "Hel")
+	}
+}
+
+func TestProviderConfig(t *testing.T) {
+	config := (&Config{Provider: ProviderAnthropic, APIKey: "key"}).MergeDefault()
+	if config.BaseURL != "https://api.anthropic.com/v1" || config.Model != "" {
+		t.Errorf("MergeDefault() = %+v, want the Anthropic endpoint and no OpenAI model", config)
+	}
+	if err := config.Validate(); err == nil {
+		t.Error("Validate() accepted an Anthropic client without a model")
+	}
+	if err := (&Config{Provider: "unknown", APIKey: "key"}).MergeDefault().Validate(); err == nil {
+		t.Error("Validate() accepted an unknown provider")
+	}
+	if _, ok := NewClient(config.WithModel("claude-sonnet-4-5")).(*anthropicClient); !ok {
+		t.Error("NewClient() did not create an Anthropic client")
+	}
+	if budget := config.Budget(""); budget.ContextLength != 200000 {
+		t.Errorf("Budget() context length = %d, want Claude's", budget.ContextLength)
+	}
+}
+
+func TestAnthropicTools(t *testing.T) {
+	var got anthropicRequest
+	client := newAnthropicStub(t, func(w http.ResponseWriter, req anthropicRequest) {
+		got = req
+		fmt.Fprint(w, `{"content":[
+			{"type":"text","text":"Let me compute."},
+			{"type":"tool_use","id":"toolu_2","name":"calculator","input":{"expression":"6*7"}}
+		],"stop_reason":"tool_use"}`)
+	})
+
+	calculator := NewFunctionTool("calculator", "Evaluates arithmetic", map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"expression": map[string]any{"type": "string"}},
+	})
+	resp, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Messages: []Message{
+			{Role: "user", Content: "What is 2+2 and 6*7?"},
+			{Role: "assistant", ToolCalls: []ToolCall{
+				{ID: "toolu_0", Type: "function", Function: FunctionCall{Name: "calculator", Arguments: `{"expression":"2+2"}`}},
+				{ID: "toolu_1", Type: "function", Function: FunctionCall{Name: "calculator"}},
+			}},
+			{Role: "tool", ToolCallID: "toolu_0", Content: "4"},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "missing expression"},
+		},
+		Tools:      []Tool{calculator},
+		ToolChoice: ToolChoiceRequired,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	if len(got.Tools) != 1 || got.Tools[0].Name != "calculator" || got.ToolChoice == nil || got.ToolChoice.Type != "any" {
+		t.Errorf("request tools = %+v, choice = %+v", got.Tools, got.ToolChoice)
+	}
+	if len(got.Messages) != 3 {
+		t.Fatalf("request messages = %+v, want the tool results in one user message", got.Messages)
+	}
+	calls := got.Messages[1].Content
+	if len(calls) != 2 || calls[0].Type != "tool_use" || string(calls[0].Input) != `{"expression":"2+2"}` || string(calls[1].Input) != "{}" {
+		t.Errorf("assistant blocks = %+v, want only the tool uses", calls)
+	}
+	results := got.Messages[2].Content
+	if got.Messages[2].Role != "user" || len(results) != 2 || results[0].ToolUseID != "toolu_0" || results[1].Content != "missing expression" {
+		t.Errorf("tool results = %+v", got.Messages[2])
+	}
+
+	message := resp.Choices[0].Message
+	if message.Content != "Let me compute." || resp.Choices[0].FinishReason != "tool_calls" || len(message.ToolCalls) != 1 {
+		t.Fatalf("message = %+v", message)
+	}
+	if call := message.ToolCalls[0]; call.ID != "toolu_2" || call.Function.Name != "calculator" || call.Function.Arguments != `{"expression":"6*7"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+}
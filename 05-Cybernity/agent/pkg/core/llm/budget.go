@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrContextOverflow is returned when a prompt leaves no room for an answer in the model's context window
+var ErrContextOverflow = errors.New("prompt does not fit in the model's context window")
+
+const (
+	// Chat formats wrap every message in a few tokens and prime the reply with a few more
+	tokensPerMessage = 4
+	tokensPerReply   = 3
+)
+
+// ModelLimits are the token limits of a model
+type ModelLimits struct {
+	ContextLength int `json:"context_length" yaml:"context_length"`
+	// MaxOutputTokens is the room reserved for the answer
+	MaxOutputTokens int `json:"max_output_tokens" yaml:"max_output_tokens"`
+}
+
+// knownLimits are the context lengths of common models, matched by prefix, the longest first
+var knownLimits = []struct {
+	prefix string
+	limits ModelLimits
+}{
+	{"gpt-4o-mini", ModelLimits{ContextLength: 128000}},
+	{"gpt-4o", ModelLimits{ContextLength: 128000}},
+	{"gpt-4.1", ModelLimits{ContextLength: 1047576}},
+	{"gpt-4-turbo", ModelLimits{ContextLength: 128000}},
+	{"gpt-4-32k", ModelLimits{ContextLength: 32768}},
+	{"gpt-4", ModelLimits{ContextLength: 8192}},
+	{"gpt-3.5-turbo", ModelLimits{ContextLength: 16385}},
+	{"deepseek", ModelLimits{ContextLength: 65536}},
+	{"claude", ModelLimits{ContextLength: 200000}},
+}
+
+// Budget splits a model's context window between the prompt and the answer
+type Budget struct {
+	ModelLimits
+	// MinOutputTokens is the least room for an answer worth sending the request for
+	MinOutputTokens int
+	Tokenizer       Tokenizer
+}
+
+// Budget returns the token budget of a model, from the configured limits of the model,
+// the known limits of common models or the client's limits, in that order
+func (c *Config) Budget(model string) *Budget {
+	if model == "" {
+		model = c.Model
+	}
+	limits := ModelLimits{ContextLength: c.ContextLength, MaxOutputTokens: c.MaxOutputTokens}
+	if l, ok := c.ModelLimits[model]; ok {
+		limits = mergeLimits(l, limits)
+	} else {
+		for _, k := range knownLimits {
+			if strings.HasPrefix(model, k.prefix) {
+				limits = mergeLimits(k.limits, limits)
+				break
+			}
+		}
+	}
+	// Never reserve more than half the window for the answer
+	limits.MaxOutputTokens = min(limits.MaxOutputTokens, limits.ContextLength/2)
+
+	return &Budget{
+		ModelLimits:     limits,
+		MinOutputTokens: min(c.MinOutputTokens, limits.MaxOutputTokens),
+		Tokenizer:       TokenizerFor(model),
+	}
+}
+
+func mergeLimits(l, def ModelLimits) ModelLimits {
+	if l.ContextLength == 0 {
+		l.ContextLength = def.ContextLength
+	}
+	if l.MaxOutputTokens == 0 {
+		l.MaxOutputTokens = def.MaxOutputTokens
+	}
+	return l
+}
+
+// Count returns the tokens of text
+func (b *Budget) Count(text string) int {
+	return b.Tokenizer.Count(text)
+}
+
+// CountMessages returns the tokens of a chat prompt
+func (b *Budget) CountMessages(messages []Message) int {
+	tokens := tokensPerReply
+	for _, m := range messages {
+		tokens += tokensPerMessage + b.Count(m.Role) + b.Count(m.Content)
+	}
+	return tokens
+}
+
+// PromptTokens returns the room for the prompt when the answer gets all its reserved tokens
+func (b *Budget) PromptTokens() int {
+	return b.ContextLength - b.MaxOutputTokens
+}
+
+// MaxTokens returns the room left for the answer after a prompt of promptTokens, up to MaxOutputTokens,
+// or ErrContextOverflow when it is below MinOutputTokens
+func (b *Budget) MaxTokens(promptTokens int) (int, error) {
+	room := b.ContextLength - promptTokens
+	if room < max(b.MinOutputTokens, 1) {
+		return 0, ErrContextOverflow
+	}
+	return min(room, b.MaxOutputTokens), nil
+}
+
+// Truncate cuts text to at most tokens, at a line or word boundary when one is close
+func (b *Budget) Truncate(text string, tokens int) string {
+	if tokens <= 0 {
+		return ""
+	}
+	if b.Count(text) <= tokens {
+		return text
+	}
+
+	// Binary search the longest prefix that fits
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if b.Count(string(runes[:mid])) <= tokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	cut := string(runes[:lo])
+	if i := strings.LastIndexAny(cut, "\n "); i > len(cut)*3/4 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut)
+}
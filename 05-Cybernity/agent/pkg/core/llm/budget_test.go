@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokenizer(t *testing.T) {
+	tests := []struct {
+		text     string
+		min, max int
+	}{
+		{"", 0, 0},
+		{"Hello, world!", 4, 5},
+		{"The quick brown fox jumps over the lazy dog.", 10, 14},
+		{"1234567", 3, 3},
+		{"你好，世界", 5, 7},
+		{"Привет мир", 4, 8},
+	}
+	for _, tt := range tests {
+		if got := (EstimateTokenizer{}).Count(tt.text); got < tt.min || got > tt.max {
+			t.Errorf("Count(%q) = %d, want between %d and %d", tt.text, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestTokenizerFor(t *testing.T) {
+	tests := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"gpt-4o-mini", "tiktoken is great!", 6},
+		{"gpt-4", "tiktoken is great!", 6},
+		{"gpt-3.5-turbo", "Hello, world!", 4},
+		{"gpt-4o", "", 0},
+	}
+	for _, tt := range tests {
+		tokenizer := TokenizerFor(tt.model)
+		if _, ok := tokenizer.(*BPETokenizer); !ok {
+			t.Fatalf("TokenizerFor(%q) = %T, want *BPETokenizer", tt.model, tokenizer)
+		}
+		if got := tokenizer.Count(tt.text); got != tt.want {
+			t.Errorf("TokenizerFor(%q).Count(%q) = %d, want %d", tt.model, tt.text, got, tt.want)
+		}
+	}
+
+	for _, model := range []string{"claude-3-5-sonnet", "deepseek-chat", "llama3"} {
+		if _, ok := TokenizerFor(model).(EstimateTokenizer); !ok {
+			t.Errorf("TokenizerFor(%q) = %T, want EstimateTokenizer", model, TokenizerFor(model))
+		}
+	}
+}
+
+func TestModelFor(t *testing.T) {
+	config := DefaultConfig().WithModel("gpt-4o-mini")
+	config.Models = []string{"gpt-4o"}
+	for model, want := range map[string]string{"gpt-4o-mini": "gpt-4o-mini", "gpt-4o": "gpt-4o", "llama3": "", "": ""} {
+		if got := config.ModelFor(model); got != want {
+			t.Errorf("ModelFor(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	config := DefaultConfig()
+	config.ModelLimits = map[string]ModelLimits{"custom": {ContextLength: 1000}}
+
+	tests := []struct {
+		model   string
+		context int
+		output  int
+	}{
+		{"gpt-4o-2024-08-06", 128000, 1024},
+		{"gpt-3.5-turbo", 16385, 1024},
+		{"custom", 1000, 500},
+		{"unknown", 8192, 1024},
+	}
+	for _, tt := range tests {
+		b := config.Budget(tt.model)
+		if b.ContextLength != tt.context || b.MaxOutputTokens != tt.output {
+			t.Errorf("Budget(%s) = %+v, want context %d and output %d", tt.model, b.ModelLimits, tt.context, tt.output)
+		}
+	}
+
+	b := config.Budget("custom")
+	if got, err := b.MaxTokens(100); err != nil || got != 500 {
+		t.Errorf("MaxTokens(100) = %d, %v, want 500", got, err)
+	}
+	if got, err := b.MaxTokens(700); err != nil || got != 300 {
+		t.Errorf("MaxTokens(700) = %d, %v, want 300", got, err)
+	}
+	if _, err := b.MaxTokens(900); !errors.Is(err, ErrContextOverflow) {
+		t.Errorf("MaxTokens(900) error = %v, want ErrContextOverflow", err)
+	}
+
+	text := strings.Repeat("word ", 200)
+	cut := b.Truncate(text, 50)
+	if n := b.Count(cut); n > 50 || n < 40 {
+		t.Errorf("Truncate() kept %d tokens, want close to 50", n)
+	}
+	if !strings.HasPrefix(text, cut) {
+		t.Error("Truncate() did not keep a prefix")
+	}
+}
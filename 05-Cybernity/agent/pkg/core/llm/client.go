@@ -1,13 +1,12 @@
 package llm
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"sync"
 )
 
 // Client interface defines the methods that an LLM client must implement
@@ -15,60 +14,46 @@ type Client interface {
 	GetConfig() *Config
 	ChatCompletion(ctx context.Context, request ChatCompletionRequest) (*ChatCompletionResponse, error)
 	ChatCompletionStream(ctx context.Context, request ChatCompletionRequest) (<-chan StreamResponse, error)
+	Embeddings(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error)
 }
 
-// client implements the Client interface
+// client implements the Client interface over the OpenAI chat completions API
 type client struct {
-	config     *Config
-	httpClient *http.Client
-	semaphore  chan struct{}
+	*transport
 }
 
-// NewClient creates a new LLM client with the given configuration
+// NewClient creates a new LLM client for the provider of the given configuration
 func NewClient(config *Config) Client {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
-	return &client{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: config.Timeout,
-		},
-		semaphore: make(chan struct{}, config.MaxConcurrentCalls),
+	switch config.Provider {
+	case ProviderAnthropic:
+		return &anthropicClient{newTransport(config)}
+	case ProviderOllama:
+		return &ollamaClient{newTransport(config)}
+	case ProviderLlamaCpp:
+		return &llamaCppClient{&client{newTransport(config)}}
 	}
-}
-
-func (c *client) GetConfig() *Config {
-	return c.config
+	return &client{newTransport(config)}
 }
 
 // ChatCompletion sends a chat completion request to LLM
 func (c *client) ChatCompletion(ctx context.Context, request ChatCompletionRequest) (*ChatCompletionResponse, error) {
-	// Acquire semaphore
-	select {
-	case c.semaphore <- struct{}{}:
-		defer func() { <-c.semaphore }()
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
 	}
+	defer c.release()
 
 	if request.Model == "" {
 		request.Model = c.config.Model
 	}
 
-	jsonBody, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST",
-		c.config.BaseURL+"/chat/completions",
-		bytes.NewBuffer(jsonBody))
+	req, err := c.newRequest(ctx, "/chat/completions", request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
-
 	c.setHeaders(req)
 
 	var resp *ChatCompletionResponse
@@ -82,11 +67,8 @@ func (c *client) ChatCompletion(ctx context.Context, request ChatCompletionReque
 
 // ChatCompletionStream sends a streaming chat completion request to LLM
 func (c *client) ChatCompletionStream(ctx context.Context, request ChatCompletionRequest) (<-chan StreamResponse, error) {
-	// Acquire semaphore
-	select {
-	case c.semaphore <- struct{}{}:
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
 	}
 
 	request.Stream = true
@@ -94,139 +76,133 @@ func (c *client) ChatCompletionStream(ctx context.Context, request ChatCompletio
 		request.Model = c.config.Model
 	}
 
-	jsonBody, err := json.Marshal(request)
-	if err != nil {
-		<-c.semaphore
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST",
-		c.config.BaseURL+"/chat/completions",
-		bytes.NewBuffer(jsonBody))
+	req, err := c.newRequest(ctx, "/chat/completions", request)
 	if err != nil {
-		<-c.semaphore
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		c.release()
+		return nil, err
 	}
-
 	c.setHeaders(req)
 
-	resp, err := c.httpClient.Do(req)
+	// Retries cover getting the stream, not its content once the first chunk is read
+	resp, err := c.openStream(ctx, req)
 	if err != nil {
-		<-c.semaphore
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		<-c.semaphore
-		resp.Body.Close()
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		c.release()
+		return nil, err
 	}
 
 	streamChan := make(chan StreamResponse)
 	go func() {
 		defer func() {
 			resp.Body.Close()
-			<-c.semaphore
+			c.release()
 			close(streamChan)
 		}()
 
-		reader := bufio.NewReader(resp.Body)
-		for {
-			line, err := reader.ReadBytes('\n')
-			if err != nil {
-				if err != io.EOF {
-					// Log error if needed
-				}
-				return
-			}
-
-			line = bytes.TrimSpace(line)
-			if len(line) == 0 {
-				continue
-			}
-
-			if !bytes.HasPrefix(line, []byte("data: ")) {
-				continue
-			}
-
-			line = bytes.TrimPrefix(line, []byte("data: "))
-			if bytes.Equal(line, []byte("[DONE]")) {
-				return
+		readSSE(resp.Body, func(_ string, data []byte) bool {
+			if bytes.Equal(data, []byte("[DONE]")) {
+				return false
 			}
 
 			var response StreamResponse
-			if err := json.Unmarshal(line, &response); err != nil {
+			if err := json.Unmarshal(data, &response); err != nil {
 				// Log error if needed
-				continue
+				return true
 			}
 
 			select {
 			case streamChan <- response:
+				return true
 			case <-ctx.Done():
-				return
+				return false
 			}
-		}
+		})
 	}()
 
 	return streamChan, nil
 }
 
-// setHeaders sets the required headers for LLM API requests
-func (c *client) setHeaders(req *http.Request) {
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
-	if c.config.OrgID != "" {
-		req.Header.Set("LLM-Organization", c.config.OrgID)
+// Embeddings embeds the inputs in batches of EmbeddingBatchSize, sent concurrently within the client's limit
+func (c *client) Embeddings(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	if len(request.Input) == 0 {
+		return nil, fmt.Errorf("no input to embed")
+	}
+	if request.Model == "" {
+		request.Model = c.config.EmbeddingModel
 	}
-}
 
-// doRequest performs the HTTP request with retries
-func (c *client) doRequest(req *http.Request, v interface{}) error {
-	var lastErr error
-	// 保存原始请求体
-	var originalBody []byte
-	if req.Body != nil {
-		originalBody, _ = io.ReadAll(req.Body)
-		req.Body.Close()
-	}
-
-	for i := 0; i <= c.config.MaxRetries; i++ {
-		// 为每次重试创建新的请求体
-		if originalBody != nil {
-			req.Body = io.NopCloser(bytes.NewBuffer(originalBody))
-			req.ContentLength = int64(len(originalBody))
-		}
+	batchSize := c.config.EmbeddingBatchSize
+	if batchSize <= 0 {
+		batchSize = len(request.Input)
+	}
+	var batches []EmbeddingRequest
+	for start := 0; start < len(request.Input); start += batchSize {
+		batch := request
+		batch.Input = request.Input[start:min(start+batchSize, len(request.Input))]
+		batches = append(batches, batch)
+	}
 
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to send request: %w", err)
-			continue
-		}
+	responses := make([]*EmbeddingResponse, len(batches))
+	errs := make([]error, len(batches))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch EmbeddingRequest) {
+			defer wg.Done()
+			responses[i], errs[i] = c.embed(ctx, batch)
+		}(i, batch)
+	}
+	wg.Wait()
 
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to read response body: %w", err)
-			continue
+	result := &EmbeddingResponse{
+		Object: "list",
+		Data:   make([]Embedding, len(request.Input)),
+	}
+	for i, resp := range responses {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			var llmErr Error
-			if err := json.Unmarshal(body, &llmErr); err != nil {
-				lastErr = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-			} else {
-				lastErr = fmt.Errorf("LLM API error: %s", llmErr.Error.Message)
+		offset := i * batchSize
+		if len(resp.Data) != len(batches[i].Input) {
+			return nil, fmt.Errorf("got %d embeddings for %d inputs", len(resp.Data), len(batches[i].Input))
+		}
+		for _, e := range resp.Data {
+			if e.Index < 0 || e.Index >= len(batches[i].Input) {
+				return nil, fmt.Errorf("embedding index %d out of range", e.Index)
 			}
-			continue
+			e.Index += offset
+			result.Data[e.Index] = e
 		}
+		result.Model = resp.Model
+		result.Usage.PromptTokens += resp.Usage.PromptTokens
+		result.Usage.TotalTokens += resp.Usage.TotalTokens
+	}
+	return result, nil
+}
 
-		if err := json.Unmarshal(body, v); err != nil {
-			lastErr = fmt.Errorf("failed to unmarshal response: %w", err)
-			continue
-		}
+// embed sends one embeddings request
+func (c *client) embed(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
 
-		return nil
+	req, err := c.newRequest(ctx, "/embeddings", request)
+	if err != nil {
+		return nil, err
 	}
+	c.setHeaders(req)
+
+	var resp *EmbeddingResponse
+	if err := c.doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
 
-	return lastErr
+// setHeaders sets the required headers for LLM API requests
+func (c *client) setHeaders(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	if c.config.OrgID != "" {
+		req.Header.Set("LLM-Organization", c.config.OrgID)
+	}
 }
@@ -2,6 +2,8 @@ package llm
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 )
 
@@ -13,14 +15,17 @@ var (
 // ClientManager manages LLM client instances
 type ClientManager struct {
 	clients map[string]Client
-	mu      sync.RWMutex
+	// embeddingClients are dedicated to embeddings, e.g. a provider without chat models
+	embeddingClients map[string]Client
+	mu               sync.RWMutex
 }
 
 // GetManager returns the singleton instance of ClientManager
 func GetManager() *ClientManager {
 	once.Do(func() {
 		defaultManager = &ClientManager{
-			clients: make(map[string]Client),
+			clients:          make(map[string]Client),
+			embeddingClients: make(map[string]Client),
 		}
 	})
 	return defaultManager
@@ -56,6 +61,36 @@ func (m *ClientManager) GetClient(key string) (Client, error) {
 	return client, nil
 }
 
+// RegisterEmbeddingClient registers a client dedicated to embeddings with the given key
+func (m *ClientManager) RegisterEmbeddingClient(key string, config *Config) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.embeddingClients[key]; exists {
+		return fmt.Errorf("embedding client with key %s already exists", key)
+	}
+
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	m.embeddingClients[key] = NewClient(config)
+	return nil
+}
+
+// GetEmbeddingClient returns the embedding client for the given key,
+// falling back to the chat client with the same key
+func (m *ClientManager) GetEmbeddingClient(key string) (Client, error) {
+	m.mu.RLock()
+	client, exists := m.embeddingClients[key]
+	m.mu.RUnlock()
+	if exists {
+		return client, nil
+	}
+
+	return m.GetClient(key)
+}
+
 // MustGetClient returns the client instance for the given key
 // Panics if the client does not exist
 func (m *ClientManager) MustGetClient(key string) Client {
@@ -88,10 +123,19 @@ func (m *ClientManager) HasClient(key string) bool {
 	return exists
 }
 
+// Keys returns the keys of the registered clients, sorted
+func (m *ClientManager) Keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(m.clients))
+}
+
 // Clear removes all registered clients
 func (m *ClientManager) Clear() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.clients = make(map[string]Client)
+	m.embeddingClients = make(map[string]Client)
 }
@@ -2,6 +2,10 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -35,7 +39,7 @@ func TestChatCompletion(t *testing.T) {
 						Content: "Say 'Hello, World!' in Chinese",
 					},
 				},
-				Temperature: 0.7,
+				Temperature: Temperature(0.7),
 			},
 			wantErr: false,
 		},
@@ -49,7 +53,7 @@ func TestChatCompletion(t *testing.T) {
 						Content: "What is 1+1?",
 					},
 				},
-				Temperature: 0,
+				Temperature: Temperature(0),
 			},
 			wantErr: false,
 		},
@@ -88,7 +92,7 @@ func TestChatCompletionStream(t *testing.T) {
 				Content: "Count from 1 to 5 slowly",
 			},
 		},
-		Temperature: 0.7,
+		Temperature: Temperature(0.7),
 		Stream:      true,
 	}
 
@@ -230,3 +234,76 @@ func TestConfigValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestEmbeddings(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		var req EmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Embed each input as its length, listed in reverse order
+		resp := EmbeddingResponse{Object: "list", Model: req.Model, Usage: Usage{PromptTokens: len(req.Input), TotalTokens: len(req.Input)}}
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, Embedding{Object: "embedding", Index: i, Embedding: []float32{float32(len(req.Input[i]))}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(DefaultConfig().
+		WithAPIKey("test-key").
+		WithBaseURL(server.URL).
+		WithEmbeddingBatchSize(2).
+		WithMaxConcurrentCalls(2))
+
+	inputs := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	resp, err := client.Embeddings(context.Background(), EmbeddingRequest{Input: inputs})
+	if err != nil {
+		t.Fatalf("Embeddings() error = %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Embeddings() sent %d requests, want 3", got)
+	}
+	if resp.Model != "text-embedding-3-small" || resp.Usage.TotalTokens != len(inputs) {
+		t.Errorf("Embeddings() model = %s, usage = %+v", resp.Model, resp.Usage)
+	}
+	for i, e := range resp.Data {
+		if e.Index != i || len(e.Embedding) != 1 || int(e.Embedding[0]) != len(inputs[i]) {
+			t.Errorf("Embeddings() data[%d] = %+v, want the embedding of %q", i, e, inputs[i])
+		}
+	}
+
+	if _, err := client.Embeddings(context.Background(), EmbeddingRequest{}); err == nil {
+		t.Error("Embeddings() accepted no input")
+	}
+}
+
+func TestEmbeddingClients(t *testing.T) {
+	manager := &ClientManager{clients: make(map[string]Client), embeddingClients: make(map[string]Client)}
+	chat := DefaultConfig().WithAPIKey("chat")
+	if err := manager.RegisterClient("default", chat); err != nil {
+		t.Fatalf("RegisterClient() error = %v", err)
+	}
+	if err := manager.RegisterEmbeddingClient("local", DefaultConfig().WithAPIKey("local")); err != nil {
+		t.Fatalf("RegisterEmbeddingClient() error = %v", err)
+	}
+
+	client, err := manager.GetEmbeddingClient("local")
+	if err != nil || client.GetConfig().APIKey != "local" {
+		t.Errorf("GetEmbeddingClient(local) = %v, %v, want the dedicated client", client, err)
+	}
+	client, err = manager.GetEmbeddingClient("default")
+	if err != nil || client.GetConfig() != chat {
+		t.Errorf("GetEmbeddingClient(default) = %v, %v, want the chat client", client, err)
+	}
+	if _, err := manager.GetEmbeddingClient("missing"); err == nil {
+		t.Error("GetEmbeddingClient(missing) returned a client")
+	}
+}
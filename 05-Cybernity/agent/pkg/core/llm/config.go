@@ -2,34 +2,92 @@ package llm
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 )
 
+// Providers of LLM APIs
+const (
+	ProviderOpenAI    = "openai" // and compatible APIs
+	ProviderAnthropic = "anthropic"
+	// Self-hosted inference servers, knowledge sent to them stays on our infrastructure
+	ProviderOllama   = "ollama"
+	ProviderLlamaCpp = "llamacpp"
+)
+
+// defaultBaseURLs are the API endpoints of the providers
+var defaultBaseURLs = map[string]string{
+	ProviderOpenAI:    "https://api.openai.com/v1",
+	ProviderAnthropic: "https://api.anthropic.com/v1",
+	ProviderOllama:    "http://127.0.0.1:11434",
+	ProviderLlamaCpp:  "http://127.0.0.1:8080/v1",
+}
+
+// IsLocal reports whether the provider is a self-hosted inference server
+func IsLocal(provider string) bool {
+	return provider == ProviderOllama || provider == ProviderLlamaCpp
+}
+
 // Config represents the configuration for LLM client
 type Config struct {
-	APIKey             string        `json:"api_key" yaml:"api_key"`
-	BaseURL            string        `json:"base_url" yaml:"base_url"`
-	OrgID              string        `json:"org_id" yaml:"org_id"`
-	Timeout            time.Duration `json:"timeout" yaml:"timeout"`
-	MaxRetries         int           `json:"max_retries" yaml:"max_retries"`
-	Model              string        `json:"model" yaml:"model"`
-	MaxConcurrentCalls int           `json:"max_concurrent_calls" yaml:"max_concurrent_calls"`
+	// Provider is the API the client speaks, openai by default
+	Provider   string        `json:"provider" yaml:"provider"`
+	APIKey     string        `json:"api_key" yaml:"api_key"`
+	BaseURL    string        `json:"base_url" yaml:"base_url"`
+	OrgID      string        `json:"org_id" yaml:"org_id"`
+	Timeout    time.Duration `json:"timeout" yaml:"timeout"`
+	MaxRetries int           `json:"max_retries" yaml:"max_retries"`
+	// RetryBaseDelay doubles with each retry up to RetryMaxDelay, unless the server asks for a wait with Retry-After
+	RetryBaseDelay time.Duration `json:"retry_base_delay" yaml:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `json:"retry_max_delay" yaml:"retry_max_delay"`
+	// CallTimeout bounds a call including its retries, Timeout bounds each attempt
+	CallTimeout time.Duration `json:"call_timeout" yaml:"call_timeout"`
+	Model       string        `json:"model" yaml:"model"`
+	// Models the client serves besides Model, agents that picked another one are answered with Model
+	Models             []string `json:"models" yaml:"models"`
+	MaxConcurrentCalls int      `json:"max_concurrent_calls" yaml:"max_concurrent_calls"`
+	EmbeddingModel     string   `json:"embedding_model" yaml:"embedding_model"`
+	// EmbeddingBatchSize is the maximum number of inputs sent in one embeddings request
+	EmbeddingBatchSize int `json:"embedding_batch_size" yaml:"embedding_batch_size"`
+	// ContextLength and MaxOutputTokens apply to models without known or configured limits
+	ContextLength   int `json:"context_length" yaml:"context_length"`
+	MaxOutputTokens int `json:"max_output_tokens" yaml:"max_output_tokens"`
+	// MinOutputTokens is the least room for an answer before a prompt is refused
+	MinOutputTokens int                    `json:"min_output_tokens" yaml:"min_output_tokens"`
+	ModelLimits     map[string]ModelLimits `json:"model_limits" yaml:"model_limits"`
 }
 
 // DefaultConfig returns a default configuration
 func DefaultConfig() *Config {
 	return &Config{
-		BaseURL:            "https://api.openai.com/v1",
+		Provider:           ProviderOpenAI,
+		BaseURL:            defaultBaseURLs[ProviderOpenAI],
 		Timeout:            30 * time.Second,
 		MaxRetries:         3,
+		RetryBaseDelay:     500 * time.Millisecond,
+		RetryMaxDelay:      20 * time.Second,
+		CallTimeout:        90 * time.Second,
 		Model:              "gpt-3.5-turbo",
 		MaxConcurrentCalls: 10,
+		EmbeddingModel:     "text-embedding-3-small",
+		EmbeddingBatchSize: 100,
+		ContextLength:      8192,
+		MaxOutputTokens:    1024,
+		MinOutputTokens:    256,
 	}
 }
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
-	if c.APIKey == "" {
+	if _, ok := defaultBaseURLs[c.Provider]; !ok {
+		return fmt.Errorf("unknown provider %s", c.Provider)
+	}
+	if c.Provider != ProviderOpenAI && c.Model == "" {
+		return fmt.Errorf("model is required for provider %s", c.Provider)
+	}
+	// Local servers usually run without a key
+	if c.APIKey == "" && !IsLocal(c.Provider) {
 		return fmt.Errorf("api key is required")
 	}
 	if c.BaseURL == "" {
@@ -41,17 +99,37 @@ func (c *Config) Validate() error {
 	if c.MaxRetries < 0 {
 		return fmt.Errorf("max retries must be greater than or equal to 0")
 	}
+	if c.RetryBaseDelay <= 0 || c.RetryMaxDelay < c.RetryBaseDelay {
+		return fmt.Errorf("retry base delay must be greater than 0 and at most the max delay")
+	}
+	if c.CallTimeout <= 0 {
+		return fmt.Errorf("call timeout must be greater than 0")
+	}
 	if c.MaxConcurrentCalls <= 0 {
 		return fmt.Errorf("max concurrent calls must be greater than 0")
 	}
+	if c.EmbeddingBatchSize <= 0 {
+		return fmt.Errorf("embedding batch size must be greater than 0")
+	}
+	if c.ContextLength <= 0 || c.MaxOutputTokens <= 0 || c.MinOutputTokens <= 0 {
+		return fmt.Errorf("context length and output tokens must be greater than 0")
+	}
+	for model, limits := range c.ModelLimits {
+		if limits.ContextLength < 0 || limits.MaxOutputTokens < 0 {
+			return fmt.Errorf("limits of model %s must not be negative", model)
+		}
+	}
 	return nil
 }
 
 // MergeDefault merges the default configuration with the current configuration
 func (c *Config) MergeDefault() *Config {
 	def := DefaultConfig()
+	if c.Provider == "" {
+		c.Provider = def.Provider
+	}
 	if c.BaseURL == "" {
-		c.BaseURL = def.BaseURL
+		c.BaseURL = defaultBaseURLs[c.Provider]
 	}
 	if c.Timeout == 0 {
 		c.Timeout = def.Timeout
@@ -59,26 +137,76 @@ func (c *Config) MergeDefault() *Config {
 	if c.MaxRetries == 0 {
 		c.MaxRetries = def.MaxRetries
 	}
-	if c.Model == "" {
+	if c.RetryBaseDelay == 0 {
+		c.RetryBaseDelay = def.RetryBaseDelay
+	}
+	if c.RetryMaxDelay == 0 {
+		c.RetryMaxDelay = max(def.RetryMaxDelay, c.RetryBaseDelay)
+	}
+	if c.CallTimeout == 0 {
+		c.CallTimeout = def.CallTimeout
+	}
+	// The default models are OpenAI's
+	if c.Model == "" && c.Provider == ProviderOpenAI {
 		c.Model = def.Model
 	}
 	if c.MaxConcurrentCalls == 0 {
 		c.MaxConcurrentCalls = def.MaxConcurrentCalls
 	}
+	if c.EmbeddingModel == "" && c.Provider == ProviderOpenAI {
+		c.EmbeddingModel = def.EmbeddingModel
+	}
+	if c.EmbeddingBatchSize == 0 {
+		c.EmbeddingBatchSize = def.EmbeddingBatchSize
+	}
+	if c.ContextLength == 0 {
+		c.ContextLength = def.ContextLength
+	}
+	if c.MaxOutputTokens == 0 {
+		c.MaxOutputTokens = def.MaxOutputTokens
+	}
+	if c.MinOutputTokens == 0 {
+		c.MinOutputTokens = def.MinOutputTokens
+	}
 	return c
 }
 
 // Clone returns a deep copy of the configuration
 func (c *Config) Clone() *Config {
 	return &Config{
+		Provider:           c.Provider,
 		APIKey:             c.APIKey,
 		BaseURL:            c.BaseURL,
 		OrgID:              c.OrgID,
 		Timeout:            c.Timeout,
 		MaxRetries:         c.MaxRetries,
+		RetryBaseDelay:     c.RetryBaseDelay,
+		RetryMaxDelay:      c.RetryMaxDelay,
+		CallTimeout:        c.CallTimeout,
 		Model:              c.Model,
+		Models:             slices.Clone(c.Models),
 		MaxConcurrentCalls: c.MaxConcurrentCalls,
+		EmbeddingModel:     c.EmbeddingModel,
+		EmbeddingBatchSize: c.EmbeddingBatchSize,
+		ContextLength:      c.ContextLength,
+		MaxOutputTokens:    c.MaxOutputTokens,
+		MinOutputTokens:    c.MinOutputTokens,
+		ModelLimits:        maps.Clone(c.ModelLimits),
+	}
+}
+
+// ModelFor returns model when the client serves it, or empty for the client's default model
+func (c *Config) ModelFor(model string) string {
+	if model == c.Model || slices.Contains(c.Models, model) {
+		return model
 	}
+	return ""
+}
+
+// WithProvider sets the provider of the API
+func (c *Config) WithProvider(provider string) *Config {
+	c.Provider = provider
+	return c
 }
 
 // WithAPIKey sets the API key
@@ -111,6 +239,19 @@ func (c *Config) WithMaxRetries(maxRetries int) *Config {
 	return c
 }
 
+// WithRetryDelay sets the delay before the first retry and the most retries wait
+func (c *Config) WithRetryDelay(base, maxDelay time.Duration) *Config {
+	c.RetryBaseDelay = base
+	c.RetryMaxDelay = maxDelay
+	return c
+}
+
+// WithCallTimeout sets the deadline of a call including its retries
+func (c *Config) WithCallTimeout(timeout time.Duration) *Config {
+	c.CallTimeout = timeout
+	return c
+}
+
 // WithDefaultModel sets the default model
 func (c *Config) WithModel(model string) *Config {
 	c.Model = model
@@ -122,3 +263,15 @@ func (c *Config) WithMaxConcurrentCalls(max int) *Config {
 	c.MaxConcurrentCalls = max
 	return c
 }
+
+// WithEmbeddingModel sets the default embedding model
+func (c *Config) WithEmbeddingModel(model string) *Config {
+	c.EmbeddingModel = model
+	return c
+}
+
+// WithEmbeddingBatchSize sets the maximum number of inputs per embeddings request
+func (c *Config) WithEmbeddingBatchSize(size int) *Config {
+	c.EmbeddingBatchSize = size
+	return c
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error classes of failed calls, test with errors.Is
+var (
+	ErrRateLimited    = errors.New("rate limited")
+	ErrAuth           = errors.New("authentication failed")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrServer         = errors.New("server error")
+	ErrTimeout        = errors.New("timed out")
+)
+
+// APIError is an error response of the LLM API
+type APIError struct {
+	Class      error // one of the error classes
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+	// RetryAfter is how long the server asked to wait before retrying, 0 when it didn't
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("LLM API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the error class, along with ErrContextOverflow when the prompt was too long for the model
+func (e *APIError) Unwrap() []error {
+	// OpenAI has a code for it, llama.cpp a type and Anthropic only a message
+	if e.Code == "context_length_exceeded" || e.Type == "exceed_context_size_error" ||
+		strings.HasPrefix(e.Message, "prompt is too long") {
+		return []error{e.Class, ErrContextOverflow}
+	}
+	return []error{e.Class}
+}
+
+// newAPIError classifies an error response by its status code and body
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	var llmErr Error
+	if err := json.Unmarshal(body, &llmErr); err == nil {
+		e.Type = llmErr.Error.Type
+		e.Message = llmErr.Error.Message
+		if llmErr.Error.Code != nil {
+			e.Code = fmt.Sprint(llmErr.Error.Code)
+		}
+	} else {
+		// Ollama sends the message alone
+		var flat struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &flat) == nil {
+			e.Message = flat.Error
+		}
+	}
+
+	e.Class = statusClass(resp.StatusCode)
+	return e
+}
+
+// statusClass returns the error class of an HTTP status code
+func statusClass(code int) error {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrAuth
+	case code == http.StatusRequestTimeout:
+		return ErrTimeout
+	case code >= 500:
+		return ErrServer
+	}
+	return ErrInvalidRequest
+}
+
+// transportError classifies a failure to send a request or read its response
+func transportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrServer, err)
+}
+
+// IsRetryable reports whether a failed call may succeed when sent again
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	// An exhausted quota is rate limited too, but won't recover by waiting
+	if errors.As(err, &apiErr) && apiErr.Code == "insufficient_quota" {
+		return false
+	}
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrServer) || errors.Is(err, ErrTimeout)
+}
+
+// retryAfter returns the wait the server asked for before retrying err
+func retryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFailingServer answers the first failures requests with the status and body, then completes the chat
+func newFailingServer(t *testing.T, failures int32, status int, header http.Header, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+			return
+		}
+		fmt.Fprint(w, `{"model":"gpt-4o","choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newRetryClient(baseURL string) Client {
+	return NewClient(DefaultConfig().
+		WithAPIKey("test-key").
+		WithBaseURL(baseURL).
+		WithMaxRetries(2).
+		WithRetryDelay(time.Millisecond, 5*time.Millisecond))
+}
+
+var chatRequest = ChatCompletionRequest{Messages: []Message{{Role: "user", Content: "hi"}}}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		status   int
+		body     string
+		wantErr  error
+		wantSent int32
+	}{
+		{"server error recovers", 2, http.StatusServiceUnavailable, "", nil, 3},
+		{"rate limit recovers", 1, http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, nil, 2},
+		{"retries run out", 5, http.StatusInternalServerError, "", ErrServer, 3},
+		{"auth is not retried", 5, http.StatusUnauthorized, `{"error":{"message":"bad key"}}`, ErrAuth, 1},
+		{"invalid request is not retried", 5, http.StatusBadRequest, `{"error":{"message":"bad"}}`, ErrInvalidRequest, 1},
+		{"exhausted quota is not retried", 5, http.StatusTooManyRequests, `{"error":{"code":"insufficient_quota"}}`, ErrRateLimited, 1},
+		{"overflow is an invalid request", 5, http.StatusBadRequest, `{"error":{"code":"context_length_exceeded"}}`, ErrContextOverflow, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newFailingServer(t, tt.failures, tt.status, nil, tt.body)
+			resp, err := newRetryClient(server.URL).ChatCompletion(context.Background(), chatRequest)
+			if tt.wantErr == nil && (err != nil || resp.Choices[0].Message.Content != "ok") {
+				t.Errorf("ChatCompletion() = %v, %v, want ok", resp, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("ChatCompletion() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantSent {
+				t.Errorf("ChatCompletion() sent %d requests, want %d", got, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	server, requests := newFailingServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}}, "")
+
+	// A wait past the call deadline gives up at once
+	client := NewClient(DefaultConfig().WithAPIKey("test-key").WithBaseURL(server.URL).WithCallTimeout(500 * time.Millisecond))
+	_, err := client.ChatCompletion(context.Background(), chatRequest)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Second || requests.Load() != 1 {
+		t.Fatalf("ChatCompletion() = %v after %d requests, want a rate limit asking for 1s", err, requests.Load())
+	}
+
+	// Within the deadline the wait is honoured
+	requests.Store(0)
+	start := time.Now()
+	if _, err := newRetryClient(server.URL).ChatCompletion(context.Background(), chatRequest); err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("ChatCompletion() retried after %v, want the 1s asked for", elapsed)
+	}
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"Thu, 01 Jan 2026 00:00:10 GMT": 10 * time.Second,
+		"Wed, 31 Dec 2025 00:00:00 GMT": 0,
+		"soon":                          0,
+	} {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestRetryStream(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	stream, err := newRetryClient(server.URL).ChatCompletionStream(context.Background(), chatRequest)
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	var content string
+	for chunk := range stream {
+		content += chunk.Choices[0].Delta.Content
+	}
+	if content != "ok" || requests.Load() != 2 {
+		t.Errorf("ChatCompletionStream() streamed %q after %d requests, want ok after a retry", content, requests.Load())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, maxDelay := 100*time.Millisecond, time.Second
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		for i := 0; i < 50; i++ {
+			if got := backoff(attempt, base, maxDelay); got < want/2 || got > want {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", attempt, got, want/2, want)
+			}
+		}
+	}
+}
@@ -51,7 +51,7 @@ func TestExample(t *testing.T) {
 				Content: "问题：上海有什么好玩的",
 			},
 		},
-		Temperature: 0.7,
+		Temperature: Temperature(0.7),
 	}
 
 	// Example of streaming response
@@ -2,6 +2,7 @@ package llm
 
 import (
 	"fmt"
+	"sync"
 )
 
 const (
@@ -11,8 +12,17 @@ const (
 type LLMConfig struct {
 	Default *Config
 	Configs map[string]*Config `yaml:"configs"`
+	// Embeddings are clients dedicated to embeddings, a key without one embeds with its chat client
+	Embeddings map[string]*Config `yaml:"embeddings"`
+	// Router spreads calls over the clients with fallback and circuit breaking
+	Router RouterConfig `yaml:"router"`
 }
 
+var (
+	router   *Router
+	routerMu sync.RWMutex
+)
+
 // InitWithConfig initializes the LLM client with the provided configuration
 func InitWithConfig(configs *LLMConfig) error {
 	if len(configs.Configs) == 0 {
@@ -34,9 +44,35 @@ func InitWithConfig(configs *LLMConfig) error {
 		}
 	}
 
+	for key, config := range configs.Embeddings {
+		config.MergeDefault()
+		if err := config.Validate(); err != nil {
+			return fmt.Errorf("invalid embedding configuration for key %s: %v", key, err)
+		}
+
+		if err := manager.RegisterEmbeddingClient(key, config); err != nil {
+			return fmt.Errorf("failed to register embedding client for key %s: %v", key, err)
+		}
+	}
+
+	r, err := NewRouter(manager, &configs.Router)
+	if err != nil {
+		return fmt.Errorf("invalid router configuration: %v", err)
+	}
+	routerMu.Lock()
+	router = r
+	routerMu.Unlock()
+
 	return nil
 }
 
+// GetRouter returns the router over the registered clients
+func GetRouter() *Router {
+	routerMu.RLock()
+	defer routerMu.RUnlock()
+	return router
+}
+
 // GetClient returns the LLM client for the given key
 func GetClient(key string) (Client, error) {
 	return GetManager().GetClient(key)
@@ -48,6 +84,11 @@ func MustGetClient(key string) Client {
 	return GetManager().MustGetClient(key)
 }
 
+// GetEmbeddingClient returns the embedding client for the given key
+func GetEmbeddingClient(key string) (Client, error) {
+	return GetManager().GetEmbeddingClient(key)
+}
+
 // GetDefaultClient returns the default LLM client
 func GetDefaultClient() (Client, error) {
 	return GetClient(DefaultClientKey)
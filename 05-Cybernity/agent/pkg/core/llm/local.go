@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LocalClient is a client of a self-hosted inference server
+type LocalClient interface {
+	Client
+	// Models lists the models the server can run
+	Models(ctx context.Context) ([]ModelInfo, error)
+	// Health fails when the server can't answer, e.g. while it is down or loading its model
+	Health(ctx context.Context) error
+}
+
+// ModelInfo is a model available on an inference server
+type ModelInfo struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size,omitempty"` // in bytes, 0 when unknown
+}
+
+// llamaCppClient is a client of a llama.cpp server, which speaks the OpenAI API under /v1
+type llamaCppClient struct {
+	*client
+}
+
+// Models lists the model the server was started with
+func (c *llamaCppClient) Models(ctx context.Context) ([]ModelInfo, error) {
+	var resp struct {
+		Data []struct {
+			ID   string `json:"id"`
+			Meta struct {
+				Size int64 `json:"size"`
+			} `json:"meta"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, c.config.BaseURL+"/models", &resp); err != nil {
+		return nil, err
+	}
+	models := make([]ModelInfo, len(resp.Data))
+	for i, m := range resp.Data {
+		models[i] = ModelInfo{ID: m.ID, Size: m.Meta.Size}
+	}
+	return models, nil
+}
+
+// Health fails while the server is loading its model
+func (c *llamaCppClient) Health(ctx context.Context) error {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	return c.get(ctx, strings.TrimSuffix(c.config.BaseURL, "/v1")+"/health", &resp)
+}
+
+// LocalStatus is the health and models of the inference server behind a client
+type LocalStatus struct {
+	Key      string      `json:"key"`
+	Provider string      `json:"provider"`
+	Healthy  bool        `json:"healthy"`
+	Error    string      `json:"error,omitempty"`
+	Models   []ModelInfo `json:"models,omitempty"`
+}
+
+// CheckLocal checks the inference servers of the local clients of the manager concurrently
+func (m *ClientManager) CheckLocal(ctx context.Context) []LocalStatus {
+	var (
+		statuses []LocalStatus
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+	)
+	for _, key := range m.Keys() {
+		client, err := m.GetClient(key)
+		if err != nil {
+			continue
+		}
+		local, ok := client.(LocalClient)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := LocalStatus{Key: key, Provider: local.GetConfig().Provider}
+			err := local.Health(ctx)
+			if err == nil {
+				status.Models, err = local.Models(ctx)
+			}
+			status.Healthy = err == nil
+			if err != nil {
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			statuses = append(statuses, status)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses
+}
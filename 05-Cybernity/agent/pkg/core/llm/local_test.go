@@ -0,0 +1,295 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFakeOllama serves Ollama's native API, knowing a single model
+func newFakeOllama(t *testing.T, chats chan<- ollamaRequest) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/chat", func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "llama3.1:8b" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"model '%s' not found"}`, req.Model)
+			return
+		}
+		if chats != nil {
+			chats <- req
+		}
+		if len(req.Tools) > 0 {
+			fmt.Fprint(w, `{"model":"llama3.1:8b","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"calculator","arguments":{"expression":"6*7"}}}]},"done":true,"done_reason":"stop"}`)
+			return
+		}
+		if !req.Stream {
+			fmt.Fprint(w, `{"model":"llama3.1:8b","created_at":"2026-01-01T00:00:00Z","message":{"role":"assistant","content":"Hello!","thinking":"A greeting."},"done":true,"done_reason":"stop","prompt_eval_count":10,"eval_count":2}`)
+			return
+		}
+		chunks := []string{
+			`{"model":"llama3.1:8b","message":{"role":"assistant","content":"Hel"},"done":false}`,
+			`{"model":"llama3.1:8b","message":{"role":"assistant","content":"lo"},"done":false}`,
+			`{"model":"llama3.1:8b","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`,
+		}
+		// The model runner fails once streaming has started
+		if req.Messages[len(req.Messages)-1].Content == "crash" {
+			chunks = append(chunks[:1], `{"error":"model runner has unexpectedly stopped"}`)
+		}
+		for _, chunk := range chunks {
+			fmt.Fprintln(w, chunk)
+		}
+	})
+	mux.HandleFunc("POST /api/embed", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		// Embed each input as its length
+		resp := ollamaEmbedResponse{PromptEvalCount: len(req.Input)}
+		for _, input := range req.Input {
+			resp.Embeddings = append(resp.Embeddings, []float32{float32(len(input))})
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[{"name":"llama3.1:8b","size":4920753328},{"name":"nomic-embed-text:latest","size":274302450}]}`)
+	})
+	mux.HandleFunc("GET /api/version", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"version":"0.9.0"}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newLocalConfig(provider, baseURL, model string) *Config {
+	config := (&Config{Provider: provider, BaseURL: baseURL, Model: model}).
+		MergeDefault().
+		WithRetryDelay(time.Millisecond, 5*time.Millisecond)
+	return config
+}
+
+func TestOllamaChatCompletion(t *testing.T) {
+	chats := make(chan ollamaRequest, 1)
+	server := newFakeOllama(t, chats)
+	config := newLocalConfig(ProviderOllama, server.URL, "llama3.1:8b")
+	config.ModelLimits = map[string]ModelLimits{"llama3.1:8b": {ContextLength: 32768}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v, want local servers to need no key", err)
+	}
+	client := NewClient(config)
+
+	resp, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Messages:    []Message{{Role: "user", Content: "Hi"}},
+		MaxTokens:   100,
+		Temperature: Temperature(0),
+		Thinking:    &Thinking{Type: ThinkingEnabled},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	req := <-chats
+	if req.Stream || req.Options.NumCtx != 32768 || req.Options.NumPredict != 100 || req.Think == nil || !*req.Think {
+		t.Errorf("request = %+v, want no stream, the budgeted context and thinking", req)
+	}
+	message := resp.Choices[0].Message
+	if message.Content != "Hello!" || message.ReasoningContent != "A greeting." || resp.Choices[0].FinishReason != "stop" || resp.Usage.TotalTokens != 12 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	_, err = client.ChatCompletion(context.Background(), ChatCompletionRequest{Model: "mistral"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrInvalidRequest) || apiErr.Message != "model 'mistral' not found" {
+		t.Errorf("ChatCompletion(mistral) error = %v, want the model not found", err)
+	}
+}
+
+func TestOllamaTools(t *testing.T) {
+	chats := make(chan ollamaRequest, 1)
+	client := NewClient(newLocalConfig(ProviderOllama, newFakeOllama(t, chats).URL, "llama3.1:8b"))
+
+	resp, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Messages: []Message{
+			{Role: "user", Content: "What is 2+2?"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_0", Type: "function", Function: FunctionCall{Name: "calculator", Arguments: `{"expression":"2+2"}`}}}},
+			{Role: "tool", ToolCallID: "call_0", Content: "4"},
+		},
+		Tools: []Tool{NewFunctionTool("calculator", "Evaluates arithmetic", map[string]any{"type": "object"})},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	req := <-chats
+	if call := req.Messages[1].ToolCalls; len(call) != 1 || string(call[0].Function.Arguments) != `{"expression":"2+2"}` {
+		t.Errorf("assistant message = %+v, want the arguments as an object", req.Messages[1])
+	}
+	if req.Messages[2].ToolName != "calculator" {
+		t.Errorf("tool message = %+v, want the tool named", req.Messages[2])
+	}
+	calls := resp.Choices[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_0" || calls[0].Function.Arguments != `{"expression":"6*7"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	client := NewClient(newLocalConfig(ProviderOllama, newFakeOllama(t, nil).URL, "llama3.1:8b"))
+
+	stream, err := client.ChatCompletionStream(context.Background(), ChatCompletionRequest{Messages: []Message{{Role: "user", Content: "Hi"}}})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	var content, finish string
+	for chunk := range stream {
+		content += chunk.Choices[0].Delta.Content
+		if chunk.Choices[0].FinishReason != "" {
+			finish = chunk.Choices[0].FinishReason
+		}
+	}
+	if content != "Hello" || finish != "stop" {
+		t.Errorf("streamed %q, finish %q", content, finish)
+	}
+}
+
+func TestOllamaStreamError(t *testing.T) {
+	client := NewClient(newLocalConfig(ProviderOllama, newFakeOllama(t, nil).URL, "llama3.1:8b"))
+
+	stream, err := client.ChatCompletionStream(context.Background(), ChatCompletionRequest{Messages: []Message{{Role: "user", Content: "crash"}}})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	var content string
+	var streamErr error
+	for chunk := range stream {
+		if streamErr != nil {
+			t.Fatalf("chunk %+v after the error", chunk)
+		}
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+			continue
+		}
+		content += chunk.Choices[0].Delta.Content
+	}
+	var apiErr *APIError
+	if !errors.As(streamErr, &apiErr) || apiErr.Message != "model runner has unexpectedly stopped" {
+		t.Fatalf("stream error = %v, want the error chunk as an APIError", streamErr)
+	}
+	if content != "Hel" {
+		t.Errorf("streamed %q before the error, want %q", content, "Hel")
+	}
+}
+
+func TestOllamaEmbeddingsAndDiscovery(t *testing.T) {
+	config := newLocalConfig(ProviderOllama, newFakeOllama(t, nil).URL, "llama3.1:8b").
+		WithEmbeddingModel("nomic-embed-text").
+		WithEmbeddingBatchSize(2)
+	client := NewClient(config)
+
+	inputs := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	resp, err := client.Embeddings(context.Background(), EmbeddingRequest{Input: inputs})
+	if err != nil {
+		t.Fatalf("Embeddings() error = %v", err)
+	}
+	if resp.Model != "nomic-embed-text" || len(resp.Data) != len(inputs) || resp.Usage.TotalTokens != len(inputs) {
+		t.Fatalf("Embeddings() = %+v", resp)
+	}
+	for i, e := range resp.Data {
+		if e.Index != i || int(e.Embedding[0]) != len(inputs[i]) {
+			t.Errorf("Embeddings() data[%d] = %+v, want the embedding of %q", i, e, inputs[i])
+		}
+	}
+
+	local := client.(LocalClient)
+	if err := local.Health(context.Background()); err != nil {
+		t.Errorf("Health() error = %v", err)
+	}
+	models, err := local.Models(context.Background())
+	if err != nil || len(models) != 2 || models[0] != (ModelInfo{ID: "llama3.1:8b", Size: 4920753328}) {
+		t.Errorf("Models() = %+v, %v", models, err)
+	}
+}
+
+func TestLlamaCpp(t *testing.T) {
+	var loaded atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		if !loaded.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":{"code":503,"message":"Loading model","type":"unavailable_error"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"ok"}`)
+	})
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"qwen2.5-7b-instruct-q4_k_m.gguf","object":"model","owned_by":"llamacpp","meta":{"size":4677120000}}]}`)
+	})
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Messages[0].Content) > 100 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"code":400,"message":"the request exceeds the available context size","type":"exceed_context_size_error"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"model":%q,"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`, req.Model)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := newLocalConfig(ProviderLlamaCpp, server.URL+"/v1", "qwen2.5-7b")
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	client := NewClient(config).(LocalClient)
+
+	if err := client.Health(context.Background()); !errors.Is(err, ErrServer) {
+		t.Errorf("Health() error = %v while loading, want ErrServer", err)
+	}
+	loaded.Store(true)
+	if err := client.Health(context.Background()); err != nil {
+		t.Errorf("Health() error = %v", err)
+	}
+	models, err := client.Models(context.Background())
+	if err != nil || len(models) != 1 || models[0].ID != "qwen2.5-7b-instruct-q4_k_m.gguf" || models[0].Size != 4677120000 {
+		t.Errorf("Models() = %+v, %v", models, err)
+	}
+
+	resp, err := client.ChatCompletion(context.Background(), ChatCompletionRequest{Messages: []Message{{Role: "user", Content: "Hi"}}})
+	if err != nil || resp.Choices[0].Message.Content != "ok" || resp.Model != "qwen2.5-7b" {
+		t.Errorf("ChatCompletion() = %+v, %v", resp, err)
+	}
+	_, err = client.ChatCompletion(context.Background(), ChatCompletionRequest{Messages: []Message{{Role: "user", Content: strings.Repeat("long ", 50)}}})
+	if !errors.Is(err, ErrContextOverflow) {
+		t.Errorf("ChatCompletion(long) error = %v, want ErrContextOverflow", err)
+	}
+}
+
+func TestCheckLocal(t *testing.T) {
+	manager := &ClientManager{clients: make(map[string]Client), embeddingClients: make(map[string]Client)}
+	manager.RegisterClient("default", DefaultConfig().WithAPIKey("key"))
+	manager.RegisterClient("local", newLocalConfig(ProviderOllama, newFakeOllama(t, nil).URL, "llama3.1:8b"))
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	manager.RegisterClient("down", newLocalConfig(ProviderOllama, down.URL, "llama3.1:8b"))
+
+	statuses := manager.CheckLocal(context.Background())
+	if len(statuses) != 2 {
+		t.Fatalf("CheckLocal() = %+v, want the two local clients", statuses)
+	}
+	if s := statuses[0]; s.Key != "down" || s.Healthy || s.Error == "" {
+		t.Errorf("CheckLocal() down = %+v", s)
+	}
+	if s := statuses[1]; s.Key != "local" || !s.Healthy || s.Provider != ProviderOllama || len(s.Models) != 2 {
+		t.Errorf("CheckLocal() local = %+v", s)
+	}
+}
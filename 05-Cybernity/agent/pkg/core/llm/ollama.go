@@ -0,0 +1,330 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ollamaClient implements the LocalClient interface over Ollama's native API
+type ollamaClient struct {
+	*transport
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"` // Ollama streams by default
+	Think    *bool           `json:"think,omitempty"`
+	Tools    []Tool          `json:"tools,omitempty"`
+	Format   any             `json:"format,omitempty"` // "json" or a JSON Schema
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // of a tool message
+}
+
+// ollamaToolCall has no ID and its arguments as an object
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaOptions struct {
+	Temperature *float32 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	NumCtx      int      `json:"num_ctx,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// ollamaResponse is a chat response, or a chunk of a streamed one
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       time.Time     `json:"created_at"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// ChatCompletion sends a chat completion request to Ollama's chat API
+func (c *ollamaClient) ChatCompletion(ctx context.Context, request ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+
+	req, err := c.newChatRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ollamaResponse
+	if err := c.doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+	return &ChatCompletionResponse{
+		Object:  "chat.completion",
+		Created: resp.CreatedAt.Unix(),
+		Model:   resp.Model,
+		Choices: []Choice{{
+			Message: Message{
+				Role:             "assistant",
+				Content:          resp.Message.Content,
+				ReasoningContent: resp.Message.Thinking,
+				ToolCalls:        ollamaToolCalls(resp.Message.ToolCalls),
+			},
+			FinishReason: resp.DoneReason,
+		}},
+		Usage: Usage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+	}, nil
+}
+
+// ChatCompletionStream streams a chat completion from Ollama, which sends one JSON object per line
+func (c *ollamaClient) ChatCompletionStream(ctx context.Context, request ChatCompletionRequest) (<-chan StreamResponse, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	request.Stream = true
+	req, err := c.newChatRequest(ctx, request)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+
+	// Retries cover getting the stream, not its content once the first chunk is read
+	resp, err := c.openStream(ctx, req)
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+
+	streamChan := make(chan StreamResponse)
+	go func() {
+		defer func() {
+			resp.Body.Close()
+			c.release()
+			close(streamChan)
+		}()
+
+		fail := func(err error) {
+			select {
+			case streamChan <- StreamResponse{Object: "chat.completion.chunk", Err: err}:
+			case <-ctx.Done():
+			}
+		}
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var chunk ollamaResponse
+			if err := decoder.Decode(&chunk); err != nil {
+				// The stream ends with a done chunk, not before
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				if ctx.Err() == nil {
+					fail(transportError(err))
+				}
+				return
+			}
+			// Ollama reports errors once streaming has started as a chunk, with the 200 already sent
+			if chunk.Error != "" {
+				fail(&APIError{Class: ErrServer, StatusCode: resp.StatusCode, Message: chunk.Error})
+				return
+			}
+
+			response := StreamResponse{
+				Object:  "chat.completion.chunk",
+				Created: chunk.CreatedAt.Unix(),
+				Model:   chunk.Model,
+				Choices: []StreamChoice{{
+					Delta: Message{
+						Role:             chunk.Message.Role,
+						Content:          chunk.Message.Content,
+						ReasoningContent: chunk.Message.Thinking,
+						ToolCalls:        ollamaToolCalls(chunk.Message.ToolCalls),
+					},
+					FinishReason: chunk.DoneReason,
+				}},
+			}
+			select {
+			case streamChan <- response:
+			case <-ctx.Done():
+				return
+			}
+			if chunk.Done {
+				return
+			}
+		}
+	}()
+
+	return streamChan, nil
+}
+
+// Embeddings embeds the inputs in batches of EmbeddingBatchSize with Ollama's embed API
+func (c *ollamaClient) Embeddings(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	if len(request.Input) == 0 {
+		return nil, fmt.Errorf("no input to embed")
+	}
+	if request.Model == "" {
+		request.Model = c.config.EmbeddingModel
+	}
+
+	result := &EmbeddingResponse{Object: "list", Model: request.Model}
+	for start := 0; start < len(request.Input); start += c.config.EmbeddingBatchSize {
+		input := request.Input[start:min(start+c.config.EmbeddingBatchSize, len(request.Input))]
+		resp, err := c.embed(ctx, request.Model, input)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Embeddings) != len(input) {
+			return nil, fmt.Errorf("got %d embeddings for %d inputs", len(resp.Embeddings), len(input))
+		}
+		for i, e := range resp.Embeddings {
+			result.Data = append(result.Data, Embedding{Object: "embedding", Index: start + i, Embedding: e})
+		}
+		result.Usage.PromptTokens += resp.PromptEvalCount
+		result.Usage.TotalTokens += resp.PromptEvalCount
+	}
+	return result, nil
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings      [][]float32 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
+
+// embed sends one embed request, Ollama runs them one at a time anyway
+func (c *ollamaClient) embed(ctx context.Context, model string, input []string) (*ollamaEmbedResponse, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+
+	req, err := c.newRequest(ctx, "/api/embed", map[string]any{"model": model, "input": input})
+	if err != nil {
+		return nil, err
+	}
+	var resp ollamaEmbedResponse
+	if err := c.doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Models lists the models pulled on the server
+func (c *ollamaClient) Models(ctx context.Context) ([]ModelInfo, error) {
+	var resp struct {
+		Models []struct {
+			Name string `json:"name"`
+			Size int64  `json:"size"`
+		} `json:"models"`
+	}
+	if err := c.get(ctx, c.config.BaseURL+"/api/tags", &resp); err != nil {
+		return nil, err
+	}
+	models := make([]ModelInfo, len(resp.Models))
+	for i, m := range resp.Models {
+		models[i] = ModelInfo{ID: m.Name, Size: m.Size}
+	}
+	return models, nil
+}
+
+// Health fails when the server doesn't answer
+func (c *ollamaClient) Health(ctx context.Context) error {
+	var resp struct {
+		Version string `json:"version"`
+	}
+	return c.get(ctx, c.config.BaseURL+"/api/version", &resp)
+}
+
+// newChatRequest converts a chat completion request to an Ollama chat request
+func (c *ollamaClient) newChatRequest(ctx context.Context, request ChatCompletionRequest) (*http.Request, error) {
+	if len(request.Functions) > 0 {
+		return nil, fmt.Errorf("provider %s does not support functions: %w", ProviderOllama, ErrInvalidRequest)
+	}
+	// Ollama can't be kept from calling the tools it is given
+	if request.ToolChoice == ToolChoiceNone {
+		request.Tools = nil
+	}
+
+	chat := ollamaRequest{
+		Model:    request.Model,
+		Messages: ollamaMessages(request.Messages),
+		Stream:   request.Stream,
+		Tools:    request.Tools,
+		Options: ollamaOptions{
+			Temperature: request.Temperature,
+			NumPredict:  request.MaxTokens,
+			Stop:        request.Stop,
+		},
+	}
+	if chat.Model == "" {
+		chat.Model = c.config.Model
+	}
+	// Ollama silently cuts prompts longer than its small default context, size it as the prompt was budgeted
+	chat.Options.NumCtx = c.config.Budget(chat.Model).ContextLength
+	if t := request.Thinking; t != nil && t.Type != ThinkingAuto {
+		think := t.Type == ThinkingEnabled
+		chat.Think = &think
+	}
+	if f := request.ResponseFormat; f != nil {
+		switch {
+		case f.Type == ResponseFormatJSONSchema && f.JSONSchema != nil:
+			chat.Format = f.JSONSchema.Schema
+		case f.Type == ResponseFormatJSONObject:
+			chat.Format = "json"
+		}
+	}
+
+	return c.newRequest(ctx, "/api/chat", chat)
+}
+
+// ollamaMessages converts messages to Ollama's, which name the tool a result is of rather than the call
+func ollamaMessages(messages []Message) []ollamaMessage {
+	toolNames := make(map[string]string)
+	converted := make([]ollamaMessage, len(messages))
+	for i, m := range messages {
+		converted[i] = ollamaMessage{Role: m.Role, Content: m.Content, ToolName: toolNames[m.ToolCallID]}
+		for _, call := range m.ToolCalls {
+			toolNames[call.ID] = call.Function.Name
+			var c ollamaToolCall
+			c.Function.Name = call.Function.Name
+			c.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if len(c.Function.Arguments) == 0 {
+				c.Function.Arguments = json.RawMessage("{}")
+			}
+			converted[i].ToolCalls = append(converted[i].ToolCalls, c)
+		}
+	}
+	return converted
+}
+
+// ollamaToolCalls converts Ollama's tool calls, numbering them as they have no IDs
+func ollamaToolCalls(calls []ollamaToolCall) []ToolCall {
+	var converted []ToolCall
+	for i, call := range calls {
+		converted = append(converted, ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Function: FunctionCall{Name: call.Function.Name, Arguments: string(call.Function.Arguments)},
+		})
+	}
+	return converted
+}
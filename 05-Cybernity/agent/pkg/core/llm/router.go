@@ -0,0 +1,373 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoHealthyClient is returned when every client of a route has its circuit open
+var ErrNoHealthyClient = errors.New("no healthy LLM client")
+
+// latencyWeight is the weight of the latest call in a client's average latency
+const latencyWeight = 0.2
+
+// RouterConfig configures how calls are spread over the registered clients
+type RouterConfig struct {
+	// Routes are ordered tiers of clients by route name, a route without one uses the client of the same key
+	Routes  map[string][]Tier `yaml:"routes"`
+	Breaker BreakerConfig     `yaml:"breaker"`
+}
+
+// Tier is a set of clients balanced by weight, the next tier is tried when all of them fail
+type Tier struct {
+	Clients []Target `yaml:"clients"`
+}
+
+// Target is a client key and its share of the calls within its tier
+type Target struct {
+	Key    string `yaml:"key"`
+	Weight int    `yaml:"weight"` // 1 when 0
+}
+
+// BreakerConfig configures the circuit breaker of each client
+type BreakerConfig struct {
+	// FailureThreshold consecutive failures open the circuit
+	FailureThreshold int `yaml:"failure_threshold"`
+	// OpenTimeout is how long an open circuit rejects calls before a probe call is let through
+	OpenTimeout time.Duration `yaml:"open_timeout"`
+}
+
+// MergeDefault merges the default configuration with the current configuration
+func (c *RouterConfig) MergeDefault() *RouterConfig {
+	if c.Breaker.FailureThreshold == 0 {
+		c.Breaker.FailureThreshold = 3
+	}
+	if c.Breaker.OpenTimeout == 0 {
+		c.Breaker.OpenTimeout = 30 * time.Second
+	}
+	return c
+}
+
+// Validate validates the configuration against the registered clients
+func (c *RouterConfig) Validate(m *ClientManager) error {
+	if c.Breaker.FailureThreshold <= 0 || c.Breaker.OpenTimeout <= 0 {
+		return fmt.Errorf("breaker failure threshold and open timeout must be greater than 0")
+	}
+	for name, tiers := range c.Routes {
+		if len(tiers) == 0 {
+			return fmt.Errorf("route %s has no tiers", name)
+		}
+		for i, tier := range tiers {
+			if len(tier.Clients) == 0 {
+				return fmt.Errorf("tier %d of route %s has no clients", i, name)
+			}
+			for _, t := range tier.Clients {
+				if !m.HasClient(t.Key) {
+					return fmt.Errorf("route %s uses unknown client %s", name, t.Key)
+				}
+				if t.Weight < 0 {
+					return fmt.Errorf("route %s gives client %s a negative weight", name, t.Key)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Circuit states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// ClientStat reports the health of one client
+type ClientStat struct {
+	Key                 string        `json:"key"`
+	Served              int64         `json:"served"`
+	Failures            int64         `json:"failures"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	AvgLatency          time.Duration `json:"avg_latency"`
+	Circuit             string        `json:"circuit"`
+	LastError           string        `json:"last_error,omitempty"`
+}
+
+// clientHealth tracks the calls of a client and its circuit breaker
+type clientHealth struct {
+	key                 string
+	served              int64
+	failures            int64
+	consecutiveFailures int
+	avgLatency          time.Duration
+	lastError           string
+	openedAt            time.Time // zero while the circuit is closed
+	probing             bool      // a half-open probe call is in flight
+}
+
+func (h *clientHealth) circuit(now time.Time, timeout time.Duration) string {
+	switch {
+	case h.openedAt.IsZero():
+		return CircuitClosed
+	case now.Sub(h.openedAt) < timeout:
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
+
+// Router sends calls to the clients of a route: tiers in order, clients within a tier by weighted chance,
+// skipping clients whose circuit is open
+type Router struct {
+	manager *ClientManager
+	config  RouterConfig
+	now     func() time.Time
+
+	mu     sync.Mutex
+	health map[string]*clientHealth
+}
+
+// NewRouter creates a router over the clients of the manager
+func NewRouter(manager *ClientManager, config *RouterConfig) (*Router, error) {
+	config.MergeDefault()
+	if err := config.Validate(manager); err != nil {
+		return nil, err
+	}
+	return &Router{
+		manager: manager,
+		config:  *config,
+		now:     time.Now,
+		health:  make(map[string]*clientHealth),
+	}, nil
+}
+
+// Do calls fn with the clients of the route until one succeeds. Each client is tried once,
+// failures count against its circuit breaker unless the context is done or the request was at fault.
+func (r *Router) Do(ctx context.Context, route string, fn func(ctx context.Context, key string, client Client) error) error {
+	var errs []error
+	tried := false
+	for _, key := range r.candidates(route) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !r.acquire(key) {
+			continue
+		}
+		client, err := r.manager.GetClient(key)
+		if err != nil {
+			r.release(key)
+			errs = append(errs, err)
+			continue
+		}
+
+		tried = true
+		start := r.now()
+		err = fn(ctx, key, client)
+		r.record(key, r.now().Sub(start), err, ctx.Err() == nil && !callerError(err))
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", key, err))
+	}
+	if !tried {
+		errs = append(errs, ErrNoHealthyClient)
+	}
+	return errors.Join(errs...)
+}
+
+// callerError reports whether a call failed because of the request rather than the client
+func callerError(err error) bool {
+	return errors.Is(err, ErrContextOverflow) || errors.Is(err, ErrInvalidRequest)
+}
+
+// Has reports whether calls on the route go to clients of its own: a configured route,
+// or a client with its key, rather than the default client
+func (r *Router) Has(route string) bool {
+	_, ok := r.config.Routes[route]
+	return ok || r.manager.HasClient(route)
+}
+
+// Clients returns the keys of the clients of a configured route, tier by tier in configuration order
+func (r *Router) Clients(route string) []string {
+	tiers, ok := r.config.Routes[route]
+	if !ok {
+		if r.manager.HasClient(route) {
+			return []string{route}
+		}
+		return nil
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, tier := range tiers {
+		for _, t := range tier.Clients {
+			if !seen[t.Key] {
+				seen[t.Key] = true
+				keys = append(keys, t.Key)
+			}
+		}
+	}
+	return keys
+}
+
+// LocalOnly fails unless the route is configured and all of its clients are self-hosted,
+// so what is sent on it stays with us
+func (r *Router) LocalOnly(route string) error {
+	keys := r.Clients(route)
+	if len(keys) == 0 {
+		return fmt.Errorf("route %s is not configured", route)
+	}
+	for _, key := range keys {
+		client, err := r.manager.GetClient(key)
+		if err != nil {
+			return err
+		}
+		if provider := client.GetConfig().Provider; !IsLocal(provider) {
+			return fmt.Errorf("route %s uses client %s of provider %s, which is not self-hosted", route, key, provider)
+		}
+	}
+	return nil
+}
+
+// candidates returns the client keys of a route in the order to try them
+func (r *Router) candidates(route string) []string {
+	tiers, ok := r.config.Routes[route]
+	if !ok {
+		key := route
+		if !r.manager.HasClient(key) {
+			key = DefaultClientKey
+		}
+		return []string{key}
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, tier := range tiers {
+		for _, key := range weightedOrder(tier.Clients) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// weightedOrder shuffles the targets so each comes first with a chance proportional to its weight
+func weightedOrder(targets []Target) []string {
+	type scored struct {
+		key   string
+		score float64
+	}
+	order := make([]scored, len(targets))
+	for i, t := range targets {
+		weight := t.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		// Efraimidis-Spirakis: sorting by u^(1/w) samples without replacement proportionally to w
+		order[i] = scored{t.Key, -rand.ExpFloat64() / float64(weight)}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].score > order[j].score })
+
+	keys := make([]string, len(order))
+	for i, o := range order {
+		keys[i] = o.key
+	}
+	return keys
+}
+
+// acquire reports whether the client may be called, letting one probe call through a half-open circuit
+func (r *Router) acquire(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := r.healthOf(key)
+	switch h.circuit(r.now(), r.config.Breaker.OpenTimeout) {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if h.probing {
+			return false
+		}
+		h.probing = true
+	}
+	return true
+}
+
+func (r *Router) release(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.healthOf(key).probing = false
+}
+
+// record updates the health of a client after a call. Calls that failed for reasons of their own,
+// like a cancelled context, only release a probe.
+func (r *Router) record(key string, latency time.Duration, err error, counts bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := r.healthOf(key)
+	wasProbe := h.probing
+	h.probing = false
+	if err != nil && !counts {
+		return
+	}
+
+	if h.avgLatency == 0 {
+		h.avgLatency = latency
+	} else {
+		h.avgLatency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(h.avgLatency))
+	}
+	if err == nil {
+		h.served++
+		h.consecutiveFailures = 0
+		h.openedAt = time.Time{}
+		return
+	}
+
+	h.failures++
+	h.consecutiveFailures++
+	h.lastError = err.Error()
+	// A failed probe reopens the circuit for another timeout
+	if wasProbe || h.consecutiveFailures >= r.config.Breaker.FailureThreshold {
+		h.openedAt = r.now()
+	}
+}
+
+func (r *Router) healthOf(key string) *clientHealth {
+	h, ok := r.health[key]
+	if !ok {
+		h = &clientHealth{key: key}
+		r.health[key] = h
+	}
+	return h
+}
+
+// Stats returns the health of every client called so far, by key
+func (r *Router) Stats() []ClientStat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	stats := make([]ClientStat, 0, len(r.health))
+	for _, h := range r.health {
+		stats = append(stats, ClientStat{
+			Key:                 h.key,
+			Served:              h.served,
+			Failures:            h.failures,
+			ConsecutiveFailures: h.consecutiveFailures,
+			AvgLatency:          h.avgLatency,
+			Circuit:             h.circuit(now, r.config.Breaker.OpenTimeout),
+			LastError:           h.lastError,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+	return stats
+}
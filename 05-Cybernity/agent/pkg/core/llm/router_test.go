@@ -0,0 +1,196 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestRouter(t *testing.T, keys []string, config *RouterConfig) (*Router, *time.Time) {
+	t.Helper()
+	manager := &ClientManager{clients: make(map[string]Client), embeddingClients: make(map[string]Client)}
+	for _, key := range keys {
+		if err := manager.RegisterClient(key, DefaultConfig().WithAPIKey(key)); err != nil {
+			t.Fatalf("RegisterClient() error = %v", err)
+		}
+	}
+	router, err := NewRouter(manager, config)
+	if err != nil {
+		t.Fatalf("NewRouter() error = %v", err)
+	}
+	now := time.Unix(0, 0)
+	router.now = func() time.Time { return now }
+	return router, &now
+}
+
+func TestRouterFallback(t *testing.T) {
+	router, _ := newTestRouter(t, []string{"primary", "backup"}, &RouterConfig{
+		Routes: map[string][]Tier{
+			"answer": {{Clients: []Target{{Key: "primary"}}}, {Clients: []Target{{Key: "backup"}}}},
+		},
+	})
+
+	var called []string
+	err := router.Do(context.Background(), "answer", func(ctx context.Context, key string, client Client) error {
+		called = append(called, key)
+		if client.GetConfig().APIKey != key {
+			t.Errorf("Do() passed the client of %s as %s", client.GetConfig().APIKey, key)
+		}
+		if key == "primary" {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	if err != nil || len(called) != 2 || called[0] != "primary" || called[1] != "backup" {
+		t.Errorf("Do() = %v, called %v, want primary then backup", err, called)
+	}
+
+	err = router.Do(context.Background(), "answer", func(ctx context.Context, key string, client Client) error {
+		return errors.New("unavailable")
+	})
+	if err == nil {
+		t.Error("Do() succeeded with every client failing")
+	}
+
+	// Routes without tiers use the client of the same key, else the default one
+	err = router.Do(context.Background(), "backup", func(ctx context.Context, key string, client Client) error {
+		if key != "backup" {
+			t.Errorf("Do(backup) called %s", key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Do(backup) error = %v", err)
+	}
+}
+
+func TestRouterBreaker(t *testing.T) {
+	router, now := newTestRouter(t, []string{"primary", "backup"}, &RouterConfig{
+		Routes: map[string][]Tier{
+			"answer": {{Clients: []Target{{Key: "primary"}}}, {Clients: []Target{{Key: "backup"}}}},
+		},
+		Breaker: BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute},
+	})
+
+	primaryUp := false
+	calls := map[string]int{}
+	ask := func() error {
+		return router.Do(context.Background(), "answer", func(ctx context.Context, key string, client Client) error {
+			calls[key]++
+			if key == "primary" && !primaryUp {
+				return errors.New("unavailable")
+			}
+			return nil
+		})
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := ask(); err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+	}
+	if calls["primary"] != 2 || calls["backup"] != 4 {
+		t.Errorf("calls = %v, want primary skipped once its circuit opened", calls)
+	}
+	if stat := statOf(router, "primary"); stat.Circuit != CircuitOpen || stat.ConsecutiveFailures != 2 || stat.LastError != "unavailable" {
+		t.Errorf("primary stat = %+v, want an open circuit", stat)
+	}
+
+	// A failed probe reopens the circuit
+	*now = now.Add(time.Minute)
+	if stat := statOf(router, "primary"); stat.Circuit != CircuitHalfOpen {
+		t.Errorf("primary circuit = %s after the timeout, want half open", stat.Circuit)
+	}
+	ask()
+	ask()
+	if calls["primary"] != 3 {
+		t.Errorf("primary called %d times, want a single probe", calls["primary"])
+	}
+
+	// A successful probe closes it
+	*now = now.Add(time.Minute)
+	primaryUp = true
+	ask()
+	ask()
+	if calls["primary"] != 5 {
+		t.Errorf("primary called %d times, want it back in use", calls["primary"])
+	}
+	if stat := statOf(router, "primary"); stat.Circuit != CircuitClosed || stat.Served != 2 || stat.Failures != 3 {
+		t.Errorf("primary stat = %+v, want a closed circuit", stat)
+	}
+}
+
+func TestRouterSkipsCallerErrors(t *testing.T) {
+	router, _ := newTestRouter(t, []string{"default"}, &RouterConfig{Breaker: BreakerConfig{FailureThreshold: 1}})
+
+	for _, callErr := range []error{ErrContextOverflow, &APIError{Class: ErrInvalidRequest, StatusCode: 400}} {
+		router.Do(context.Background(), "answer", func(ctx context.Context, key string, client Client) error {
+			return callErr
+		})
+	}
+	if stat := statOf(router, "default"); stat.Circuit != CircuitClosed || stat.Failures != 0 {
+		t.Errorf("default stat = %+v, want invalid requests not to count", stat)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := router.Do(ctx, "answer", func(ctx context.Context, key string, client Client) error {
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) || statOf(router, "default").Circuit != CircuitClosed {
+		t.Errorf("Do() = %v, want a cancelled call not to open the circuit", err)
+	}
+
+	router.Do(context.Background(), "answer", func(ctx context.Context, key string, client Client) error {
+		return errors.New("unavailable")
+	})
+	err = router.Do(context.Background(), "answer", func(ctx context.Context, key string, client Client) error {
+		t.Error("Do() called a client with an open circuit")
+		return nil
+	})
+	if !errors.Is(err, ErrNoHealthyClient) {
+		t.Errorf("Do() = %v, want ErrNoHealthyClient", err)
+	}
+}
+
+func TestWeightedOrder(t *testing.T) {
+	targets := []Target{{Key: "heavy", Weight: 3}, {Key: "light", Weight: 1}}
+	first := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		order := weightedOrder(targets)
+		if len(order) != 2 || order[0] == order[1] {
+			t.Fatalf("weightedOrder() = %v, want each target once", order)
+		}
+		first[order[0]]++
+	}
+	// heavy comes first 3 times in 4
+	if first["heavy"] < 2800 || first["heavy"] > 3200 {
+		t.Errorf("heavy came first %d times in 4000, want about 3000", first["heavy"])
+	}
+}
+
+func TestRouterConfigValidate(t *testing.T) {
+	manager := &ClientManager{clients: make(map[string]Client), embeddingClients: make(map[string]Client)}
+	manager.RegisterClient("default", DefaultConfig())
+
+	for name, config := range map[string]*RouterConfig{
+		"unknown client":  {Routes: map[string][]Tier{"answer": {{Clients: []Target{{Key: "missing"}}}}}},
+		"empty route":     {Routes: map[string][]Tier{"answer": {}}},
+		"empty tier":      {Routes: map[string][]Tier{"answer": {{}}}},
+		"negative weight": {Routes: map[string][]Tier{"answer": {{Clients: []Target{{Key: "default", Weight: -1}}}}}},
+	} {
+		if _, err := NewRouter(manager, config); err == nil {
+			t.Errorf("NewRouter(%s) accepted the configuration", name)
+		}
+	}
+}
+
+func statOf(router *Router, key string) ClientStat {
+	for _, stat := range router.Stats() {
+		if stat.Key == key {
+			return stat
+		}
+	}
+	return ClientStat{}
+}
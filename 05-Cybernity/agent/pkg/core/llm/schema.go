@@ -0,0 +1,299 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidOutput is returned when the model's reply doesn't match the schema asked for
+var ErrInvalidOutput = errors.New("reply does not match the schema")
+
+// Response formats
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat asks the model for JSON replies, matching a schema with json_schema
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema names the schema replies have to match
+type JSONSchema struct {
+	Name        string `json:"name"` // letters, digits, _ and -
+	Description string `json:"description,omitempty"`
+	Schema      any    `json:"schema"`
+	// Strict makes providers that can enforce the schema while generating do so,
+	// it needs every property required and no additional properties
+	Strict bool `json:"strict,omitempty"`
+}
+
+// Instruction asks for the format in words, for providers that can't be asked in the request
+func (f *ResponseFormat) Instruction() string {
+	switch {
+	case f == nil || f.Type == ResponseFormatText || f.Type == "":
+		return ""
+	case f.Type == ResponseFormatJSONSchema && f.JSONSchema != nil:
+		schema, _ := json.Marshal(f.JSONSchema.Schema)
+		return "Reply with a single JSON value matching this JSON Schema, without any other text:\n" + string(schema)
+	}
+	return "Reply with a single JSON object, without any other text."
+}
+
+// Schema is a JSON Schema replies are validated against. It supports the keywords providers
+// take for structured output: type, properties, required, additionalProperties, items, enum,
+// minimum, maximum, minLength, maxLength, minItems and maxItems.
+type Schema struct {
+	root map[string]any
+}
+
+// annotations are keywords that don't constrain values
+var annotations = []string{"$schema", "title", "description", "default", "examples", "format"}
+
+var schemaKeywords = []string{
+	"type", "properties", "required", "additionalProperties", "items", "enum",
+	"minimum", "maximum", "minLength", "maxLength", "minItems", "maxItems",
+}
+
+var schemaTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// ParseSchema parses a JSON Schema, rejecting keywords it can't enforce
+func ParseSchema(data []byte) (*Schema, error) {
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := checkSchema(root, "$"); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// MustParseSchema parses a schema known to be valid, panicking otherwise
+func MustParseSchema(data string) *Schema {
+	schema, err := ParseSchema([]byte(data))
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// Map returns the schema to send in a ResponseFormat
+func (s *Schema) Map() map[string]any {
+	return s.root
+}
+
+func checkSchema(node map[string]any, path string) error {
+	for key, value := range node {
+		switch {
+		case slices.Contains(annotations, key):
+		case !slices.Contains(schemaKeywords, key):
+			return fmt.Errorf("schema keyword %s at %s is not supported", key, path)
+		case key == "type":
+			for _, t := range typesOf(value) {
+				if !slices.Contains(schemaTypes, t) {
+					return fmt.Errorf("unknown type %v at %s", t, path)
+				}
+			}
+			if len(typesOf(value)) == 0 {
+				return fmt.Errorf("invalid type at %s", path)
+			}
+		case key == "properties":
+			properties, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("properties at %s must be an object", path)
+			}
+			for name, property := range properties {
+				sub, ok := property.(map[string]any)
+				if !ok {
+					return fmt.Errorf("property %s at %s must be a schema", name, path)
+				}
+				if err := checkSchema(sub, path+"."+name); err != nil {
+					return err
+				}
+			}
+		case key == "items":
+			sub, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("items at %s must be a schema", path)
+			}
+			if err := checkSchema(sub, path+"[]"); err != nil {
+				return err
+			}
+		case key == "required":
+			names, ok := value.([]any)
+			if !ok {
+				return fmt.Errorf("required at %s must be a list of names", path)
+			}
+			for _, name := range names {
+				if _, ok := name.(string); !ok {
+					return fmt.Errorf("required at %s must be a list of names", path)
+				}
+			}
+		case key == "additionalProperties":
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("additionalProperties at %s must be true or false", path)
+			}
+		case key == "enum":
+			if values, ok := value.([]any); !ok || len(values) == 0 {
+				return fmt.Errorf("enum at %s must be a list of values", path)
+			}
+		default:
+			// The bounds
+			if n, ok := value.(float64); !ok || (key != "minimum" && key != "maximum" && (n < 0 || n != math.Trunc(n))) {
+				return fmt.Errorf("%s at %s must be a number", key, path)
+			}
+		}
+	}
+	return nil
+}
+
+// typesOf returns the types of a type keyword, a name or a list of names
+func typesOf(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var types []string
+		for _, t := range v {
+			name, ok := t.(string)
+			if !ok {
+				return nil
+			}
+			types = append(types, name)
+		}
+		return types
+	}
+	return nil
+}
+
+// Validate extracts the JSON value from a reply and checks it against the schema.
+// It returns the value alone, without code fences or text around it.
+func (s *Schema) Validate(reply string) (string, error) {
+	text := ExtractJSON(reply)
+	decoder := json.NewDecoder(strings.NewReader(text))
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("%w: invalid JSON: %v", ErrInvalidOutput, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return "", fmt.Errorf("%w: text after the JSON value", ErrInvalidOutput)
+	}
+	if err := validateValue(s.root, value, "$"); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+	}
+	return text, nil
+}
+
+// ExtractJSON returns the JSON value of a reply, which models wrap in code fences or text now and then
+func ExtractJSON(reply string) string {
+	text := strings.TrimSpace(reply)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimPrefix(text, "json")
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+	}
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return text
+	}
+	// A value between text
+	start, end := strings.IndexAny(text, "{["), strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start {
+		return text[start : end+1]
+	}
+	return text
+}
+
+func validateValue(schema map[string]any, value any, path string) error {
+	if types := typesOf(schema["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return isType(value, t) }) {
+		return fmt.Errorf("%s must be %s", path, strings.Join(types, " or "))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return jsonEqual(e, value) }) {
+		return fmt.Errorf("%s must be one of %v", path, enum)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := v[name.(string)]; !ok {
+					return fmt.Errorf("%s.%s is required", path, name)
+				}
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(v)) {
+			sub, ok := properties[name].(map[string]any)
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s.%s is not allowed", path, name)
+				}
+				continue
+			}
+			if err := validateValue(sub, v[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if err := checkBounds(schema, "minItems", "maxItems", float64(len(v)), path, "items"); err != nil {
+			return err
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validateValue(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		return checkBounds(schema, "minLength", "maxLength", float64(utf8.RuneCountInString(v)), path, "characters")
+	case float64:
+		return checkBounds(schema, "minimum", "maximum", v, path, "")
+	}
+	return nil
+}
+
+func checkBounds(schema map[string]any, minKey, maxKey string, n float64, path, unit string) error {
+	if min, ok := schema[minKey].(float64); ok && n < min {
+		return fmt.Errorf("%s must have at least %v %s", path, min, unit)
+	}
+	if max, ok := schema[maxKey].(float64); ok && n > max {
+		return fmt.Errorf("%s must have at most %v %s", path, max, unit)
+	}
+	return nil
+}
+
+func isType(value any, t string) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		return t == "object"
+	case []any:
+		return t == "array"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || (t == "integer" && v == math.Trunc(v))
+	case bool:
+		return t == "boolean"
+	case nil:
+		return t == "null"
+	}
+	return false
+}
+
+func jsonEqual(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
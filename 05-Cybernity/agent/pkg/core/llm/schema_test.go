@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+  "type": "object",
+  "properties": {
+    "answer": {"type": "string", "minLength": 1, "description": "The answer"},
+    "confidence": {"type": "number", "minimum": 0, "maximum": 1},
+    "sections": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+    "kind": {"type": ["string", "null"], "enum": ["fact", "opinion", null]},
+    "count": {"type": "integer"}
+  },
+  "required": ["answer", "confidence"],
+  "additionalProperties": false
+}`
+
+func TestParseSchema(t *testing.T) {
+	tests := []struct {
+		schema string
+		want   string
+	}{
+		{`{"type": "object", "oneOf": []}`, "oneOf at $ is not supported"},
+		{`{"properties": {"a": {"$ref": "#/x"}}}`, "$ref at $.a is not supported"},
+		{`{"type": "map"}`, "unknown type map"},
+		{`{"items": {"minItems": -1}}`, "minItems at $[] must be a number"},
+		{`{"required": "answer"}`, "required at $ must be a list"},
+		{`{"additionalProperties": {}}`, "additionalProperties at $ must be true or false"},
+		{`[]`, "invalid schema"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseSchema([]byte(tt.schema)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseSchema(%s) error = %v, want %q", tt.schema, err, tt.want)
+		}
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := MustParseSchema(testSchema)
+	tests := []struct {
+		name  string
+		reply string
+		want  string // error, empty when valid
+	}{
+		{"valid", `{"answer": "yes", "confidence": 0.9, "sections": ["Intro"], "kind": null, "count": 2}`, ""},
+		{"fenced", "```json\n{\"answer\": \"yes\", \"confidence\": 1}\n```", ""},
+		{"text around", `Here it is: {"answer": "yes", "confidence": 1} Hope it helps.`, ""},
+		{"not json", "yes", "invalid JSON"},
+		{"two values", `{"answer": "yes", "confidence": 1} {}`, "text after the JSON value"},
+		{"trailing brace", `{"answer": "yes", "confidence": 1}}`, "text after the JSON value"},
+		{"trailing bracket", `{"answer": "yes", "confidence": 1}]`, "text after the JSON value"},
+		{"missing", `{"answer": "yes"}`, "$.confidence is required"},
+		{"type", `{"answer": 1, "confidence": 1}`, "$.answer must be string"},
+		{"minimum", `{"answer": "yes", "confidence": 2}`, "$.confidence must have at most 1"},
+		{"min length", `{"answer": "", "confidence": 1}`, "$.answer must have at least 1 characters"},
+		{"items", `{"answer": "yes", "confidence": 1, "sections": ["a", 2]}`, "$.sections[1] must be string"},
+		{"max items", `{"answer": "yes", "confidence": 1, "sections": ["a", "b", "c"]}`, "$.sections must have at most 2 items"},
+		{"enum", `{"answer": "yes", "confidence": 1, "kind": "guess"}`, "$.kind must be one of"},
+		{"integer", `{"answer": "yes", "confidence": 1, "count": 1.5}`, "$.count must be integer"},
+		{"additional", `{"answer": "yes", "confidence": 1, "extra": true}`, "$.extra is not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := schema.Validate(tt.reply)
+			if tt.want == "" {
+				if err != nil || !strings.HasPrefix(got, "{") || !strings.HasSuffix(got, "}") {
+					t.Errorf("Validate() = %q, %v, want the JSON object alone", got, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidOutput) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseFormat(t *testing.T) {
+	format := &ResponseFormat{
+		Type:       ResponseFormatJSONSchema,
+		JSONSchema: &JSONSchema{Name: "answer", Schema: MustParseSchema(testSchema).Map()},
+	}
+
+	// Ollama constrains the reply to the schema
+	chats := make(chan ollamaRequest, 1)
+	ollama := NewClient(newLocalConfig(ProviderOllama, newFakeOllama(t, chats).URL, "llama3.1:8b"))
+	if _, err := ollama.ChatCompletion(context.Background(), ChatCompletionRequest{ResponseFormat: format}); err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if schema, ok := (<-chats).Format.(map[string]any); !ok || schema["type"] != "object" {
+		t.Errorf("ollama format = %v, want the schema", schema)
+	}
+	if _, err := ollama.ChatCompletion(context.Background(), ChatCompletionRequest{ResponseFormat: &ResponseFormat{Type: ResponseFormatJSONObject}}); err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if got := (<-chats).Format; got != "json" {
+		t.Errorf("ollama format = %v, want json", got)
+	}
+
+	// Anthropic is asked in the system prompt
+	var got anthropicRequest
+	anthropic := newAnthropicStub(t, func(w http.ResponseWriter, req anthropicRequest) {
+		got = req
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"{}"}],"stop_reason":"end_turn"}`)
+	})
+	_, err := anthropic.ChatCompletion(context.Background(), ChatCompletionRequest{
+		Messages:       []Message{{Role: "system", Content: "You are helpful."}, {Role: "user", Content: "Hi"}},
+		ResponseFormat: format,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if !strings.HasPrefix(got.System, "You are helpful.\n\nReply with a single JSON value") || !strings.Contains(got.System, `"required":["answer","confidence"]`) {
+		t.Errorf("anthropic system = %q, want the schema after the prompt", got.System)
+	}
+	if (&ResponseFormat{Type: ResponseFormatText}).Instruction() != "" {
+		t.Error("Instruction() of text, want none")
+	}
+}
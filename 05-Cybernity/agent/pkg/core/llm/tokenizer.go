@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// Tokenizer counts the tokens a model's tokenizer splits text into
+type Tokenizer interface {
+	Count(text string) int
+}
+
+const (
+	encodingCL100K = "cl100k_base"
+	encodingO200K  = "o200k_base"
+)
+
+// knownEncodings are the BPE vocabularies of common models, matched by prefix, the longest first
+var knownEncodings = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", encodingO200K},
+	{"gpt-4.1", encodingO200K},
+	{"gpt-4.5", encodingO200K},
+	{"chatgpt-4o", encodingO200K},
+	{"o1", encodingO200K},
+	{"o3", encodingO200K},
+	{"o4", encodingO200K},
+	{"gpt-4", encodingCL100K},
+	{"gpt-3.5-turbo", encodingCL100K},
+	{"text-embedding-3", encodingCL100K},
+	{"text-embedding-ada-002", encodingCL100K},
+}
+
+var (
+	bpeTokenizers   = make(map[string]*BPETokenizer)
+	bpeTokenizersMu sync.Mutex
+)
+
+func init() {
+	// The vocabularies are embedded, tokenizing never downloads them
+	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+}
+
+// TokenizerFor returns the BPE tokenizer of a known model, or an EstimateTokenizer for other models
+// or when the vocabulary can't be loaded
+func TokenizerFor(model string) Tokenizer {
+	for _, k := range knownEncodings {
+		if strings.HasPrefix(model, k.prefix) {
+			if t, err := bpeTokenizer(k.encoding); err == nil {
+				return t
+			}
+			break
+		}
+	}
+	return EstimateTokenizer{}
+}
+
+// BPETokenizer counts tokens exactly with the vocabulary of an OpenAI model
+type BPETokenizer struct {
+	encoding *tiktoken.Tiktoken
+}
+
+// bpeTokenizer loads a vocabulary once, it takes a while and a few megabytes
+func bpeTokenizer(encoding string) (*BPETokenizer, error) {
+	bpeTokenizersMu.Lock()
+	defer bpeTokenizersMu.Unlock()
+	if t, ok := bpeTokenizers[encoding]; ok {
+		return t, nil
+	}
+	e, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+	t := &BPETokenizer{encoding: e}
+	bpeTokenizers[encoding] = t
+	return t, nil
+}
+
+func (t *BPETokenizer) Count(text string) int {
+	// Special tokens in the text are counted as plain text, which is how the API sends them
+	return len(t.encoding.EncodeOrdinary(text))
+}
+
+// EstimateTokenizer approximates BPE tokenizers such as cl100k and o200k without their vocabularies,
+// for models whose vocabulary isn't known: it splits text the way they pre-tokenize it and estimates
+// the tokens of each piece, erring high so a budget built on it is safe
+type EstimateTokenizer struct{}
+
+func (EstimateTokenizer) Count(text string) int {
+	var (
+		tokens int
+		class  = classNone
+		run    int // runes in the current piece
+		prev   rune
+	)
+	for _, r := range text {
+		c := classify(r)
+		switch {
+		case class == classSpace && run == 1 && prev == ' ' && (c == classWord || c == classOther):
+			// A single space is merged into the word that follows it, like BPE pre-tokenizers do
+			class = c
+		case c != class || c == classSymbol:
+			tokens += class.tokens(run)
+			class, run = c, 1
+		default:
+			run++
+		}
+		prev = r
+	}
+	return tokens + class.tokens(run)
+}
+
+type runeClass int
+
+const (
+	classNone   runeClass = iota
+	classWord             // Latin and other space separated letters
+	classOther            // letters of scripts whose words are split into more tokens, like Cyrillic or Arabic
+	classDigit            // digits, split in groups of three
+	classIdeo             // CJK and other scripts written without spaces
+	classSpace            // whitespace
+	classSymbol           // punctuation and symbols, a token each
+)
+
+func classify(r rune) runeClass {
+	switch {
+	case unicode.IsSpace(r):
+		return classSpace
+	case unicode.IsDigit(r):
+		return classDigit
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai):
+		return classIdeo
+	case unicode.IsLetter(r) || unicode.Is(unicode.Mn, r):
+		if r < utf8.RuneSelf || unicode.In(r, unicode.Latin) {
+			return classWord
+		}
+		return classOther
+	}
+	return classSymbol
+}
+
+// tokens estimates the tokens of a piece of n runes of the class
+func (c runeClass) tokens(n int) int {
+	if n == 0 {
+		return 0
+	}
+	switch c {
+	case classWord:
+		// Common words are one token, long or rare ones a few
+		return (n + 5) / 6
+	case classOther:
+		return 1 + (n-1)/2
+	case classDigit:
+		return (n + 2) / 3
+	case classIdeo:
+		return n
+	case classSpace:
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// transport sends the HTTP requests of a client within its concurrency limit, with retries
+type transport struct {
+	config     *Config
+	httpClient *http.Client
+	semaphore  chan struct{}
+}
+
+func newTransport(config *Config) *transport {
+	return &transport{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: config.Timeout,
+		},
+		semaphore: make(chan struct{}, config.MaxConcurrentCalls),
+	}
+}
+
+func (t *transport) GetConfig() *Config {
+	return t.config
+}
+
+// acquire takes one of the client's concurrent calls, to be given back with release
+func (t *transport) acquire(ctx context.Context) error {
+	select {
+	case t.semaphore <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *transport) release() {
+	<-t.semaphore
+}
+
+// newRequest creates a JSON POST request to the path under the base URL
+func (t *transport) newRequest(ctx context.Context, path string, body any) (*http.Request, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", t.config.BaseURL+path, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// get sends a GET request once, without retries, and decodes the response into v
+func (t *transport) get(ctx context.Context, url string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	return t.send(req, func(resp *http.Response) error {
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %w: %w", ErrServer, err)
+		}
+		return nil
+	})
+}
+
+// openStream sends a streaming request with retries until the stream is open.
+// The response body is the caller's to close.
+func (t *transport) openStream(ctx context.Context, req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	err := t.retry(ctx, time.Now().Add(t.config.CallTimeout), req, func(r *http.Response) error {
+		resp = r
+		return nil
+	})
+	return resp, err
+}
+
+// doRequest performs the HTTP request with retries within the call deadline and decodes the response into v
+func (t *transport) doRequest(req *http.Request, v interface{}) error {
+	deadline := time.Now().Add(t.config.CallTimeout)
+	ctx, cancel := context.WithDeadline(req.Context(), deadline)
+	defer cancel()
+
+	return t.retry(ctx, deadline, req.WithContext(ctx), func(resp *http.Response) error {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response body: %w", transportError(err))
+		}
+		if err := json.Unmarshal(body, v); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %w: %w", ErrServer, err)
+		}
+		return nil
+	})
+}
+
+// retry sends the request until handle accepts a successful response, retrying retryable failures
+// after a backoff as long as it ends before the deadline. The response body is handle's to close.
+func (t *transport) retry(ctx context.Context, deadline time.Time, req *http.Request, handle func(*http.Response) error) error {
+	// 保存原始请求体
+	var originalBody []byte
+	if req.Body != nil {
+		originalBody, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+
+	for attempt := 0; ; attempt++ {
+		// 为每次重试创建新的请求体
+		if originalBody != nil {
+			req.Body = io.NopCloser(bytes.NewBuffer(originalBody))
+			req.ContentLength = int64(len(originalBody))
+		}
+
+		err := t.send(req, handle)
+		if err == nil {
+			return nil
+		}
+		if attempt >= t.config.MaxRetries || !IsRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := retryAfter(err)
+		if delay == 0 {
+			delay = backoff(attempt, t.config.RetryBaseDelay, t.config.RetryMaxDelay)
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// send sends the request once, handing a successful response to handle
+func (t *transport) send(req *http.Request, handle func(*http.Response) error) error {
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", transportError(err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return newAPIError(resp, body)
+	}
+	return handle(resp)
+}
+
+// maxErrorBody is the most of an error response read
+const maxErrorBody = 64 << 10
+
+// backoff returns the wait before retry number attempt+1: exponential from base up to max,
+// with jitter over its upper half so clients failing together don't retry together
+func backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// readSSE reads server-sent events, calling fn with the event name and data of each until fn returns false
+func readSSE(r io.Reader, fn func(event string, data []byte) bool) error {
+	reader := bufio.NewReader(r)
+	var event string
+	for {
+		line, err := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		switch {
+		case len(line) == 0:
+			event = ""
+		case bytes.HasPrefix(line, []byte("event:")):
+			event = string(bytes.TrimSpace(bytes.TrimPrefix(line, []byte("event:"))))
+		case bytes.HasPrefix(line, []byte("data:")):
+			if !fn(event, bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))) {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -4,6 +4,43 @@ package llm
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ReasoningContent is the thinking of a reasoning model before its answer
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// ToolCalls are the tools an assistant message asks to call
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID is the call a tool message answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// Tool is a tool the model may call
+type Tool struct {
+	Type     string   `json:"type"` // function
+	Function Function `json:"function"`
+}
+
+// ToolCall is a call of a tool by the model
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"` // function
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall is the function a tool call calls, with its JSON encoded arguments
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Tool choices besides naming a tool
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
+// Temperature returns t to set as ChatCompletionRequest.Temperature
+func Temperature(t float32) *float32 {
+	return &t
 }
 
 // ChatCompletionRequest represents a request for chat completion
@@ -11,10 +48,15 @@ type ChatCompletionRequest struct {
 	Model       string     `json:"model"`
 	Messages    []Message  `json:"messages"`
 	MaxTokens   int        `json:"max_tokens,omitempty"`
-	Temperature float32    `json:"temperature,omitempty"`
+	Temperature *float32   `json:"temperature,omitempty"` // nil for the provider's default, 0 is the most deterministic
 	Stream      bool       `json:"stream,omitempty"`
 	Stop        []string   `json:"stop,omitempty"`
-	Functions   []Function `json:"functions,omitempty"`
+	Functions   []Function `json:"functions,omitempty"` // deprecated by OpenAI in favour of tools
+	Tools       []Tool     `json:"tools,omitempty"`
+	ToolChoice  string     `json:"tool_choice,omitempty"` // auto when empty
+	Thinking    *Thinking  `json:"thinking,omitempty"`    // the model's default when nil
+	// ResponseFormat asks for JSON replies, text when nil
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 }
 
 type ThinkingType string
@@ -25,8 +67,11 @@ const (
 	ThinkingAuto     ThinkingType = "auto"
 )
 
+// Thinking configures the reasoning of models that think before answering
 type Thinking struct {
 	Type ThinkingType `json:"type"`
+	// BudgetTokens caps the thinking of providers that take a budget, 1024 at least
+	BudgetTokens int `json:"budget_tokens,omitempty"`
 }
 
 // ChatCompletionResponse represents a response from chat completion
@@ -53,6 +98,33 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// EmbeddingRequest represents a request for embeddings of one or more inputs
+type EmbeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+// EmbeddingResponse represents a response with one embedding per input, in input order
+type EmbeddingResponse struct {
+	Object string      `json:"object"`
+	Data   []Embedding `json:"data"`
+	Model  string      `json:"model"`
+	Usage  Usage       `json:"usage"`
+}
+
+// Embedding represents the embedding of the input at Index
+type Embedding struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// NewFunctionTool returns a tool calling a function with the JSON schema of its parameters
+func NewFunctionTool(name, description string, parameters any) Tool {
+	return Tool{Type: "function", Function: Function{Name: name, Description: description, Parameters: parameters}}
+}
+
 // Function represents a function that can be called by the model
 type Function struct {
 	Name        string `json:"name"`
@@ -84,4 +156,6 @@ type StreamResponse struct {
 	Created int64          `json:"created"`
 	Model   string         `json:"model"`
 	Choices []StreamChoice `json:"choices"`
+	// Err is set, without choices, on the last response of a stream that failed
+	Err error `json:"-"`
 }
@@ -0,0 +1,111 @@
+package prompt
+
+import (
+	"cybernity/pkg/core/llm"
+	"fmt"
+	"sync"
+)
+
+type Config struct {
+	MaxTemplateLength int `yaml:"max_template_length"`
+	// MaxPromptLength is the longest system prompt a template may render, knowledge included, in bytes
+	MaxPromptLength int `yaml:"max_prompt_length"`
+	// MaxSchemaLength is the longest response schema, in bytes
+	MaxSchemaLength int `yaml:"max_schema_length"`
+	// Models creators may pick for their agents, besides the default model of the LLM client
+	Models []string `yaml:"models"`
+	// Routes creators may pin their agents to for knowledge that must stay with us, all their clients must be self-hosted
+	Routes []string `yaml:"routes"`
+	// MaxRepairs is how many times a reply that doesn't match the agent's response schema is sent back to be fixed,
+	// nil for the default and 0 to never send one back
+	MaxRepairs *int `yaml:"max_repairs"`
+}
+
+var (
+	config   = DefaultConfig()
+	configMu sync.RWMutex
+)
+
+// DefaultConfig returns a default configuration
+func DefaultConfig() *Config {
+	maxRepairs := 2
+	return &Config{
+		MaxTemplateLength: 8192,
+		MaxPromptLength:   2 * 1024 * 1024,
+		MaxSchemaLength:   16384,
+		MaxRepairs:        &maxRepairs,
+	}
+}
+
+// MergeDefault merges the default configuration with the current configuration
+func (c *Config) MergeDefault() *Config {
+	def := DefaultConfig()
+	if c.MaxTemplateLength == 0 {
+		c.MaxTemplateLength = def.MaxTemplateLength
+	}
+	if c.MaxPromptLength == 0 {
+		c.MaxPromptLength = def.MaxPromptLength
+	}
+	if c.MaxSchemaLength == 0 {
+		c.MaxSchemaLength = def.MaxSchemaLength
+	}
+	if c.MaxRepairs == nil {
+		c.MaxRepairs = def.MaxRepairs
+	}
+	return c
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	if c.MaxTemplateLength <= 0 {
+		return fmt.Errorf("max template length must be greater than 0")
+	}
+	if c.MaxPromptLength <= 0 {
+		return fmt.Errorf("max prompt length must be greater than 0")
+	}
+	if c.MaxSchemaLength <= 0 {
+		return fmt.Errorf("max schema length must be greater than 0")
+	}
+	if *c.MaxRepairs < 0 {
+		return fmt.Errorf("max repairs must not be negative")
+	}
+	for _, model := range c.Models {
+		if model == "" {
+			return fmt.Errorf("models must not be empty")
+		}
+	}
+	for _, route := range c.Routes {
+		if route == "" {
+			return fmt.Errorf("routes must not be empty")
+		}
+		// Agents are pinned to a route to keep their knowledge with us
+		router := llm.GetRouter()
+		if router == nil {
+			return fmt.Errorf("routes need the llm router to be initialized first")
+		}
+		if err := router.LocalOnly(route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InitWithConfig sets how personas are validated
+func InitWithConfig(cfg *Config) error {
+	cfg.MergeDefault()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = cfg
+	return nil
+}
+
+// GetConfig returns how personas are validated
+func GetConfig() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
@@ -0,0 +1,321 @@
+package prompt
+
+import (
+	"bytes"
+	"cybernity/pkg/core/llm"
+	"cybernity/pkg/core/tool"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// DefaultTemplate is the system prompt of agents without their own template
+const DefaultTemplate = "你是{{.Name}},你的描述是{{.Description}},现在你在进行一次知识付费，以下是知识库中与问题最相关的片段：\n\n{{.Knowledge}}\n\n请根据知识库回答问题。"
+
+// AnswerSchema is an example response schema for agents whose answers are read by programs
+const AnswerSchema = `{
+  "type": "object",
+  "properties": {
+    "answer": {"type": "string", "description": "The answer to the question"},
+    "confidence": {"type": "number", "minimum": 0, "maximum": 1, "description": "How sure the answer is"},
+    "sections": {"type": "array", "items": {"type": "string"}, "description": "Titles of the knowledge sections the answer is from"}
+  },
+  "required": ["answer", "confidence", "sections"],
+  "additionalProperties": false
+}`
+
+// DefaultTemperature is used when the persona doesn't set one
+const DefaultTemperature float32 = 0.7
+
+// Tones an agent can answer in
+const (
+	ToneNeutral  = "neutral"
+	ToneFriendly = "friendly"
+	ToneFormal   = "formal"
+	ToneConcise  = "concise"
+)
+
+// Refusal policies for questions the knowledge doesn't answer
+const (
+	// RefusalStrict answers from the knowledge only and says so when it has no answer
+	RefusalStrict = "strict"
+	// RefusalLenient may answer from general knowledge, saying which parts are not from the knowledge
+	RefusalLenient = "lenient"
+)
+
+var toneDirectives = map[string]string{
+	ToneNeutral:  "请保持客观中立的语气。",
+	ToneFriendly: "请使用友好亲切的语气。",
+	ToneFormal:   "请使用正式专业的语气。",
+	ToneConcise:  "请简洁扼要地回答。",
+}
+
+var refusalDirectives = map[string]string{
+	RefusalStrict:  "只根据知识库回答；如果知识库中没有相关内容，请明确说明无法回答，不要编造。",
+	RefusalLenient: "如果知识库中没有相关内容，可以结合常识回答，但需说明哪些内容不是来自知识库。",
+}
+
+// Persona configures how an agent answers
+type Persona struct {
+	Template      string   `json:"template"` // empty for DefaultTemplate
+	Language      string   `json:"language"` // BCP 47 tag, empty to answer in the language of the question
+	Tone          string   `json:"tone"`
+	RefusalPolicy string   `json:"refusal_policy"`
+	Temperature   *float32 `json:"temperature"` // nil for DefaultTemperature
+	Model         string   `json:"model"`       // empty for the default model
+	Route         string   `json:"route"`       // LLM route answering the agent's questions, empty for the shared one
+	Tools         []string `json:"tools"`       // built-in tools the agent may call
+	// ResponseSchema is the JSON Schema answers have to match, empty to answer in text
+	ResponseSchema string `json:"response_schema"`
+}
+
+// Default returns the persona of agents that have not configured one
+func Default() *Persona {
+	return &Persona{Tone: ToneNeutral, RefusalPolicy: RefusalStrict}
+}
+
+// Vars are the variables a template can use
+type Vars struct {
+	Name        string
+	Description string
+	Knowledge   string
+	Question    string
+}
+
+// Validate checks every field, so a saved persona always renders
+func (p *Persona) Validate() error {
+	var errs []error
+	if p.Template != "" {
+		if err := validateTemplate(p.Template); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.Language != "" {
+		if _, err := language.Parse(p.Language); err != nil {
+			errs = append(errs, fmt.Errorf("invalid language %q", p.Language))
+		}
+	}
+	if _, ok := toneDirectives[p.Tone]; !ok {
+		errs = append(errs, fmt.Errorf("tone must be one of neutral, friendly, formal or concise"))
+	}
+	if _, ok := refusalDirectives[p.RefusalPolicy]; !ok {
+		errs = append(errs, fmt.Errorf("refusal policy must be strict or lenient"))
+	}
+	if t := p.Temperature; t != nil && (*t < 0 || *t > 2) {
+		errs = append(errs, errors.New("temperature must be between 0 and 2"))
+	}
+	if p.Model != "" && !slices.Contains(GetConfig().Models, p.Model) {
+		errs = append(errs, fmt.Errorf("model %q is not available", p.Model))
+	}
+	if p.Route != "" && !slices.Contains(GetConfig().Routes, p.Route) {
+		errs = append(errs, fmt.Errorf("route %q is not available", p.Route))
+	}
+	for _, name := range p.Tools {
+		if !tool.GetConfig().IsEnabled(name) {
+			errs = append(errs, fmt.Errorf("tool %q is not available", name))
+		}
+	}
+	if p.ResponseSchema != "" {
+		if limit := GetConfig().MaxSchemaLength; len(p.ResponseSchema) > limit {
+			errs = append(errs, fmt.Errorf("response schema exceeds %d bytes", limit))
+		} else if _, err := llm.ParseSchema([]byte(p.ResponseSchema)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid response schema: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateTemplate(text string) error {
+	if limit := GetConfig().MaxTemplateLength; len(text) > limit {
+		return fmt.Errorf("template exceeds %d bytes", limit)
+	}
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	if len(tmpl.Templates()) > 1 {
+		return errors.New("template must not define templates")
+	}
+	usesKnowledge, err := checkNode(tmpl.Tree.Root)
+	if err != nil {
+		return err
+	}
+	if !usesKnowledge {
+		return errors.New("template must include {{.Knowledge}}")
+	}
+	// Catches unknown variables and misused functions, which only fail when executed
+	if _, err := render(tmpl, Vars{}); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	return nil
+}
+
+// unboundedFuncs are the builtin functions whose output isn't bounded by their arguments,
+// printf "%999999d" alone writes a megabyte
+var unboundedFuncs = []string{"print", "printf", "println", "call"}
+
+// checkNode rejects loops, template calls and unbounded functions, whose output isn't bounded by the template's length,
+// and reports whether the template refers to the knowledge
+func checkNode(node parse.Node) (bool, error) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false, nil
+		}
+		var uses bool
+		for _, child := range n.Nodes {
+			u, err := checkNode(child)
+			if err != nil {
+				return false, err
+			}
+			uses = uses || u
+		}
+		return uses, nil
+	case *parse.ActionNode:
+		if err := checkPipe(n.Pipe); err != nil {
+			return false, err
+		}
+		return strings.Contains(n.String(), ".Knowledge"), nil
+	case *parse.IfNode:
+		if err := checkPipe(n.Pipe); err != nil {
+			return false, err
+		}
+		return checkBranches(n.List, n.ElseList)
+	case *parse.WithNode:
+		if err := checkPipe(n.Pipe); err != nil {
+			return false, err
+		}
+		return checkBranches(n.List, n.ElseList)
+	case *parse.RangeNode:
+		return false, errors.New("template must not use range")
+	case *parse.TemplateNode:
+		return false, errors.New("template must not call templates")
+	}
+	return false, nil
+}
+
+// checkPipe rejects the unbounded functions anywhere in a pipeline, including parenthesized ones
+func checkPipe(pipe *parse.PipeNode) error {
+	if pipe == nil {
+		return nil
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.IdentifierNode:
+				if slices.Contains(unboundedFuncs, a.Ident) {
+					return fmt.Errorf("template must not use %s", a.Ident)
+				}
+			case *parse.PipeNode:
+				if err := checkPipe(a); err != nil {
+					return err
+				}
+			case *parse.ChainNode:
+				if p, ok := a.Node.(*parse.PipeNode); ok {
+					if err := checkPipe(p); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func checkBranches(list, elseList *parse.ListNode) (bool, error) {
+	uses, err := checkNode(list)
+	if err != nil {
+		return false, err
+	}
+	usesElse, err := checkNode(elseList)
+	return uses || usesElse, err
+}
+
+// SystemPrompt renders the template followed by the directives of the persona
+func (p *Persona) SystemPrompt(vars Vars) (string, error) {
+	text := p.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	rendered, err := render(tmpl, vars)
+	if err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	var b strings.Builder
+	b.WriteString(rendered)
+
+	// Directives follow the template, so a template can't drop them
+	var directives []string
+	if p.Language != "" {
+		if tag, err := language.Parse(p.Language); err == nil {
+			directives = append(directives, fmt.Sprintf("请使用%s（%s）回答。", display.Self.Name(tag), tag))
+		}
+	}
+	if d, ok := toneDirectives[p.Tone]; ok {
+		directives = append(directives, d)
+	}
+	if d, ok := refusalDirectives[p.RefusalPolicy]; ok {
+		directives = append(directives, d)
+	}
+	if len(directives) > 0 {
+		b.WriteString("\n\n" + strings.Join(directives, "\n"))
+	}
+	return b.String(), nil
+}
+
+// render executes the template, failing once it writes more than the maximum prompt length
+func render(tmpl *template.Template, vars Vars) (string, error) {
+	w := &cappedWriter{limit: GetConfig().MaxPromptLength}
+	if err := tmpl.Execute(w, vars); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// cappedWriter is a buffer refusing writes past its limit
+type cappedWriter struct {
+	bytes.Buffer
+	limit int
+}
+
+func (w *cappedWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > w.limit {
+		return 0, fmt.Errorf("rendered prompt exceeds %d bytes", w.limit)
+	}
+	return w.Buffer.Write(p)
+}
+
+// GetTemperature returns the temperature of the persona, DefaultTemperature when not set
+func (p *Persona) GetTemperature() float32 {
+	if p.Temperature == nil {
+		return DefaultTemperature
+	}
+	return *p.Temperature
+}
+
+// ResponseFormat returns the format answers are asked in and the schema they are validated against,
+// nil for text answers
+func (p *Persona) ResponseFormat() (*llm.ResponseFormat, *llm.Schema, error) {
+	if p.ResponseSchema == "" {
+		return nil, nil, nil
+	}
+	schema, err := llm.ParseSchema([]byte(p.ResponseSchema))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid response schema: %w", err)
+	}
+	format := &llm.ResponseFormat{
+		Type:       llm.ResponseFormatJSONSchema,
+		JSONSchema: &llm.JSONSchema{Name: "answer", Schema: schema.Map()},
+	}
+	return format, schema, nil
+}
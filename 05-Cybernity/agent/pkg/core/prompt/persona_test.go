@@ -0,0 +1,167 @@
+package prompt
+
+import (
+	"cybernity/pkg/core/llm"
+	"cybernity/pkg/core/tool"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var initRoutesOnce sync.Once
+
+// initRoutes registers a remote default client and a self-hosted one, each on the route of its key
+func initRoutes(t *testing.T) {
+	t.Helper()
+	initRoutesOnce.Do(func() {
+		err := llm.InitWithConfig(&llm.LLMConfig{Configs: map[string]*llm.Config{
+			llm.DefaultClientKey: {APIKey: "key"},
+			"local":              {Provider: llm.ProviderOllama, Model: "llama3.1:8b"},
+		}})
+		if err != nil {
+			t.Fatalf("llm.InitWithConfig() error = %v", err)
+		}
+	})
+}
+
+func TestSystemPrompt(t *testing.T) {
+	vars := Vars{Name: "Ada", Description: "pricing expert", Knowledge: "[a.md#1]\nplans", Question: "cost?"}
+
+	got, err := Default().SystemPrompt(vars)
+	if err != nil {
+		t.Fatalf("SystemPrompt() error = %v", err)
+	}
+	for _, want := range []string{"你是Ada,你的描述是pricing expert", "[a.md#1]\nplans", toneDirectives[ToneNeutral], refusalDirectives[RefusalStrict]} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SystemPrompt() = %q, want it to contain %q", got, want)
+		}
+	}
+
+	temperature := float32(0)
+	persona := &Persona{
+		Template:      "You are {{.Name}}.{{if .Knowledge}} Use:\n{{.Knowledge}}{{end}}",
+		Language:      "fr",
+		Tone:          ToneConcise,
+		RefusalPolicy: RefusalLenient,
+		Temperature:   &temperature,
+	}
+	if err := persona.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	got, err = persona.SystemPrompt(vars)
+	if err != nil {
+		t.Fatalf("SystemPrompt() error = %v", err)
+	}
+	if !strings.HasPrefix(got, "You are Ada. Use:\n[a.md#1]\nplans\n\n") || !strings.Contains(got, "français（fr）") {
+		t.Errorf("SystemPrompt() = %q", got)
+	}
+	if persona.GetTemperature() != 0 || Default().GetTemperature() != DefaultTemperature {
+		t.Error("GetTemperature() did not keep an explicit 0")
+	}
+}
+
+func TestMaxPromptLength(t *testing.T) {
+	old := GetConfig()
+	defer InitWithConfig(old)
+	if err := InitWithConfig(&Config{MaxPromptLength: 1024}); err != nil {
+		t.Fatalf("InitWithConfig() error = %v", err)
+	}
+
+	// Short enough to save, but each copy of the knowledge counts towards the rendered length
+	persona := &Persona{Template: strings.Repeat("{{.Knowledge}}", 8), Tone: ToneNeutral, RefusalPolicy: RefusalStrict}
+	if err := persona.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if _, err := persona.SystemPrompt(Vars{Knowledge: strings.Repeat("k", 100)}); err != nil {
+		t.Errorf("SystemPrompt() error = %v", err)
+	}
+	if _, err := persona.SystemPrompt(Vars{Knowledge: strings.Repeat("k", 200)}); err == nil || !strings.Contains(err.Error(), "exceeds 1024 bytes") {
+		t.Errorf("SystemPrompt() error = %v, want the rendered prompt to exceed its limit", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	old := GetConfig()
+	defer InitWithConfig(old)
+	initRoutes(t)
+	if err := InitWithConfig(&Config{Models: []string{"gpt-4o"}, Routes: []string{"local"}}); err != nil {
+		t.Fatalf("InitWithConfig() error = %v", err)
+	}
+	oldTools := tool.GetConfig()
+	defer tool.InitWithConfig(oldTools)
+	tool.InitWithConfig(&tool.Config{Enabled: []string{tool.Calculator}})
+
+	hot := float32(3)
+	tests := []struct {
+		name    string
+		persona Persona
+		want    string
+	}{
+		{"no knowledge", Persona{Template: "You are {{.Name}}"}, "must include {{.Knowledge}}"},
+		{"syntax", Persona{Template: "{{.Knowledge"}, "invalid template"},
+		{"unknown variable", Persona{Template: "{{.Knowledge}} {{.Secret}}"}, "invalid template"},
+		{"range", Persona{Template: "{{range 100000000}}{{.Knowledge}}{{end}}"}, "must not use range"},
+		{"printf", Persona{Template: `{{printf "%.0s%999999d" .Knowledge 1}}`}, "must not use printf"},
+		{"piped print", Persona{Template: `{{.Knowledge | print}}`}, "must not use print"},
+		{"nested println", Persona{Template: `{{if (println .Knowledge)}}{{.Knowledge}}{{end}}`}, "must not use println"},
+		{"call", Persona{Template: `{{.Knowledge}}{{with call .Name}}{{end}}`}, "must not use call"},
+		{"template call", Persona{Template: `{{define "x"}}{{template "x"}}{{end}}{{.Knowledge}}`}, "must not define templates"},
+		{"too long", Persona{Template: "{{.Knowledge}}" + strings.Repeat("x", 9000)}, "exceeds"},
+		{"language", Persona{Language: "not a language"}, "invalid language"},
+		{"tone", Persona{Tone: "angry"}, "tone must be"},
+		{"refusal", Persona{RefusalPolicy: "never"}, "refusal policy must be"},
+		{"temperature", Persona{Temperature: &hot}, "temperature must be"},
+		{"model", Persona{Model: "gpt-5-ultra"}, "not available"},
+		{"route", Persona{Route: "elsewhere"}, "not available"},
+		{"tool", Persona{Tools: []string{tool.AgentLookup}}, "not available"},
+		{"response schema", Persona{ResponseSchema: `{"type": "object", "oneOf": []}`}, "invalid response schema"},
+		{"response schema too long", Persona{ResponseSchema: `{"description": "` + strings.Repeat("x", 17000) + `"}`}, "response schema exceeds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.persona
+			if p.Tone == "" {
+				p.Tone = ToneNeutral
+			}
+			if p.RefusalPolicy == "" {
+				p.RefusalPolicy = RefusalStrict
+			}
+			err := p.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	ok := Persona{Template: "{{.Knowledge}}", Tone: ToneFormal, RefusalPolicy: RefusalStrict, Model: "gpt-4o", Route: "local", Tools: []string{tool.Calculator}, ResponseSchema: AnswerSchema}
+	if err := ok.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestConfigRoutes(t *testing.T) {
+	initRoutes(t)
+	for route, want := range map[string]string{
+		"local":              "",
+		llm.DefaultClientKey: "not self-hosted",
+		"missing":            "not configured",
+	} {
+		err := (&Config{Routes: []string{route}}).MergeDefault().Validate()
+		if want == "" && err != nil {
+			t.Errorf("Validate(route %s) error = %v", route, err)
+		}
+		if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("Validate(route %s) error = %v, want %q", route, err, want)
+		}
+	}
+}
+
+func TestConfigMaxRepairs(t *testing.T) {
+	if got := *(&Config{}).MergeDefault().MaxRepairs; got != 2 {
+		t.Errorf("MergeDefault() max repairs = %d, want the default 2", got)
+	}
+	never := 0
+	if got := *(&Config{MaxRepairs: &never}).MergeDefault().MaxRepairs; got != 0 {
+		t.Errorf("MergeDefault() max repairs = %d, want 0 kept", got)
+	}
+}
@@ -0,0 +1,109 @@
+package rag
+
+import (
+	"cybernity/pkg/core/knowledge"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Chunk is a passage of a knowledge document
+type Chunk struct {
+	Document string `json:"document"`
+	Index    int    `json:"index"` // position within the document
+	Text     string `json:"text"`
+}
+
+// Source identifies the chunk within the agent's knowledge
+func (c Chunk) Source() string {
+	return c.Document + "#" + strconv.Itoa(c.Index+1)
+}
+
+// Split cuts the text of documents into chunks of at most size characters, packing whole paragraphs
+// when they fit. Consecutive chunks of a document share up to overlap characters so a passage
+// cut in two can still be retrieved.
+func Split(docs []knowledge.Document, size, overlap int) []Chunk {
+	var chunks []Chunk
+	for _, doc := range docs {
+		for i, text := range splitText(string(doc.Content), size, overlap) {
+			chunks = append(chunks, Chunk{Document: doc.Name, Index: i, Text: text})
+		}
+	}
+	return chunks
+}
+
+func splitText(text string, size, overlap int) []string {
+	var (
+		chunks  []string
+		current []rune
+		fresh   bool // current has text beyond the overlap of the previous chunk
+	)
+	flush := func() {
+		chunks = append(chunks, strings.TrimSpace(string(current)))
+		current = tail(current, overlap)
+		fresh = false
+	}
+
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		for _, piece := range pieces([]rune(para), max(size-overlap-2, 1)) {
+			sep := 0
+			if len(current) > 0 {
+				sep = 2
+			}
+			if fresh && len(current)+sep+len(piece) > size {
+				flush()
+			}
+			if len(current) > 0 {
+				current = append(current, '\n', '\n')
+			}
+			current = append(current, piece...)
+			fresh = true
+		}
+	}
+	if fresh {
+		flush()
+	}
+	return chunks
+}
+
+// pieces cuts a paragraph longer than size at the last space or sentence end before the limit
+func pieces(para []rune, size int) [][]rune {
+	var out [][]rune
+	for len(para) > size {
+		cut := size
+		for i := size; i > size/2; i-- {
+			if unicode.IsSpace(para[i]) || strings.ContainsRune("。！？.!?", para[i-1]) {
+				cut = i
+				break
+			}
+		}
+		out = append(out, []rune(strings.TrimSpace(string(para[:cut]))))
+		para = []rune(strings.TrimSpace(string(para[cut:])))
+	}
+	if len(para) > 0 {
+		out = append(out, para)
+	}
+	return out
+}
+
+// tail returns the last n characters of a chunk, starting at a word when possible
+func tail(chunk []rune, n int) []rune {
+	if n <= 0 || len(chunk) == 0 {
+		return nil
+	}
+	if len(chunk) <= n {
+		return append([]rune(nil), chunk...)
+	}
+	start := len(chunk) - n
+	for i := start; i < len(chunk) && i < start+n/2; i++ {
+		if unicode.IsSpace(chunk[i]) {
+			start = i + 1
+			break
+		}
+	}
+	return append([]rune(nil), chunk[start:]...)
+}
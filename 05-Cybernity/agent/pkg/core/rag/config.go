@@ -0,0 +1,166 @@
+package rag
+
+import (
+	"cybernity/pkg/core/llm"
+	"fmt"
+	"sync"
+)
+
+const (
+	// EmbedderHash embeds text locally by feature hashing, needing no provider
+	EmbedderHash = "hash"
+	// EmbedderLLM embeds text with the embeddings model of an LLM client
+	EmbedderLLM = "llm"
+)
+
+type Config struct {
+	// ChunkSize and ChunkOverlap are in characters
+	ChunkSize    int `yaml:"chunk_size"`
+	ChunkOverlap int `yaml:"chunk_overlap"`
+	// TopK chunks most relevant to a question are put in the prompt
+	TopK     int     `yaml:"top_k"`
+	MinScore float64 `yaml:"min_score"`
+	Embedder string  `yaml:"embedder"`
+	// Dimensions of the vectors of the hash embedder
+	Dimensions int `yaml:"dimensions"`
+	// EmbeddingClient is the key of the LLM client the llm embedder uses
+	EmbeddingClient string `yaml:"embedding_client"`
+	// IndexWorkers index uploaded knowledge in the background, taking agents from a queue of IndexQueueSize
+	IndexWorkers   int `yaml:"index_workers"`
+	IndexQueueSize int `yaml:"index_queue_size"`
+}
+
+var (
+	config   = DefaultConfig()
+	configMu sync.RWMutex
+)
+
+// DefaultConfig returns a default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		ChunkSize:    1200,
+		ChunkOverlap: 200,
+		TopK:         5,
+		Embedder:     EmbedderLLM,
+		Dimensions:   1024,
+		// Same key as the chat client, unless a dedicated embedding client is registered
+		EmbeddingClient: llm.DefaultClientKey,
+		IndexWorkers:    2,
+		IndexQueueSize:  64,
+	}
+}
+
+// MergeDefault merges the default configuration with the current configuration
+func (c *Config) MergeDefault() *Config {
+	def := DefaultConfig()
+	if c.ChunkSize == 0 {
+		c.ChunkSize = def.ChunkSize
+	}
+	if c.ChunkOverlap == 0 {
+		c.ChunkOverlap = def.ChunkOverlap
+	}
+	if c.TopK == 0 {
+		c.TopK = def.TopK
+	}
+	if c.Embedder == "" {
+		c.Embedder = def.Embedder
+	}
+	if c.Dimensions == 0 {
+		c.Dimensions = def.Dimensions
+	}
+	if c.EmbeddingClient == "" {
+		c.EmbeddingClient = def.EmbeddingClient
+	}
+	if c.IndexWorkers == 0 {
+		c.IndexWorkers = def.IndexWorkers
+	}
+	if c.IndexQueueSize == 0 {
+		c.IndexQueueSize = def.IndexQueueSize
+	}
+	return c
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	if c.ChunkSize <= 0 {
+		return fmt.Errorf("chunk size must be greater than 0")
+	}
+	if c.ChunkOverlap < 0 || c.ChunkOverlap >= c.ChunkSize {
+		return fmt.Errorf("chunk overlap must be between 0 and the chunk size")
+	}
+	if c.TopK <= 0 {
+		return fmt.Errorf("top k must be greater than 0")
+	}
+	if c.MinScore < -1 || c.MinScore > 1 {
+		return fmt.Errorf("min score must be between -1 and 1")
+	}
+	if c.Embedder != EmbedderHash && c.Embedder != EmbedderLLM {
+		return fmt.Errorf("unknown embedder %q", c.Embedder)
+	}
+	if c.Dimensions <= 0 {
+		return fmt.Errorf("dimensions must be greater than 0")
+	}
+	if c.IndexWorkers <= 0 {
+		return fmt.Errorf("index workers must be greater than 0")
+	}
+	if c.IndexQueueSize <= 0 {
+		return fmt.Errorf("index queue size must be greater than 0")
+	}
+	return nil
+}
+
+// InitWithConfig sets how knowledge is chunked, embedded and retrieved
+func InitWithConfig(cfg *Config) error {
+	cfg.MergeDefault()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = cfg
+	return nil
+}
+
+// GetConfig returns how knowledge is chunked, embedded and retrieved
+func GetConfig() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// GetEmbedder returns the configured embedder
+func GetEmbedder() (Embedder, error) {
+	cfg := GetConfig()
+	if cfg.Embedder != EmbedderLLM {
+		return NewHashEmbedder(cfg.Dimensions), nil
+	}
+
+	client, err := llm.GetEmbeddingClient(cfg.EmbeddingClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embedding client: %w", err)
+	}
+	return NewLLMEmbedder(client), nil
+}
+
+// GetRouteEmbedder returns the embedder of agents pinned to an LLM route, whose knowledge must not leave it:
+// the embeddings model of the first self-hosted client of the route that has one, or the hash embedder
+func GetRouteEmbedder(route string) (Embedder, error) {
+	cfg := GetConfig()
+	router := llm.GetRouter()
+	if cfg.Embedder != EmbedderLLM || router == nil {
+		return NewHashEmbedder(cfg.Dimensions), nil
+	}
+
+	for _, key := range router.Clients(route) {
+		client, err := llm.GetEmbeddingClient(key)
+		if err != nil {
+			continue
+		}
+		// A dedicated embedding client may be remote even when the chat client of the key isn't
+		if config := client.GetConfig(); llm.IsLocal(config.Provider) && config.EmbeddingModel != "" {
+			return NewLLMEmbedder(client), nil
+		}
+	}
+	return NewHashEmbedder(cfg.Dimensions), nil
+}
@@ -0,0 +1,156 @@
+package rag
+
+import (
+	"context"
+	"cybernity/pkg/core/llm"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Embedder turns texts into vectors whose cosine similarity reflects how related the texts are
+type Embedder interface {
+	// Name identifies the embedder and its parameters, indexes built by another embedder can't be searched
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// HashEmbedder embeds the terms of a text by feature hashing. It needs no model, so it matches
+// words rather than meaning, but it works offline and for any language.
+type HashEmbedder struct {
+	dimensions int
+}
+
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+func (e *HashEmbedder) Name() string {
+	return EmbedderHash + "-" + strconv.Itoa(e.dimensions)
+}
+
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	counts := make(map[string]int)
+	for _, term := range Terms(text) {
+		counts[term]++
+	}
+
+	vector := make([]float32, e.dimensions)
+	for term, count := range counts {
+		h := fnv.New32a()
+		h.Write([]byte(term))
+		sum := h.Sum32()
+		// The sign bit spreads collisions around zero instead of piling them up
+		weight := float32(1 + math.Log(float64(count)))
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vector[int(sum%uint32(e.dimensions))] += weight
+	}
+	return normalize(vector)
+}
+
+// LLMEmbedder embeds text with the embeddings model of an LLM client
+type LLMEmbedder struct {
+	client llm.Client
+}
+
+func NewLLMEmbedder(client llm.Client) *LLMEmbedder {
+	return &LLMEmbedder{client: client}
+}
+
+func (e *LLMEmbedder) Name() string {
+	return EmbedderLLM + "-" + e.client.GetConfig().EmbeddingModel
+}
+
+func (e *LLMEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	resp, err := e.client.Embeddings(ctx, llm.EmbeddingRequest{Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(resp.Data), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, d := range resp.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// Terms returns the lowercased words of a text. Scripts written without spaces, like Chinese or Japanese,
+// give overlapping character pairs instead.
+func Terms(text string) []string {
+	var (
+		terms []string
+		word  []rune
+		ideo  []rune
+	)
+	flushWord := func() {
+		if len(word) > 0 {
+			terms = append(terms, string(word))
+			word = word[:0]
+		}
+	}
+	flushIdeo := func() {
+		switch {
+		case len(ideo) == 1:
+			terms = append(terms, string(ideo))
+		case len(ideo) > 1:
+			for i := 0; i+1 < len(ideo); i++ {
+				terms = append(terms, string(ideo[i:i+2]))
+			}
+		}
+		ideo = ideo[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai):
+			flushWord()
+			ideo = append(ideo, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			flushIdeo()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushIdeo()
+		}
+	}
+	flushWord()
+	flushIdeo()
+	return terms
+}
+
+// normalize scales a vector to unit length, so cosine similarity is a dot product
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
@@ -0,0 +1,104 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Index holds the chunks of an agent's knowledge and their vectors
+type Index struct {
+	Embedder string      `json:"embedder"`
+	Chunks   []Chunk     `json:"chunks"`
+	Vectors  [][]float32 `json:"vectors"`
+}
+
+// Result is a chunk retrieved for a question
+type Result struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// Build embeds the chunks into an index
+func Build(ctx context.Context, embedder Embedder, chunks []Chunk) (*Index, error) {
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed chunks: %w", err)
+	}
+	if len(vectors) != len(chunks) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d chunks", len(vectors), len(chunks))
+	}
+	return &Index{Embedder: embedder.Name(), Chunks: chunks, Vectors: vectors}, nil
+}
+
+// Search returns the k chunks most similar to the query vector scoring at least minScore, best first
+func (idx *Index) Search(query []float32, k int, minScore float64) []Result {
+	results := make([]Result, 0, len(idx.Chunks))
+	for i, v := range idx.Vectors {
+		score := cosine(query, v)
+		if score < minScore {
+			continue
+		}
+		results = append(results, Result{Chunk: idx.Chunks[i], Score: score})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// Retrieve embeds the question with the index's embedder and returns the k most relevant chunks
+func (idx *Index) Retrieve(ctx context.Context, embedder Embedder, question string, k int, minScore float64) ([]Result, error) {
+	if embedder.Name() != idx.Embedder {
+		return nil, fmt.Errorf("index was built by %s, not %s", idx.Embedder, embedder.Name())
+	}
+	vectors, err := embedder.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedder returned %d vectors for the question", len(vectors))
+	}
+	return idx.Search(vectors[0], k, minScore), nil
+}
+
+func (idx *Index) Marshal() ([]byte, error) {
+	return json.Marshal(idx)
+}
+
+func Unmarshal(data []byte) (*Index, error) {
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("invalid index: %w", err)
+	}
+	if len(idx.Chunks) != len(idx.Vectors) {
+		return nil, fmt.Errorf("invalid index: %d chunks but %d vectors", len(idx.Chunks), len(idx.Vectors))
+	}
+	return &idx, nil
+}
+
+// cosine returns the cosine similarity of two vectors, 0 when their dimensions differ
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
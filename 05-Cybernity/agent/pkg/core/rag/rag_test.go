@@ -0,0 +1,149 @@
+package rag
+
+import (
+	"context"
+	"cybernity/pkg/core/knowledge"
+	"cybernity/pkg/core/llm"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit(t *testing.T) {
+	paras := []string{
+		strings.Repeat("alpha ", 30),
+		strings.Repeat("beta ", 30),
+		strings.Repeat("gamma ", 30),
+		strings.Repeat("delta ", 100),
+	}
+	docs := []knowledge.Document{
+		{Name: "a.md", Content: []byte(strings.Join(paras, "\n\n"))},
+		{Name: "b.md", Content: []byte("short")},
+	}
+
+	chunks := Split(docs, 300, 50)
+	var last int
+	for i, c := range chunks {
+		if n := utf8.RuneCountInString(c.Text); n > 300 {
+			t.Errorf("chunk %d has %d characters, want at most 300", i, n)
+		}
+		if c.Document == "b.md" {
+			last = i
+		}
+	}
+	if chunks[0].Document != "a.md" || chunks[0].Index != 0 || !strings.HasPrefix(chunks[0].Text, "alpha") {
+		t.Errorf("first chunk = %+v", chunks[0])
+	}
+	if got := chunks[last]; got.Text != "short" || got.Index != 0 || got.Source() != "b.md#1" {
+		t.Errorf("chunk of b.md = %+v", got)
+	}
+
+	// Consecutive chunks overlap
+	for i := 1; i < len(chunks) && chunks[i].Document == "a.md"; i++ {
+		prev := chunks[i-1].Text
+		if !strings.Contains(prev, strings.Fields(chunks[i].Text)[0]) {
+			t.Errorf("chunk %d does not start with the end of chunk %d", i, i-1)
+		}
+	}
+
+	joined := ""
+	for _, c := range chunks {
+		joined += c.Text
+	}
+	for _, word := range []string{"alpha", "beta", "gamma", "delta", "short"} {
+		if !strings.Contains(joined, word) {
+			t.Errorf("chunks lost %q", word)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := Terms("Hello, World! 知识付费 v2")
+	want := []string{"hello", "world", "知识", "识付", "付费", "v2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Terms() = %q, want %q", got, want)
+	}
+}
+
+func TestRetrieve(t *testing.T) {
+	ctx := context.Background()
+	embedder := NewHashEmbedder(256)
+	chunks := []Chunk{
+		{Document: "pricing.md", Text: "The pro plan costs 100 dollars per year and includes support."},
+		{Document: "install.md", Text: "Install the agent with docker compose and set the API key."},
+		{Document: "faq.md", Text: "退款政策：购买后七天内可以申请退款。"},
+	}
+	index, err := Build(ctx, embedder, chunks)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	data, err := index.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	index, err = Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	tests := []struct {
+		question string
+		document string
+	}{
+		{"How much does the pro plan cost?", "pricing.md"},
+		{"how do I install it with docker", "install.md"},
+		{"怎么申请退款", "faq.md"},
+	}
+	for _, tt := range tests {
+		results, err := index.Retrieve(ctx, embedder, tt.question, 1, 0.01)
+		if err != nil {
+			t.Fatalf("Retrieve() error = %v", err)
+		}
+		if len(results) != 1 || results[0].Document != tt.document {
+			t.Errorf("Retrieve(%q) = %+v, want %s", tt.question, results, tt.document)
+		}
+	}
+
+	if results, _ := index.Retrieve(ctx, embedder, "unrelated zebra", 3, 0.01); len(results) != 0 {
+		t.Errorf("Retrieve() = %+v, want no chunk above the min score", results)
+	}
+	if _, err := index.Retrieve(ctx, NewHashEmbedder(128), "cost", 1, 0); err == nil {
+		t.Error("Retrieve() accepted another embedder")
+	}
+}
+
+func TestGetRouteEmbedder(t *testing.T) {
+	err := llm.InitWithConfig(&llm.LLMConfig{
+		Configs: map[string]*llm.Config{
+			llm.DefaultClientKey: {APIKey: "key"},
+			"local":              {Provider: llm.ProviderOllama, Model: "llama3.1:8b", EmbeddingModel: "nomic-embed-text"},
+			"chat":               {Provider: llm.ProviderOllama, Model: "llama3.1:8b"},
+			"leaky":              {Provider: llm.ProviderOllama, Model: "llama3.1:8b"},
+		},
+		// The chat clients of leaky are self-hosted, its embeddings aren't
+		Embeddings: map[string]*llm.Config{"leaky": {APIKey: "key"}},
+		Router: llm.RouterConfig{Routes: map[string][]llm.Tier{
+			"pinned": {{Clients: []llm.Target{{Key: "chat"}}}, {Clients: []llm.Target{{Key: "local"}}}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("llm.InitWithConfig() error = %v", err)
+	}
+
+	for route, want := range map[string]string{
+		"pinned": "llm-nomic-embed-text",
+		"local":  "llm-nomic-embed-text",
+		"chat":   "hash-1024",
+		"leaky":  "hash-1024",
+	} {
+		embedder, err := GetRouteEmbedder(route)
+		if err != nil {
+			t.Fatalf("GetRouteEmbedder(%s) error = %v", route, err)
+		}
+		if embedder.Name() != want {
+			t.Errorf("GetRouteEmbedder(%s) = %s, want %s", route, embedder.Name(), want)
+		}
+	}
+}
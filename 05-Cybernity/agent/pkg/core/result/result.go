@@ -44,6 +44,18 @@ func UError(c *gin.Context, msg string) {
 	c.Abort()
 }
 
+// UErrorData fails with details a client can act on, e.g. a validation error code
+func UErrorData(c *gin.Context, msg string, data interface{}) {
+	traceID, _ := c.Get("trace_id")
+	c.JSON(http.StatusOK, Result{
+		Code:    FailCode,
+		Message: msg,
+		Data:    data,
+		TraceID: traceID,
+	})
+	c.Abort()
+}
+
 func Error(c *gin.Context, errno *Errno) {
 	traceID, _ := c.Get("trace_id")
 	c.JSON(http.StatusOK, Result{
@@ -0,0 +1,146 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// Each share is the secret-sized y values followed by a one byte x coordinate.
+
+var (
+	ErrInvalidThreshold = errors.New("threshold must be at least 2 and no more than the number of shares")
+	ErrTooManyShares    = errors.New("at most 255 shares are supported")
+	ErrEmptySecret      = errors.New("secret cannot be empty")
+	ErrNotEnoughShares  = errors.New("at least 2 shares are required")
+	ErrInvalidShares    = errors.New("shares must be the same length and at least 2 bytes")
+	ErrDuplicateShare   = errors.New("duplicate share")
+)
+
+// Split splits secret into n shares, any threshold of which reconstruct it
+func Split(secret []byte, n, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+	if n > 255 {
+		return nil, ErrTooManyShares
+	}
+	if threshold < 2 || threshold > n {
+		return nil, ErrInvalidThreshold
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+
+	// One random polynomial of degree threshold-1 per secret byte, with the byte as its constant term
+	coefficients := make([]byte, threshold)
+	for idx, b := range secret {
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate coefficients: %w", err)
+		}
+		coefficients[0] = b
+		for i := range shares {
+			shares[i][idx] = evaluate(coefficients, byte(i+1))
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine reconstructs the secret from at least threshold shares produced by Split.
+// Passing fewer shares than the threshold yields an unrelated value rather than an error.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrNotEnoughShares
+	}
+	size := len(shares[0])
+	if size < 2 {
+		return nil, ErrInvalidShares
+	}
+
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]bool, len(shares))
+	for i, share := range shares {
+		if len(share) != size {
+			return nil, ErrInvalidShares
+		}
+		x := share[size-1]
+		if x == 0 || seen[x] {
+			return nil, ErrDuplicateShare
+		}
+		seen[x] = true
+		xs[i] = x
+	}
+
+	secret := make([]byte, size-1)
+	ys := make([]byte, len(shares))
+	for idx := range secret {
+		for i, share := range shares {
+			ys[i] = share[idx]
+		}
+		secret[idx] = interpolateAtZero(xs, ys)
+	}
+	return secret, nil
+}
+
+// evaluate evaluates the polynomial at x using Horner's method
+func evaluate(coefficients []byte, x byte) byte {
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = add(mul(result, x), coefficients[i])
+	}
+	return result
+}
+
+// interpolateAtZero computes the Lagrange interpolation of the points at x = 0
+func interpolateAtZero(xs, ys []byte) byte {
+	var result byte
+	for i := range xs {
+		basis := byte(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			basis = mul(basis, div(xs[j], add(xs[i], xs[j])))
+		}
+		result = add(result, mul(ys[i], basis))
+	}
+	return result
+}
+
+// GF(2^8) arithmetic with the AES polynomial x^8 + x^4 + x^3 + x + 1
+
+func add(a, b byte) byte {
+	return a ^ b
+}
+
+// mul multiplies in constant time so shares don't leak through timing
+func mul(a, b byte) byte {
+	var result byte
+	for i := 0; i < 8; i++ {
+		result ^= byte(subtle.ConstantTimeByteEq(b&1, 1)) * a
+		carry := byte(subtle.ConstantTimeByteEq(a&0x80, 0x80))
+		a <<= 1
+		a ^= carry * 0x1b
+		b >>= 1
+	}
+	return result
+}
+
+// inverse returns a^254, which is a^-1 for non-zero a
+func inverse(a byte) byte {
+	result := a
+	for i := 0; i < 6; i++ {
+		result = mul(result, result)
+		result = mul(result, a)
+	}
+	return mul(result, result)
+}
+
+func div(a, b byte) byte {
+	return mul(a, inverse(b))
+}
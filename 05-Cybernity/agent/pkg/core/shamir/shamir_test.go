@@ -0,0 +1,63 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInverse(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if got := mul(byte(a), inverse(byte(a))); got != 1 {
+			t.Fatalf("mul(%d, inverse(%d)) = %d, want 1", a, a, got)
+		}
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte(`{"encryption_private_key":"...","blockchain_private_key":"..."}`)
+
+	shares, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+
+	subsets := [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}}
+	for _, subset := range subsets {
+		var parts [][]byte
+		for _, i := range subset {
+			parts = append(parts, shares[i])
+		}
+		got, err := Combine(parts)
+		if err != nil {
+			t.Fatalf("Combine(%v) error = %v", subset, err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("Combine(%v) = %q, want %q", subset, got, secret)
+		}
+	}
+
+	got, err := Combine(shares[:2])
+	if err != nil {
+		t.Fatalf("Combine() error = %v", err)
+	}
+	if bytes.Equal(got, secret) {
+		t.Error("Combine() reconstructed the secret from fewer shares than the threshold")
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	if _, err := Split([]byte("secret"), 3, 4); err != ErrInvalidThreshold {
+		t.Errorf("Split() error = %v, want %v", err, ErrInvalidThreshold)
+	}
+	if _, err := Split([]byte("secret"), 3, 1); err != ErrInvalidThreshold {
+		t.Errorf("Split() error = %v, want %v", err, ErrInvalidThreshold)
+	}
+	if _, err := Split(nil, 3, 2); err != ErrEmptySecret {
+		t.Errorf("Split() error = %v, want %v", err, ErrEmptySecret)
+	}
+
+	shares, _ := Split([]byte("secret"), 3, 2)
+	if _, err := Combine([][]byte{shares[0], shares[0]}); err != ErrDuplicateShare {
+		t.Errorf("Combine() error = %v, want %v", err, ErrDuplicateShare)
+	}
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	cidVersion1 = 0x01
+
+	CodecRaw    = 0x55
+	CodecDagPB  = 0x70
+	HashSHA2256 = 0x12
+	HashID      = 0x00 // identity, the digest is the content itself
+)
+
+var (
+	ErrInvalidCID      = errors.New("invalid cid")
+	ErrContentMismatch = errors.New("content does not match cid")
+)
+
+var base32Lower = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// CID is a parsed content identifier
+type CID struct {
+	Version  int
+	Codec    uint64
+	HashCode uint64
+	Digest   []byte
+}
+
+// RawCID returns the CIDv1 (raw codec, sha2-256) of content stored as a single block
+func RawCID(content []byte) string {
+	digest := sha256.Sum256(content)
+	c := &CID{Version: 1, Codec: CodecRaw, HashCode: HashSHA2256, Digest: digest[:]}
+	return c.String()
+}
+
+// ParseCID parses a CIDv0 (base58btc "Qm...") or a multibase encoded CIDv1
+func ParseCID(s string) (*CID, error) {
+	if len(s) == 46 && strings.HasPrefix(s, "Qm") {
+		b, err := decodeBase58(s)
+		if err != nil {
+			return nil, err
+		}
+		return DecodeCID(b)
+	}
+	if len(s) < 2 {
+		return nil, ErrInvalidCID
+	}
+
+	var b []byte
+	var err error
+	switch s[0] {
+	case 'b':
+		b, err = base32Lower.DecodeString(strings.ToUpper(s[1:]))
+	case 'B':
+		b, err = base32Lower.DecodeString(s[1:])
+	case 'z':
+		b, err = decodeBase58(s[1:])
+	default:
+		return nil, fmt.Errorf("%w: unsupported multibase %q", ErrInvalidCID, s[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCID, err)
+	}
+	return DecodeCID(b)
+}
+
+// DecodeCID decodes the binary form of a CID, as found in dag-pb links
+func DecodeCID(b []byte) (*CID, error) {
+	// A CIDv0 is a bare sha2-256 multihash
+	if len(b) == 34 && b[0] == HashSHA2256 && b[1] == 32 {
+		return &CID{Version: 0, Codec: CodecDagPB, HashCode: HashSHA2256, Digest: b[2:]}, nil
+	}
+
+	version, n := binary.Uvarint(b)
+	if n <= 0 || version != cidVersion1 {
+		return nil, fmt.Errorf("%w: unsupported version", ErrInvalidCID)
+	}
+	b = b[n:]
+	codec, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, ErrInvalidCID
+	}
+	b = b[n:]
+	hashCode, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, ErrInvalidCID
+	}
+	b = b[n:]
+	length, n := binary.Uvarint(b)
+	if n <= 0 || uint64(len(b[n:])) != length {
+		return nil, fmt.Errorf("%w: bad multihash length", ErrInvalidCID)
+	}
+	return &CID{Version: 1, Codec: codec, HashCode: hashCode, Digest: b[n:]}, nil
+}
+
+// Bytes returns the binary form of the CID
+func (c *CID) Bytes() []byte {
+	multihash := binary.AppendUvarint(nil, c.HashCode)
+	multihash = binary.AppendUvarint(multihash, uint64(len(c.Digest)))
+	multihash = append(multihash, c.Digest...)
+	if c.Version == 0 {
+		return multihash
+	}
+
+	b := binary.AppendUvarint(nil, cidVersion1)
+	b = binary.AppendUvarint(b, c.Codec)
+	return append(b, multihash...)
+}
+
+// String returns the canonical string form, base58btc for CIDv0 and base32 for CIDv1
+func (c *CID) String() string {
+	if c.Version == 0 {
+		return encodeBase58(c.Bytes())
+	}
+	// "b" is the multibase prefix for lowercase base32
+	return "b" + strings.ToLower(base32Lower.EncodeToString(c.Bytes()))
+}
+
+// Verify checks that block hashes to the CID's multihash
+func (c *CID) Verify(block []byte) error {
+	switch c.HashCode {
+	case HashSHA2256:
+		digest := sha256.Sum256(block)
+		if !bytes.Equal(digest[:], c.Digest) {
+			return fmt.Errorf("%w: %s", ErrContentMismatch, c)
+		}
+	case HashID:
+		if !bytes.Equal(block, c.Digest) {
+			return fmt.Errorf("%w: %s", ErrContentMismatch, c)
+		}
+	default:
+		return fmt.Errorf("unsupported multihash 0x%x in %s", c.HashCode, c)
+	}
+	return nil
+}
+
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		idx := strings.IndexRune(base58Alphabet, r)
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: invalid base58 character %q", ErrInvalidCID, r)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(idx)))
+	}
+
+	// Leading '1's encode leading zero bytes
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+func encodeBase58(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, '1')
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	BackendPinata = "pinata"
+	BackendKubo   = "kubo"
+	BackendLocal  = "local"
+)
+
+type Config struct {
+	Backend    string          `yaml:"backend"` // pinata, kubo or local, defaults to pinata
+	Timeout    time.Duration   `yaml:"timeout"`
+	SkipVerify bool            `yaml:"skip_verify"` // don't check downloaded content against its CID
+	Kubo       KuboConfig      `yaml:"kubo"`
+	Local      LocalConfig     `yaml:"local"`
+	Gateways   GatewayConfig   `yaml:"gateways"` // when set, content is read through these gateways instead of the backend
+	Pins       PinPolicyConfig `yaml:"pins"`
+}
+
+type KuboConfig struct {
+	APIURL     string `yaml:"api_url"`     // RPC API of the node, e.g. http://127.0.0.1:5001
+	GatewayURL string `yaml:"gateway_url"` // optional, Get uses the RPC API when empty
+}
+
+type LocalConfig struct {
+	Path string `yaml:"path"`
+}
+
+// DefaultConfig returns a default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Backend: BackendPinata,
+		Timeout: 60 * time.Second,
+		Local: LocalConfig{
+			Path: "./deployment/storage",
+		},
+		Gateways: GatewayConfig{
+			Strategy: GatewayStrategySequential,
+			Timeout:  20 * time.Second,
+		},
+	}
+}
+
+// MergeDefault merges the default configuration with the current configuration
+func (c *Config) MergeDefault() *Config {
+	def := DefaultConfig()
+	if c.Backend == "" {
+		c.Backend = def.Backend
+	}
+	if c.Timeout == 0 {
+		c.Timeout = def.Timeout
+	}
+	if c.Local.Path == "" {
+		c.Local.Path = def.Local.Path
+	}
+	if c.Gateways.Strategy == "" {
+		c.Gateways.Strategy = def.Gateways.Strategy
+	}
+	if c.Gateways.Timeout == 0 {
+		c.Gateways.Timeout = def.Gateways.Timeout
+	}
+	c.Pins.mergeDefault()
+	return c
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	switch c.Backend {
+	case BackendPinata, BackendLocal:
+	case BackendKubo:
+		if c.Kubo.APIURL == "" {
+			return fmt.Errorf("kubo api url is required")
+		}
+	default:
+		return fmt.Errorf("unknown storage backend: %s", c.Backend)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0")
+	}
+	switch c.Gateways.Strategy {
+	case GatewayStrategySequential, GatewayStrategyParallel:
+	default:
+		return fmt.Errorf("unknown gateway strategy: %s", c.Gateways.Strategy)
+	}
+	if c.Gateways.Timeout <= 0 {
+		return fmt.Errorf("gateway timeout must be greater than 0")
+	}
+	return c.Pins.validate()
+}
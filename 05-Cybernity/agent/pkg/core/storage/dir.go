@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// unixfsHAMTShard is the UnixFS type of sharded directories, which are not supported
+const unixfsHAMTShard = 5
+
+// ValidFileName reports whether name can be used as a directory entry
+func ValidFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// checkFiles validates directory entries before they are uploaded
+func checkFiles(files []File) error {
+	if len(files) == 0 {
+		return errors.New("directory has no files")
+	}
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		if !ValidFileName(f.Name) {
+			return fmt.Errorf("invalid file name %q", f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate file name %q", f.Name)
+		}
+		seen[f.Name] = true
+	}
+	return nil
+}
+
+// ResolveVerified returns the CID of the named entry of the directory at root,
+// checking the directory block against its CID.
+func ResolveVerified(ctx context.Context, root, name string, getBlock BlockGetter) (*CID, error) {
+	c, err := ParseCID(root)
+	if err != nil {
+		return nil, err
+	}
+	if c.Codec != CodecDagPB {
+		return nil, fmt.Errorf("%s is not a directory", c)
+	}
+
+	block, err := getBlock(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Verify(block); err != nil {
+		return nil, err
+	}
+	node, err := decodePBNode(block)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dag-pb block %s: %w", c, err)
+	}
+	fsType, _, err := decodeUnixFSData(node.data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unixfs data in %s: %w", c, err)
+	}
+	switch fsType {
+	case unixfsDirectory:
+	case unixfsHAMTShard:
+		return nil, fmt.Errorf("sharded directory %s is not supported", c)
+	default:
+		return nil, fmt.Errorf("%s is not a directory (unixfs type %d)", c, fsType)
+	}
+
+	for _, link := range node.links {
+		if link.name == name {
+			return DecodeCID(link.hash)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s/%s", ErrNotFound, root, name)
+}
+
+// ReadFileVerified reads the named file of the directory at root, verifying every block
+func ReadFileVerified(ctx context.Context, root, name string, getBlock BlockGetter) ([]byte, error) {
+	child, err := ResolveVerified(ctx, root, name, getBlock)
+	if err != nil {
+		return nil, err
+	}
+	return ReadVerified(ctx, child.String(), getBlock)
+}
+
+// encodeDirectory builds a dag-pb UnixFS directory node linking to the given entries.
+// Links are sorted by name as dag-pb requires.
+func encodeDirectory(links []pbLink) []byte {
+	sorted := make([]pbLink, len(links))
+	copy(sorted, links)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+	// PBNode encodes Links before Data even though Data has the lower field number
+	var node []byte
+	for _, l := range sorted {
+		link := protowire.AppendTag(nil, 1, protowire.BytesType)
+		link = protowire.AppendBytes(link, l.hash)
+		link = protowire.AppendTag(link, 2, protowire.BytesType)
+		link = protowire.AppendString(link, l.name)
+		link = protowire.AppendTag(link, 3, protowire.VarintType)
+		link = protowire.AppendVarint(link, l.size)
+		node = protowire.AppendTag(node, 2, protowire.BytesType)
+		node = protowire.AppendBytes(node, link)
+	}
+
+	fsData := protowire.AppendTag(nil, 1, protowire.VarintType)
+	fsData = protowire.AppendVarint(fsData, unixfsDirectory)
+	node = protowire.AppendTag(node, 1, protowire.BytesType)
+	return protowire.AppendBytes(node, fsData)
+}
+
+// dagPBCID returns the CIDv1 (dag-pb, sha2-256) of a dag-pb block
+func dagPBCID(block []byte) string {
+	digest := sha256.Sum256(block)
+	c := &CID{Version: 1, Codec: CodecDagPB, HashCode: HashSHA2256, Digest: digest[:]}
+	return c.String()
+}
+
+// ipfsPath returns the escaped gateway path of a CID, or of a file in the directory at the CID
+func ipfsPath(cid, name string) string {
+	p := "/ipfs/" + url.PathEscape(cid)
+	if name != "" {
+		p += "/" + url.PathEscape(name)
+	}
+	return p
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStorage stores content on the local filesystem under its raw CID, for development and tests
+type FileStorage struct {
+	dir string
+}
+
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &FileStorage{dir: dir}, nil
+}
+
+func (s *FileStorage) Put(ctx context.Context, name string, content []byte) (string, error) {
+	cid := RawCID(content)
+	if err := os.WriteFile(s.path(cid), content, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return cid, nil
+}
+
+func (s *FileStorage) Get(ctx context.Context, cid string) ([]byte, error) {
+	if !validLocalCID(cid) {
+		return nil, ErrNotFound
+	}
+	content, err := os.ReadFile(s.path(cid))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := ParseCID(cid)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Verify(content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// PutDirectory stores every file under its raw CID and a UnixFS directory node linking to them
+func (s *FileStorage) PutDirectory(ctx context.Context, files []File) (string, error) {
+	if err := checkFiles(files); err != nil {
+		return "", err
+	}
+
+	links := make([]pbLink, len(files))
+	for i, f := range files {
+		cid, err := s.Put(ctx, f.Name, f.Content)
+		if err != nil {
+			return "", err
+		}
+		c, err := ParseCID(cid)
+		if err != nil {
+			return "", err
+		}
+		links[i] = pbLink{hash: c.Bytes(), name: f.Name, size: uint64(len(f.Content))}
+	}
+
+	node := encodeDirectory(links)
+	root := dagPBCID(node)
+	if err := os.WriteFile(s.path(root), node, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write directory: %w", err)
+	}
+	return root, nil
+}
+
+func (s *FileStorage) GetFile(ctx context.Context, root, name string) ([]byte, error) {
+	return ReadFileVerified(ctx, root, name, func(ctx context.Context, c *CID) ([]byte, error) {
+		return s.Get(ctx, c.String())
+	})
+}
+
+func (s *FileStorage) Stat(ctx context.Context, cid string) (*Object, error) {
+	if !validLocalCID(cid) {
+		return nil, ErrNotFound
+	}
+	info, err := os.Stat(s.path(cid))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Object{CID: cid, Size: info.Size()}, nil
+}
+
+func (s *FileStorage) Delete(ctx context.Context, cid string) error {
+	if !validLocalCID(cid) {
+		return ErrNotFound
+	}
+	err := os.Remove(s.path(cid))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *FileStorage) path(cid string) string {
+	return filepath.Join(s.dir, cid)
+}
+
+// validLocalCID guards against path traversal through the CID
+func validLocalCID(cid string) bool {
+	return cid != "" && !strings.ContainsAny(cid, `/\.`)
+}
@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GatewayStrategySequential = "sequential"
+	GatewayStrategyParallel   = "parallel"
+
+	// gatewayLatencyWeight is the EWMA weight given to the latest request
+	gatewayLatencyWeight = 0.3
+	// gatewayFailureThreshold consecutive failures demote a gateway behind all healthy ones
+	gatewayFailureThreshold = 3
+	// gatewayRecentFetches is how many fetches are kept for metrics
+	gatewayRecentFetches = 100
+)
+
+type GatewayConfig struct {
+	URLs     []string      `yaml:"urls"`     // in order of preference
+	Strategy string        `yaml:"strategy"` // sequential or parallel, defaults to sequential
+	Timeout  time.Duration `yaml:"timeout"`  // per gateway request
+}
+
+// GatewayStat reports the health of one gateway
+type GatewayStat struct {
+	URL                 string        `json:"url"`
+	Served              int64         `json:"served"`
+	Failures            int64         `json:"failures"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	AvgLatency          time.Duration `json:"avg_latency"`
+	Healthy             bool          `json:"healthy"`
+}
+
+// GatewayFetch records which gateway served a CID
+type GatewayFetch struct {
+	CID     string        `json:"cid"`
+	Gateway string        `json:"gateway"`
+	Latency time.Duration `json:"latency"`
+	At      time.Time     `json:"at"`
+}
+
+type gatewayHealth struct {
+	url                 string
+	order               int
+	served              int64
+	failures            int64
+	consecutiveFailures int
+	avgLatency          time.Duration
+}
+
+func (h *gatewayHealth) healthy() bool {
+	return h.consecutiveFailures < gatewayFailureThreshold
+}
+
+// GatewayFetcher retrieves content from an ordered list of IPFS gateways with failover,
+// preferring gateways that have recently been fast and reliable.
+type GatewayFetcher struct {
+	cfg        *GatewayConfig
+	httpClient *http.Client
+	verify     bool
+	mu         sync.Mutex
+	gateways   []*gatewayHealth
+	recent     []GatewayFetch
+}
+
+func NewGatewayFetcher(cfg *GatewayConfig, httpClient *http.Client, verify bool) *GatewayFetcher {
+	f := &GatewayFetcher{cfg: cfg, httpClient: httpClient, verify: verify}
+	for i, u := range cfg.URLs {
+		gateway := strings.TrimSuffix(strings.TrimSuffix(u, "/"), "/ipfs")
+		f.gateways = append(f.gateways, &gatewayHealth{url: gateway, order: i})
+	}
+	return f
+}
+
+// Fetch returns the content for cid and the gateway that served it
+func (f *GatewayFetcher) Fetch(ctx context.Context, cid string) ([]byte, string, error) {
+	return f.fetch(ctx, cid, "")
+}
+
+// FetchFile returns the named file of the directory at root and the gateway that served it
+func (f *GatewayFetcher) FetchFile(ctx context.Context, root, name string) ([]byte, string, error) {
+	return f.fetch(ctx, root, name)
+}
+
+func (f *GatewayFetcher) fetch(ctx context.Context, cid, name string) ([]byte, string, error) {
+	gateways := f.ranked()
+	if len(gateways) == 0 {
+		return nil, "", errors.New("no gateways configured")
+	}
+
+	if f.cfg.Strategy == GatewayStrategyParallel {
+		return f.fetchParallel(ctx, cid, name, gateways)
+	}
+	return f.fetchSequential(ctx, cid, name, gateways)
+}
+
+func (f *GatewayFetcher) fetchSequential(ctx context.Context, cid, name string, gateways []string) ([]byte, string, error) {
+	var errs []error
+	for _, gateway := range gateways {
+		content, err := f.fetchOne(ctx, gateway, cid, name)
+		if err == nil {
+			return content, gateway, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", gateway, err))
+	}
+	return nil, "", f.fetchError(errs)
+}
+
+func (f *GatewayFetcher) fetchParallel(ctx context.Context, cid, name string, gateways []string) ([]byte, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		gateway string
+		content []byte
+		err     error
+	}
+	results := make(chan result, len(gateways))
+	for _, gateway := range gateways {
+		go func(gateway string) {
+			content, err := f.fetchOne(ctx, gateway, cid, name)
+			results <- result{gateway: gateway, content: content, err: err}
+		}(gateway)
+	}
+
+	var errs []error
+	for range gateways {
+		r := <-results
+		if r.err == nil {
+			// Cancel the slower requests, they are not counted against their gateways
+			return r.content, r.gateway, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", r.gateway, r.err))
+	}
+	return nil, "", f.fetchError(errs)
+}
+
+// fetchError reports ErrNotFound only when every gateway said so
+func (f *GatewayFetcher) fetchError(errs []error) error {
+	for _, err := range errs {
+		if !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("all gateways failed: %w", errors.Join(errs...))
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrNotFound, errors.Join(errs...))
+}
+
+func (f *GatewayFetcher) fetchOne(ctx context.Context, gateway, cid, name string) ([]byte, error) {
+	reqCtx := ctx
+	if f.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, f.cfg.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	content, err := f.get(reqCtx, gateway, cid, name)
+	latency := time.Since(start)
+
+	// A request cancelled by the caller says nothing about the gateway
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	f.record(gateway, cid, latency, err)
+	return content, err
+}
+
+func (f *GatewayFetcher) get(ctx context.Context, gateway, cid, name string) ([]byte, error) {
+	// Content that fails verification counts against the gateway that served it
+	if f.verify {
+		if name != "" {
+			return ReadFileVerified(ctx, cid, name, gatewayBlockGetter(f.httpClient, gateway))
+		}
+		return ReadVerified(ctx, cid, gatewayBlockGetter(f.httpClient, gateway))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", gateway+ipfsPath(cid, name), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return content, nil
+}
+
+func (f *GatewayFetcher) record(gateway, cid string, latency time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, h := range f.gateways {
+		if h.url != gateway {
+			continue
+		}
+		if h.avgLatency == 0 {
+			h.avgLatency = latency
+		} else {
+			h.avgLatency = time.Duration(gatewayLatencyWeight*float64(latency) + (1-gatewayLatencyWeight)*float64(h.avgLatency))
+		}
+		if err != nil {
+			h.failures++
+			h.consecutiveFailures++
+			return
+		}
+		h.served++
+		h.consecutiveFailures = 0
+	}
+
+	f.recent = append(f.recent, GatewayFetch{CID: cid, Gateway: gateway, Latency: latency, At: time.Now()})
+	if len(f.recent) > gatewayRecentFetches {
+		f.recent = f.recent[len(f.recent)-gatewayRecentFetches:]
+	}
+}
+
+// ranked returns gateway URLs with healthy gateways first. Within each group gateways that were tried
+// come fastest first, and untried ones follow in configured order.
+func (f *GatewayFetcher) ranked() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	gateways := make([]*gatewayHealth, len(f.gateways))
+	copy(gateways, f.gateways)
+	// Compared as the tuple (healthy, untried, latency, order), so the order is consistent
+	sort.Slice(gateways, func(i, j int) bool {
+		a, b := gateways[i], gateways[j]
+		if a.healthy() != b.healthy() {
+			return a.healthy()
+		}
+		if untriedA, untriedB := a.avgLatency == 0, b.avgLatency == 0; untriedA != untriedB {
+			return untriedB
+		}
+		if a.avgLatency != b.avgLatency {
+			return a.avgLatency < b.avgLatency
+		}
+		return a.order < b.order
+	})
+
+	urls := make([]string, len(gateways))
+	for i, h := range gateways {
+		urls[i] = h.url
+	}
+	return urls
+}
+
+// Stats returns the health of every gateway in configured order
+func (f *GatewayFetcher) Stats() []GatewayStat {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := make([]GatewayStat, len(f.gateways))
+	for i, h := range f.gateways {
+		stats[i] = GatewayStat{
+			URL:                 h.url,
+			Served:              h.served,
+			Failures:            h.failures,
+			ConsecutiveFailures: h.consecutiveFailures,
+			AvgLatency:          h.avgLatency,
+			Healthy:             h.healthy(),
+		}
+	}
+	return stats
+}
+
+// RecentFetches returns the most recent successful fetches, oldest first
+func (f *GatewayFetcher) RecentFetches() []GatewayFetch {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	recent := make([]GatewayFetch, len(f.recent))
+	copy(recent, f.recent)
+	return recent
+}
+
+// GatewayBackend reads content through a GatewayFetcher and delegates everything else
+type GatewayBackend struct {
+	Backend
+	fetcher *GatewayFetcher
+}
+
+func NewGatewayBackend(backend Backend, fetcher *GatewayFetcher) *GatewayBackend {
+	return &GatewayBackend{Backend: backend, fetcher: fetcher}
+}
+
+func (b *GatewayBackend) Get(ctx context.Context, cid string) ([]byte, error) {
+	content, _, err := b.fetcher.Fetch(ctx, cid)
+	return content, err
+}
+
+func (b *GatewayBackend) GetFile(ctx context.Context, root, name string) ([]byte, error) {
+	content, _, err := b.fetcher.FetchFile(ctx, root, name)
+	return content, err
+}
+
+func (b *GatewayBackend) Fetcher() *GatewayFetcher {
+	return b.fetcher
+}
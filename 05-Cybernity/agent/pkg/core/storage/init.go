@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"cybernity/pkg/core/pinata"
+	"net/http"
+	"sync"
+)
+
+var (
+	defaultBackend Backend
+	mu             sync.RWMutex
+)
+
+// New creates the backend selected by the configuration
+func New(cfg *Config, pinataCfg *pinata.Config) (Backend, error) {
+	cfg.MergeDefault()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Timeout: cfg.Timeout}
+	verify := !cfg.SkipVerify
+	var backend Backend
+	switch cfg.Backend {
+	case BackendKubo:
+		backend = NewKuboStorage(&cfg.Kubo, httpClient, verify)
+	case BackendLocal:
+		// Local content is not on IPFS, so gateways don't apply
+		return NewFileStorage(cfg.Local.Path)
+	default:
+		backend = NewPinataStorage(pinataCfg, httpClient, verify)
+	}
+
+	if len(cfg.Gateways.URLs) > 0 {
+		backend = NewGatewayBackend(backend, NewGatewayFetcher(&cfg.Gateways, httpClient, verify))
+	}
+	return backend, nil
+}
+
+// InitWithConfig initializes the default backend with the provided configuration
+func InitWithConfig(cfg *Config, pinataCfg *pinata.Config) error {
+	backend, err := New(cfg, pinataCfg)
+	if err != nil {
+		return err
+	}
+	SetBackend(backend)
+	return nil
+}
+
+// SetBackend replaces the default backend
+func SetBackend(backend Backend) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultBackend = backend
+}
+
+// GetBackend returns the default backend
+func GetBackend() (Backend, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if defaultBackend == nil {
+		return nil, ErrNotInitialized
+	}
+	return defaultBackend, nil
+}
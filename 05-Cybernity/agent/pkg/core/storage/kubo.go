@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// KuboStorage stores content on a Kubo (go-ipfs) node through its RPC API
+type KuboStorage struct {
+	cfg        *KuboConfig
+	httpClient *http.Client
+	verify     bool
+}
+
+func NewKuboStorage(cfg *KuboConfig, httpClient *http.Client, verify bool) *KuboStorage {
+	return &KuboStorage{cfg: cfg, httpClient: httpClient, verify: verify}
+}
+
+func (s *KuboStorage) Put(ctx context.Context, name string, content []byte) (string, error) {
+	return s.add(ctx, []File{{Name: name, Content: content}}, false)
+}
+
+// PutDirectory adds the files wrapped in a directory
+func (s *KuboStorage) PutDirectory(ctx context.Context, files []File) (string, error) {
+	if err := checkFiles(files); err != nil {
+		return "", err
+	}
+	return s.add(ctx, files, true)
+}
+
+func (s *KuboStorage) add(ctx context.Context, files []File, wrap bool) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for _, f := range files {
+		part, err := writer.CreateFormFile("file", f.Name)
+		if err != nil {
+			return "", fmt.Errorf("failed to create form file: %w", err)
+		}
+		if _, err := io.Copy(part, bytes.NewReader(f.Content)); err != nil {
+			return "", fmt.Errorf("failed to write file content to form: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("pin", "true")
+	if wrap {
+		query.Set("wrap-with-directory", "true")
+	}
+	resp, err := s.call(ctx, "add", query, body, writer.FormDataContentType())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// One JSON object is streamed per added file, the wrapping directory comes last with an empty name
+	var hash string
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var result struct {
+			Name string `json:"Name"`
+			Hash string `json:"Hash"`
+		}
+		if err := decoder.Decode(&result); err != nil {
+			return "", fmt.Errorf("failed to decode response: %w", err)
+		}
+		if !wrap || result.Name == "" {
+			hash = result.Hash
+		}
+	}
+	if hash == "" {
+		return "", fmt.Errorf("Hash not found in response")
+	}
+	return hash, nil
+}
+
+func (s *KuboStorage) Get(ctx context.Context, cid string) ([]byte, error) {
+	return s.get(ctx, cid, "")
+}
+
+func (s *KuboStorage) GetFile(ctx context.Context, root, name string) ([]byte, error) {
+	return s.get(ctx, root, name)
+}
+
+// get reads a CID, or the named file of the directory at the CID when name is set
+func (s *KuboStorage) get(ctx context.Context, cid, name string) ([]byte, error) {
+	if s.verify {
+		getBlock := s.getBlock
+		if s.cfg.GatewayURL != "" {
+			getBlock = gatewayBlockGetter(s.httpClient, strings.TrimSuffix(s.cfg.GatewayURL, "/"))
+		}
+		if name != "" {
+			return ReadFileVerified(ctx, cid, name, getBlock)
+		}
+		return ReadVerified(ctx, cid, getBlock)
+	}
+
+	var resp *http.Response
+	if s.cfg.GatewayURL != "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(s.cfg.GatewayURL, "/")+ipfsPath(cid, name), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		resp, err = s.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, ErrNotFound
+			}
+			return nil, fmt.Errorf("bad status: %s", resp.Status)
+		}
+	} else {
+		arg := cid
+		if name != "" {
+			arg = "/ipfs/" + cid + "/" + name
+		}
+		query := url.Values{}
+		query.Set("arg", arg)
+		var err error
+		resp, err = s.call(ctx, "cat", query, nil, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return content, nil
+}
+
+func (s *KuboStorage) Stat(ctx context.Context, cid string) (*Object, error) {
+	query := url.Values{}
+	query.Set("arg", "/ipfs/"+cid)
+	resp, err := s.call(ctx, "files/stat", query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Size int64 `json:"Size"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &Object{CID: cid, Size: result.Size}, nil
+}
+
+func (s *KuboStorage) Delete(ctx context.Context, cid string) error {
+	query := url.Values{}
+	query.Set("arg", cid)
+	resp, err := s.call(ctx, "pin/rm", query, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *KuboStorage) getBlock(ctx context.Context, c *CID) ([]byte, error) {
+	query := url.Values{}
+	query.Set("arg", c.String())
+	resp, err := s.call(ctx, "block/get", query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	block, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return block, nil
+}
+
+// call invokes an RPC API command, all of which are POST requests
+func (s *KuboStorage) call(ctx context.Context, command string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	endpoint := strings.TrimSuffix(s.cfg.APIURL, "/") + "/api/v0/" + command + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var kuboErr struct {
+			Message string `json:"Message"`
+		}
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(bodyBytes, &kuboErr) == nil && strings.Contains(kuboErr.Message, "not pinned") {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("bad status: %s, body: %s", resp.Status, string(bodyBytes))
+	}
+	return resp, nil
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"cybernity/pkg/core/pinata"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	pinataAPIURL         = "https://api.pinata.cloud"
+	pinataDefaultGateway = "https://gateway.pinata.cloud"
+)
+
+// PinataStorage pins content with Pinata and reads it back through a gateway
+type PinataStorage struct {
+	cfg        *pinata.Config
+	httpClient *http.Client
+	verify     bool
+}
+
+func NewPinataStorage(cfg *pinata.Config, httpClient *http.Client, verify bool) *PinataStorage {
+	return &PinataStorage{cfg: cfg, httpClient: httpClient, verify: verify}
+}
+
+func (s *PinataStorage) Put(ctx context.Context, name string, content []byte) (string, error) {
+	return s.pin(ctx, []File{{Name: name, Content: content}})
+}
+
+// PutDirectory pins the files under a common folder, which Pinata wraps in a directory
+func (s *PinataStorage) PutDirectory(ctx context.Context, files []File) (string, error) {
+	if err := checkFiles(files); err != nil {
+		return "", err
+	}
+	dir := make([]File, len(files))
+	for i, f := range files {
+		dir[i] = File{Name: "knowledge/" + f.Name, Content: f.Content}
+	}
+	return s.pin(ctx, dir)
+}
+
+func (s *PinataStorage) pin(ctx context.Context, files []File) (string, error) {
+	// Create a buffer to store our request body
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for _, f := range files {
+		// Create a new form-data header with the provided file name
+		part, err := writer.CreateFormFile("file", f.Name)
+		if err != nil {
+			return "", fmt.Errorf("failed to create form file: %w", err)
+		}
+
+		// Copy the file content to the form-data part
+		_, err = io.Copy(part, bytes.NewReader(f.Content))
+		if err != nil {
+			return "", fmt.Errorf("failed to write file content to form: %w", err)
+		}
+	}
+
+	// It's important to close the multipart writer.
+	// This writes the trailing boundary marker.
+	err := writer.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", pinataAPIURL+"/pinning/pinFileToIPFS", body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var result struct {
+		IpfsHash string `json:"IpfsHash"`
+	}
+	if err := s.doJSON(req, &result); err != nil {
+		return "", err
+	}
+
+	if result.IpfsHash == "" {
+		return "", fmt.Errorf("IpfsHash not found in response")
+	}
+
+	return result.IpfsHash, nil
+}
+
+func (s *PinataStorage) Get(ctx context.Context, cid string) ([]byte, error) {
+	if s.verify {
+		return ReadVerified(ctx, cid, gatewayBlockGetter(s.httpClient, s.GatewayURL()))
+	}
+	return s.fetch(ctx, ipfsPath(cid, ""))
+}
+
+func (s *PinataStorage) GetFile(ctx context.Context, root, name string) ([]byte, error) {
+	if s.verify {
+		return ReadFileVerified(ctx, root, name, gatewayBlockGetter(s.httpClient, s.GatewayURL()))
+	}
+	return s.fetch(ctx, ipfsPath(root, name))
+}
+
+// fetch reads a path from the gateway without verification
+func (s *PinataStorage) fetch(ctx context.Context, p string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.GatewayURL()+p, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return respBody, nil
+}
+
+func (s *PinataStorage) Stat(ctx context.Context, cid string) (*Object, error) {
+	query := url.Values{}
+	query.Set("hashContains", cid)
+	query.Set("status", "pinned")
+	req, err := http.NewRequestWithContext(ctx, "GET", pinataAPIURL+"/data/pinList?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var result struct {
+		Rows []struct {
+			IpfsPinHash string `json:"ipfs_pin_hash"`
+			Size        int64  `json:"size"`
+			Metadata    struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		} `json:"rows"`
+	}
+	if err := s.doJSON(req, &result); err != nil {
+		return nil, err
+	}
+
+	for _, row := range result.Rows {
+		if row.IpfsPinHash == cid {
+			return &Object{CID: cid, Name: row.Metadata.Name, Size: row.Size}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *PinataStorage) Delete(ctx context.Context, cid string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", pinataAPIURL+"/pinning/unpin/"+url.PathEscape(cid), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	return s.doJSON(req, nil)
+}
+
+// GatewayURL returns the configured gateway without a trailing slash or /ipfs suffix
+func (s *PinataStorage) GatewayURL() string {
+	gateway := strings.TrimSuffix(strings.TrimSuffix(s.cfg.GatewayURL, "/"), "/ipfs")
+	if gateway == "" {
+		return pinataDefaultGateway
+	}
+	return gateway
+}
+
+// doJSON sends an authorized request to the Pinata API and decodes the JSON response into v when not nil
+func (s *PinataStorage) doJSON(req *http.Request, v interface{}) error {
+	if s.cfg.JWT == "" {
+		return fmt.Errorf("PINATA_JWT is not set in config")
+	}
+	req.Header.Set("Authorization", "Bearer "+s.cfg.JWT)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("bad status: %s, body: %s", resp.Status, string(bodyBytes))
+	}
+
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
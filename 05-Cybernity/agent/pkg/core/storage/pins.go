@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// PinPolicyConfig controls when pinned content we no longer need is unpinned.
+// A zero TTL disables the corresponding policy.
+type PinPolicyConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Interval         time.Duration `yaml:"interval"`            // how often policies are applied
+	OffChainAgentTTL time.Duration `yaml:"off_chain_agent_ttl"` // unpin agents never put on-chain after this long
+	SupersededTTL    time.Duration `yaml:"superseded_ttl"`      // unpin superseded content after this long
+}
+
+func (c *PinPolicyConfig) mergeDefault() {
+	if c.Interval == 0 {
+		c.Interval = time.Hour
+	}
+}
+
+func (c *PinPolicyConfig) validate() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("pin policy interval must be greater than 0")
+	}
+	if c.OffChainAgentTTL < 0 || c.SupersededTTL < 0 {
+		return fmt.Errorf("pin policy ttl must not be negative")
+	}
+	return nil
+}
@@ -1,4 +1,40 @@
 package storage
 
-type FileStorage struct {
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNotFound       = errors.New("content not found")
+	ErrNotInitialized = errors.New("storage backend not initialized")
+)
+
+// Object describes content stored in a backend
+type Object struct {
+	CID  string `json:"cid"`
+	Name string `json:"name,omitempty"`
+	Size int64  `json:"size"`
+}
+
+// File is one entry of a directory
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// Backend stores content addressed by CID
+type Backend interface {
+	// Put stores content under the given file name and returns its CID
+	Put(ctx context.Context, name string, content []byte) (string, error)
+	// Get returns the content for a CID
+	Get(ctx context.Context, cid string) ([]byte, error)
+	// Stat returns metadata for a CID, ErrNotFound if the backend doesn't hold it
+	Stat(ctx context.Context, cid string) (*Object, error)
+	// Delete unpins or removes the content for a CID
+	Delete(ctx context.Context, cid string) error
+	// PutDirectory stores the files as one UnixFS directory and returns the directory CID
+	PutDirectory(ctx context.Context, files []File) (string, error)
+	// GetFile returns the content of the named file in the directory at root
+	GetFile(ctx context.Context, root, name string) ([]byte, error)
 }
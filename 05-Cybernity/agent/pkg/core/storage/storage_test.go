@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStorage(t *testing.T) {
+	ctx := context.Background()
+	backend, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage() error = %v", err)
+	}
+
+	content := []byte("encrypted knowledge")
+	cid, err := backend.Put(ctx, "knowledge.txt", content)
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if cid != RawCID(content) {
+		t.Errorf("Put() cid = %s, want %s", cid, RawCID(content))
+	}
+
+	got, err := backend.Get(ctx, cid)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("Get() = %q, want %q", got, content)
+	}
+
+	obj, err := backend.Stat(ctx, cid)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if obj.Size != int64(len(content)) {
+		t.Errorf("Stat() size = %d, want %d", obj.Size, len(content))
+	}
+
+	if err := backend.Delete(ctx, cid); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := backend.Get(ctx, cid); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := backend.Get(ctx, "../secret"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() with path traversal error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestGatewayFetcherFailover(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content of " + strings.TrimPrefix(r.URL.Path, "/ipfs/")))
+	}))
+	defer working.Close()
+
+	for _, strategy := range []string{GatewayStrategySequential, GatewayStrategyParallel} {
+		t.Run(strategy, func(t *testing.T) {
+			fetcher := NewGatewayFetcher(&GatewayConfig{
+				URLs:     []string{broken.URL, working.URL + "/ipfs/"},
+				Strategy: strategy,
+				Timeout:  5 * time.Second,
+			}, http.DefaultClient, false)
+
+			for i := 0; i < gatewayFailureThreshold; i++ {
+				content, gateway, err := fetcher.Fetch(context.Background(), "bafy")
+				if err != nil {
+					t.Fatalf("Fetch() error = %v", err)
+				}
+				if string(content) != "content of bafy" || gateway != working.URL {
+					t.Errorf("Fetch() = %q from %s, want content from %s", content, gateway, working.URL)
+				}
+			}
+
+			if strategy == GatewayStrategySequential {
+				if ranked := fetcher.ranked(); ranked[0] != working.URL {
+					t.Errorf("ranked() = %v, want failing gateway demoted", ranked)
+				}
+			}
+			if recent := fetcher.RecentFetches(); len(recent) != gatewayFailureThreshold || recent[0].Gateway != working.URL {
+				t.Errorf("RecentFetches() = %v", recent)
+			}
+		})
+	}
+}
+
+func TestGatewayRanking(t *testing.T) {
+	urls := []string{"https://a", "https://b", "https://c", "https://d", "https://e", "https://f"}
+	fetcher := NewGatewayFetcher(&GatewayConfig{URLs: urls, Timeout: time.Second}, http.DefaultClient, false)
+	latencies := []time.Duration{0, 300 * time.Millisecond, 0, 100 * time.Millisecond, 100 * time.Millisecond, 50 * time.Millisecond}
+	for i, h := range fetcher.gateways {
+		h.avgLatency = latencies[i]
+	}
+	fetcher.gateways[5].consecutiveFailures = gatewayFailureThreshold
+
+	// Tried gateways fastest first, ties in configured order, then untried ones, then unhealthy ones
+	want := []string{"https://d", "https://e", "https://b", "https://a", "https://c", "https://f"}
+	for i := 0; i < 10; i++ {
+		if got := fetcher.ranked(); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Fatalf("ranked() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPinPolicyConfig(t *testing.T) {
+	cfg := (&Config{}).MergeDefault()
+	if cfg.Pins.Interval != time.Hour {
+		t.Errorf("default pin interval = %v, want 1h", cfg.Pins.Interval)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	cfg.Pins.OffChainAgentTTL = -time.Hour
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() accepted a negative pin ttl")
+	}
+}
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// maxVerifiedDepth bounds the dag-pb tree depth so a malicious DAG can't recurse forever
+	maxVerifiedDepth = 32
+	// maxVerifiedBlocks bounds the blocks fetched for one file, so a DAG linking the same blocks over and over
+	// can't keep the reader fetching forever. It allows MaxVerifiedSize in 4 KiB chunks.
+	maxVerifiedBlocks = MaxVerifiedSize / 4096
+	// MaxVerifiedSize bounds the reassembled file size
+	MaxVerifiedSize = 256 * 1024 * 1024
+)
+
+// UnixFS data types
+const (
+	unixfsRaw       = 0
+	unixfsDirectory = 1
+	unixfsFile      = 2
+)
+
+// BlockGetter fetches the raw bytes of a single block
+type BlockGetter func(ctx context.Context, c *CID) ([]byte, error)
+
+// ReadVerified reassembles the file at cid block by block, checking every block against its CID.
+// Raw leaves and dag-pb/UnixFS file trees are supported, as produced by ipfs add and Pinata.
+func ReadVerified(ctx context.Context, cid string, getBlock BlockGetter) ([]byte, error) {
+	root, err := ParseCID(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &verifiedReader{getBlock: getBlock, blocks: maxVerifiedBlocks}
+	if err := r.read(ctx, root, 0); err != nil {
+		return nil, err
+	}
+	return r.out.Bytes(), nil
+}
+
+// verifiedReader holds what one ReadVerified call has read so far
+type verifiedReader struct {
+	getBlock BlockGetter
+	out      bytes.Buffer
+	blocks   int // left to fetch
+}
+
+func (r *verifiedReader) read(ctx context.Context, c *CID, depth int) error {
+	if depth > maxVerifiedDepth {
+		return fmt.Errorf("dag deeper than %d levels", maxVerifiedDepth)
+	}
+	if r.blocks == 0 {
+		return fmt.Errorf("dag has more than %d blocks", maxVerifiedBlocks)
+	}
+	r.blocks--
+
+	block, err := r.getBlock(ctx, c)
+	if err != nil {
+		return err
+	}
+	if err := c.Verify(block); err != nil {
+		return err
+	}
+
+	switch c.Codec {
+	case CodecRaw:
+		r.out.Write(block)
+	case CodecDagPB:
+		node, err := decodePBNode(block)
+		if err != nil {
+			return fmt.Errorf("invalid dag-pb block %s: %w", c, err)
+		}
+		fsType, data, err := decodeUnixFSData(node.data)
+		if err != nil {
+			return fmt.Errorf("invalid unixfs data in %s: %w", c, err)
+		}
+		if fsType != unixfsFile && fsType != unixfsRaw {
+			return fmt.Errorf("%s is not a file (unixfs type %d)", c, fsType)
+		}
+
+		r.out.Write(data)
+		for _, link := range node.links {
+			child, err := DecodeCID(link.hash)
+			if err != nil {
+				return fmt.Errorf("invalid link in %s: %w", c, err)
+			}
+			if err := r.read(ctx, child, depth+1); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported codec 0x%x in %s", c.Codec, c)
+	}
+
+	if r.out.Len() > MaxVerifiedSize {
+		return fmt.Errorf("content exceeds %d bytes", MaxVerifiedSize)
+	}
+	return nil
+}
+
+type pbNode struct {
+	data  []byte
+	links []pbLink
+}
+
+type pbLink struct {
+	hash []byte // binary CID
+	name string
+	size uint64 // cumulative size of the target
+}
+
+// decodePBNode decodes a dag-pb PBNode { 2: repeated PBLink Links; 1: bytes Data }
+func decodePBNode(b []byte) (*pbNode, error) {
+	node := &pbNode{}
+	err := walkProto(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			node.data = v
+		case num == 2 && typ == protowire.BytesType:
+			// PBLink { 1: bytes Hash; 2: string Name; 3: uint64 Tsize }
+			var link pbLink
+			if err := walkProto(v, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					link.hash = v
+				case num == 2 && typ == protowire.BytesType:
+					link.name = string(v)
+				case num == 3 && typ == protowire.VarintType:
+					link.size = n
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if link.hash == nil {
+				return errors.New("link without hash")
+			}
+			node.links = append(node.links, link)
+		}
+		return nil
+	})
+	return node, err
+}
+
+// decodeUnixFSData decodes UnixFS Data { 1: Type; 2: bytes Data; ... } returning the type and inline data
+func decodeUnixFSData(b []byte) (uint64, []byte, error) {
+	if b == nil {
+		return 0, nil, errors.New("missing unixfs data")
+	}
+	var fsType uint64
+	var hasType bool
+	var data []byte
+	err := walkProto(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			fsType, hasType = n, true
+		case num == 2 && typ == protowire.BytesType:
+			data = v
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if !hasType {
+		return 0, nil, errors.New("missing unixfs type")
+	}
+	return fsType, data, nil
+}
+
+// walkProto calls fn for every field, with bytes fields in v and varint fields in n
+func walkProto(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var v []byte
+		var x uint64
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gatewayBlockGetter fetches raw blocks from a trustless gateway
+func gatewayBlockGetter(httpClient *http.Client, gateway string) BlockGetter {
+	return func(ctx context.Context, c *CID) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", gateway+"/ipfs/"+url.PathEscape(c.String())+"?format=raw", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Accept", "application/vnd.ipld.raw")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("bad status: %s", resp.Status)
+		}
+
+		// A single block is at most a few MiB, anything bigger is not a block
+		block, err := io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		return block, nil
+	}
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseCID(t *testing.T) {
+	// The empty UnixFS directory, a well known CIDv0
+	emptyDir := []byte{0x0a, 0x02, 0x08, 0x01}
+	c, err := ParseCID("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	if err != nil {
+		t.Fatalf("ParseCID() error = %v", err)
+	}
+	if c.Version != 0 || c.Codec != CodecDagPB {
+		t.Errorf("ParseCID() = v%d codec 0x%x, want v0 dag-pb", c.Version, c.Codec)
+	}
+	if err := c.Verify(emptyDir); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if c.String() != "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn" {
+		t.Errorf("String() = %s", c.String())
+	}
+
+	raw := RawCID([]byte("hello"))
+	c, err = ParseCID(raw)
+	if err != nil {
+		t.Fatalf("ParseCID(%s) error = %v", raw, err)
+	}
+	if c.Version != 1 || c.Codec != CodecRaw || c.String() != raw {
+		t.Errorf("ParseCID(%s) = %+v", raw, c)
+	}
+	if err := c.Verify([]byte("hellO")); !errors.Is(err, ErrContentMismatch) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrContentMismatch)
+	}
+
+	if _, err := ParseCID("not-a-cid"); err == nil {
+		t.Error("ParseCID() accepted an invalid cid")
+	}
+}
+
+// unixfsFileNode builds a dag-pb UnixFS file node linking to the given children
+func unixfsFileNode(data []byte, children ...*CID) []byte {
+	fsData := protowire.AppendTag(nil, 1, protowire.VarintType)
+	fsData = protowire.AppendVarint(fsData, unixfsFile)
+	if data != nil {
+		fsData = protowire.AppendTag(fsData, 2, protowire.BytesType)
+		fsData = protowire.AppendBytes(fsData, data)
+	}
+
+	var node []byte
+	for _, child := range children {
+		link := protowire.AppendTag(nil, 1, protowire.BytesType)
+		link = protowire.AppendBytes(link, child.Bytes())
+		node = protowire.AppendTag(node, 2, protowire.BytesType)
+		node = protowire.AppendBytes(node, link)
+	}
+	node = protowire.AppendTag(node, 1, protowire.BytesType)
+	return protowire.AppendBytes(node, fsData)
+}
+
+func TestReadVerified(t *testing.T) {
+	blocks := map[string][]byte{}
+	put := func(codec uint64, version int, block []byte) *CID {
+		digest := sha256.Sum256(block)
+		c := &CID{Version: version, Codec: codec, HashCode: HashSHA2256, Digest: digest[:]}
+		blocks[c.String()] = block
+		return c
+	}
+	getBlock := func(ctx context.Context, c *CID) ([]byte, error) {
+		block, ok := blocks[c.String()]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return block, nil
+	}
+
+	// A chunked file: a dag-pb leaf, a raw leaf and a nested dag-pb node
+	leaf1 := put(CodecDagPB, 0, unixfsFileNode([]byte("chunk one, ")))
+	leaf2 := put(CodecRaw, 1, []byte("chunk two, "))
+	nested := put(CodecDagPB, 1, unixfsFileNode(nil, put(CodecRaw, 1, []byte("chunk three"))))
+	root := put(CodecDagPB, 0, unixfsFileNode(nil, leaf1, leaf2, nested))
+
+	content, err := ReadVerified(context.Background(), root.String(), getBlock)
+	if err != nil {
+		t.Fatalf("ReadVerified() error = %v", err)
+	}
+	if !bytes.Equal(content, []byte("chunk one, chunk two, chunk three")) {
+		t.Errorf("ReadVerified() = %q", content)
+	}
+
+	// A gateway serving a tampered leaf must be rejected
+	blocks[leaf2.String()] = []byte("chunk 2!, ")
+	if _, err := ReadVerified(context.Background(), root.String(), getBlock); !errors.Is(err, ErrContentMismatch) {
+		t.Errorf("ReadVerified() error = %v, want %v", err, ErrContentMismatch)
+	}
+
+	// A DAG fanning out to the same empty leaf stays small but needs more fetches than allowed
+	empty := put(CodecRaw, 1, nil)
+	fanout := func(child *CID) []*CID {
+		links := make([]*CID, 256)
+		for i := range links {
+			links[i] = child
+		}
+		return links
+	}
+	wide := put(CodecDagPB, 0, unixfsFileNode(nil, fanout(empty)...))
+	root = put(CodecDagPB, 0, unixfsFileNode(nil, fanout(wide)...))
+	if _, err := ReadVerified(context.Background(), root.String(), getBlock); err == nil || !strings.Contains(err.Error(), "blocks") {
+		t.Errorf("ReadVerified() error = %v, want a block budget error", err)
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	// The empty UnixFS directory has a well known encoding
+	if !bytes.Equal(encodeDirectory(nil), []byte{0x0a, 0x02, 0x08, 0x01}) {
+		t.Errorf("encodeDirectory(nil) = %x", encodeDirectory(nil))
+	}
+
+	ctx := context.Background()
+	backend, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage() error = %v", err)
+	}
+	root, err := backend.PutDirectory(ctx, []File{
+		{Name: "b.txt", Content: []byte("second")},
+		{Name: "manifest.json", Content: []byte("{}")},
+		{Name: "a file.txt", Content: []byte("first")},
+	})
+	if err != nil {
+		t.Fatalf("PutDirectory() error = %v", err)
+	}
+
+	got, err := backend.GetFile(ctx, root, "a file.txt")
+	if err != nil {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+	if string(got) != "first" {
+		t.Errorf("GetFile() = %q, want %q", got, "first")
+	}
+	if _, err := backend.GetFile(ctx, root, "missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetFile() error = %v, want %v", err, ErrNotFound)
+	}
+
+	// Links are sorted by name whatever the upload order
+	block, _ := backend.Get(ctx, root)
+	node, err := decodePBNode(block)
+	if err != nil {
+		t.Fatalf("decodePBNode() error = %v", err)
+	}
+	if len(node.links) != 3 || node.links[0].name != "a file.txt" || node.links[2].name != "manifest.json" {
+		t.Errorf("directory links = %+v", node.links)
+	}
+
+	if _, err := backend.PutDirectory(ctx, []File{{Name: "../x", Content: nil}}); err == nil {
+		t.Error("PutDirectory() accepted an invalid file name")
+	}
+	if _, err := backend.PutDirectory(ctx, []File{{Name: "x"}, {Name: "x"}}); err == nil {
+		t.Error("PutDirectory() accepted duplicate file names")
+	}
+}
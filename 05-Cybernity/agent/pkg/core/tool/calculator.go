@@ -0,0 +1,274 @@
+package tool
+
+import (
+	"context"
+	"cybernity/pkg/core/llm"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxExpressionLength caps the expressions the calculator evaluates
+const maxExpressionLength = 256
+
+// NewCalculator returns the calculator tool, which evaluates arithmetic so the model doesn't have to
+func NewCalculator() Tool {
+	return &Func{
+		Tool: llm.NewFunctionTool(Calculator,
+			"Evaluates an arithmetic expression with + - * / % ^, parentheses, the constants pi and e, "+
+				"and the functions sqrt, abs, ln, log10, exp, round, floor and ceil.",
+			map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"expression": map[string]any{"type": "string", "description": "e.g. (1+2.5)*3^2"},
+				},
+				"required": []string{"expression"},
+			}),
+		Fn: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Expression string `json:"expression"`
+			}
+			if err := DecodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			value, err := Evaluate(args.Expression)
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(value, 'g', 15, 64), nil
+		},
+	}
+}
+
+var functions = map[string]func(float64) float64{
+	"sqrt":  math.Sqrt,
+	"abs":   math.Abs,
+	"ln":    math.Log,
+	"log10": math.Log10,
+	"exp":   math.Exp,
+	"round": math.Round,
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+}
+
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// Evaluate evaluates an arithmetic expression
+func Evaluate(expression string) (float64, error) {
+	if len(expression) > maxExpressionLength {
+		return 0, fmt.Errorf("expression exceeds %d characters", maxExpressionLength)
+	}
+	p := &parser{input: expression}
+	value, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at %d", p.input[p.pos], p.pos)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("result is not a finite number")
+	}
+	return value, nil
+}
+
+// parser is a recursive descent parser of
+//
+//	expression = term {("+" | "-") term}
+//	term       = unary {("*" | "/" | "%") unary}
+//	unary      = ("+" | "-") unary | power
+//	power      = primary ["^" unary]
+//	primary    = number | constant | function "(" expression ")" | "(" expression ")"
+type parser struct {
+	input string
+	pos   int
+	depth int
+}
+
+// maxDepth caps the nesting of expressions
+const maxDepth = 64
+
+func (p *parser) expression() (float64, error) {
+	if p.depth++; p.depth > maxDepth {
+		return 0, errors.New("expression is nested too deeply")
+	}
+	defer func() { p.depth-- }()
+
+	left, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.next() {
+		case '+':
+			p.pos++
+			right, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			left += right
+		case '-':
+			p.pos++
+			right, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			left -= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *parser) term() (float64, error) {
+	left, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.next()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case op == '*':
+			left *= right
+		case right == 0:
+			return 0, errors.New("division by zero")
+		case op == '/':
+			left /= right
+		default:
+			left = math.Mod(left, right)
+		}
+	}
+}
+
+func (p *parser) unary() (float64, error) {
+	switch p.next() {
+	case '+':
+		p.pos++
+		return p.unary()
+	case '-':
+		p.pos++
+		value, err := p.unary()
+		return -value, err
+	}
+	return p.power()
+}
+
+func (p *parser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.next() != '^' {
+		return base, nil
+	}
+	p.pos++
+	// Right associative and binding tighter than a sign: 2^3^2 is 2^9, -2^2 is -4
+	exponent, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+func (p *parser) primary() (float64, error) {
+	c := p.next()
+	switch {
+	case c == '(':
+		p.pos++
+		value, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+		if p.next() != ')' {
+			return 0, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		p.pos++
+		return value, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+	case unicode.IsLetter(rune(c)):
+		return p.name()
+	case c == 0:
+		return 0, errors.New("unexpected end of expression")
+	}
+	return 0, fmt.Errorf("unexpected %q at %d", c, p.pos)
+}
+
+func (p *parser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	// An exponent, as in 1.5e3
+	if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+		end := p.pos + 1
+		if end < len(p.input) && (p.input[end] == '+' || p.input[end] == '-') {
+			end++
+		}
+		if end < len(p.input) && isDigit(p.input[end]) {
+			for p.pos = end; p.pos < len(p.input) && isDigit(p.input[p.pos]); p.pos++ {
+			}
+		}
+	}
+	value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+	}
+	return value, nil
+}
+
+func (p *parser) name() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || isDigit(p.input[p.pos])) {
+		p.pos++
+	}
+	name := strings.ToLower(p.input[start:p.pos])
+	if value, ok := constants[name]; ok {
+		return value, nil
+	}
+	fn, ok := functions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown name %q", name)
+	}
+	if p.next() != '(' {
+		return 0, fmt.Errorf("missing ( after %s", name)
+	}
+	arg, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	return fn(arg), nil
+}
+
+// next returns the next character after spaces, 0 at the end
+func (p *parser) next() byte {
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,86 @@
+package tool
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Enabled are the built-in tools creators may let their agents call
+	Enabled []string `yaml:"enabled"`
+	// MaxIterations caps the rounds of tool calls before the agent has to answer
+	MaxIterations int `yaml:"max_iterations"`
+	// Timeout bounds each tool call
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxResultLength caps the characters of a result sent back to the model
+	MaxResultLength int `yaml:"max_result_length"`
+}
+
+var (
+	config   = DefaultConfig()
+	configMu sync.RWMutex
+)
+
+// DefaultConfig returns a default configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MaxIterations:   4,
+		Timeout:         10 * time.Second,
+		MaxResultLength: 4000,
+	}
+}
+
+// MergeDefault merges the default configuration with the current configuration
+func (c *Config) MergeDefault() *Config {
+	def := DefaultConfig()
+	if c.MaxIterations == 0 {
+		c.MaxIterations = def.MaxIterations
+	}
+	if c.Timeout == 0 {
+		c.Timeout = def.Timeout
+	}
+	if c.MaxResultLength == 0 {
+		c.MaxResultLength = def.MaxResultLength
+	}
+	return c
+}
+
+// Validate validates the configuration
+func (c *Config) Validate() error {
+	for _, name := range c.Enabled {
+		if !slices.Contains(Builtins, name) {
+			return fmt.Errorf("unknown tool %s", name)
+		}
+	}
+	if c.MaxIterations <= 0 || c.Timeout <= 0 || c.MaxResultLength <= 0 {
+		return fmt.Errorf("max iterations, timeout and max result length must be greater than 0")
+	}
+	return nil
+}
+
+// IsEnabled reports whether creators may let their agents call the tool
+func (c *Config) IsEnabled(name string) bool {
+	return slices.Contains(c.Enabled, name)
+}
+
+// InitWithConfig sets which tools agents may call and how
+func InitWithConfig(cfg *Config) error {
+	cfg.MergeDefault()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = cfg
+	return nil
+}
+
+// GetConfig returns which tools agents may call and how
+func GetConfig() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
@@ -0,0 +1,73 @@
+package tool
+
+import (
+	"context"
+	"cybernity/pkg/core/llm"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Names of the built-in tools
+const (
+	Calculator      = "calculator"
+	KnowledgeSearch = "knowledge_search"
+	AgentLookup     = "agent_lookup"
+)
+
+// Builtins are the tools agents can be allowed to call
+var Builtins = []string{Calculator, KnowledgeSearch, AgentLookup}
+
+// Tool is a tool an agent can call
+type Tool interface {
+	Definition() llm.Tool
+	// Call runs the tool with the JSON arguments the model gave, its result goes back to the model
+	Call(ctx context.Context, arguments string) (string, error)
+}
+
+// Func is a tool running a function
+type Func struct {
+	Tool llm.Tool
+	Fn   func(ctx context.Context, arguments string) (string, error)
+}
+
+func (f *Func) Definition() llm.Tool {
+	return f.Tool
+}
+
+func (f *Func) Call(ctx context.Context, arguments string) (string, error) {
+	return f.Fn(ctx, arguments)
+}
+
+// Set is a set of tools by name
+type Set map[string]Tool
+
+// NewSet returns the set of the tools
+func NewSet(tools ...Tool) Set {
+	set := make(Set, len(tools))
+	for _, t := range tools {
+		set[t.Definition().Function.Name] = t
+	}
+	return set
+}
+
+// Definitions returns the definitions of the tools to send the model, by name
+func (s Set) Definitions() []llm.Tool {
+	definitions := make([]llm.Tool, 0, len(s))
+	for _, name := range slices.Sorted(maps.Keys(s)) {
+		definitions = append(definitions, s[name].Definition())
+	}
+	return definitions
+}
+
+// DecodeArguments decodes the JSON arguments of a call
+func DecodeArguments(arguments string, v any) error {
+	if arguments == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
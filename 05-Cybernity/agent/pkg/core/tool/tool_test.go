@@ -0,0 +1,81 @@
+package tool
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 / 4", 2.5},
+		{"10 % 4", 2},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"2 ^ -1", 0.5},
+		{"--3", 3},
+		{"1.5e3 + .5", 1500.5},
+		{"sqrt(16) + abs(-2)", 6},
+		{"round(pi * 100) / 100", 3.14},
+		{"ln(e)", 1},
+		{"floor(2.7) + ceil(2.1)", 5},
+	}
+	for _, tt := range tests {
+		got, err := Evaluate(tt.expression)
+		if err != nil || got != tt.want {
+			t.Errorf("Evaluate(%q) = %v, %v, want %v", tt.expression, got, err, tt.want)
+		}
+	}
+
+	for expression, want := range map[string]string{
+		"1 / 0":                   "division by zero",
+		"1 % 0":                   "division by zero",
+		"(1 + 2":                  "missing )",
+		"1 +":                     "unexpected end",
+		"2 3":                     "unexpected",
+		"foo(1)":                  "unknown name",
+		"sqrt 4":                  "missing (",
+		"sqrt(-1)":                "not a finite number",
+		"10 ^ 400":                "not a finite number",
+		strings.Repeat("(", 100):  "nested too deeply",
+		strings.Repeat("1+", 200): "exceeds",
+	} {
+		if _, err := Evaluate(expression); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Evaluate(%.20q) error = %v, want %q", expression, err, want)
+		}
+	}
+}
+
+func TestCalculator(t *testing.T) {
+	calculator := NewCalculator()
+	if name := calculator.Definition().Function.Name; name != Calculator {
+		t.Errorf("Definition() name = %s", name)
+	}
+	got, err := calculator.Call(context.Background(), `{"expression":"0.1 + 0.2"}`)
+	if err != nil || got != "0.3" {
+		t.Errorf("Call() = %q, %v, want 0.3", got, err)
+	}
+	if _, err := calculator.Call(context.Background(), `{"expression":`); err == nil {
+		t.Error("Call() accepted invalid arguments")
+	}
+}
+
+func TestConfig(t *testing.T) {
+	cfg := (&Config{Enabled: []string{Calculator}}).MergeDefault()
+	if err := cfg.Validate(); err != nil || !cfg.IsEnabled(Calculator) || cfg.IsEnabled(AgentLookup) {
+		t.Errorf("Validate() = %v, enabled = %v", err, cfg.Enabled)
+	}
+	if err := (&Config{Enabled: []string{"shell"}}).MergeDefault().Validate(); err == nil {
+		t.Error("Validate() accepted an unknown tool")
+	}
+
+	set := NewSet(NewCalculator(), &Func{Tool: NewCalculator().Definition()})
+	if len(set) != 1 || len(set.Definitions()) != 1 {
+		t.Errorf("NewSet() = %v, want tools by name", set)
+	}
+}
@@ -8,11 +8,16 @@ import (
 )
 
 type Agents struct {
-	Name           string `json:"name"`
-	Description    string `json:"description"`
-	CID            string `json:"cid" gorm:"column:cid"`
-	CreatorAddress string `json:"creator_address"`
-	AgentAddress   string `json:"agent_address"`
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	CID              string `json:"cid" gorm:"column:cid"`
+	CreatorAddress   string `json:"creator_address"`
+	AgentAddress     string `json:"agent_address"`
+	EncryptionMode   string `json:"encryption_mode"`
+	WrappedKey       string `json:"wrapped_key" gorm:"type:text"` // base64 content key wrapped to the agent's public key
+	KnowledgeFormat  string `json:"knowledge_format"`
+	KnowledgeCID     string `json:"knowledge_cid" gorm:"column:knowledge_cid"` // latest knowledge, empty until the first update
+	KnowledgeVersion int    `json:"knowledge_version"`
 	gorm.Model
 }
 
@@ -25,19 +30,57 @@ const (
 	OffChain = 0
 )
 
-func (a *Agents) Create(ctx context.Context) (err error) {
+// Who encrypted the knowledge stored at the agent's CID
+const (
+	EncryptionModeServer     = ""            // server encrypted the uploaded plaintext
+	EncryptionModeClient     = "client"      // creator encrypted to the agent's public key
+	EncryptionModeWrappedKey = "wrapped_key" // creator encrypted with their own content key, wrapped to the agent's public key
+)
+
+// How the knowledge is laid out at the agent's CID
+const (
+	KnowledgeFormatFile      = ""          // a single encrypted file
+	KnowledgeFormatDirectory = "directory" // a directory of encrypted files listed by a manifest
+)
+
+// Create creates the agent along with its first knowledge version, so neither exists without the other
+func (a *Agents) Create(ctx context.Context, version *KnowledgeVersions) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		return a.create(tx, version)
+	})
+}
+
+// CreateWithWallet creates the agent with the wallet its creator reserved, binding the wallet to the agent CID.
+// It fails with ErrWalletUsed when the wallet is bound already, and leaves it unbound when the agent can't be created.
+func (a *Agents) CreateWithWallet(ctx context.Context, version *KnowledgeVersions) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		bound := tx.Model(&Wallet{}).Where("agent_address = ? AND cid = ''", a.AgentAddress).Update("cid", a.CID)
+		if bound.Error != nil {
+			return bound.Error
+		}
+		if bound.RowsAffected == 0 {
+			return ErrWalletUsed
+		}
+		return a.create(tx, version)
+	})
+}
+
+func (a *Agents) create(tx *gorm.DB, version *KnowledgeVersions) error {
 	// Check if CID already exists
 	var count int64
-	if err = pg.GetManager().GetClient("cybernity").GetDB(ctx).Model(&Agents{}).Where("cid = ?", a.CID).Count(&count).Error; err != nil {
+	if err := tx.Model(&Agents{}).Where("cid = ?", a.CID).Count(&count).Error; err != nil {
 		return err
 	}
 	if count > 0 {
 		return gorm.ErrDuplicatedKey
 	}
 
-	err = pg.GetManager().GetClient("cybernity").GetDB(ctx).Create(a).Error
-	return
+	if err := tx.Create(a).Error; err != nil {
+		return err
+	}
+	return tx.Create(version).Error
 }
+
 func (a *Agents) List(ctx context.Context) ([]*Agents, error) {
 	var agents []*Agents
 	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).Where("on_chain = ?", OnChain).Order("created_at desc").Find(&agents).Error
@@ -51,3 +94,19 @@ func (a *Agents) Get(ctx context.Context, cid string) (*Agents, error) {
 func (a *Agents) OnChain(ctx context.Context, cid string) error {
 	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Model(&Agents{}).Where("cid = ?", cid).Update("on_chain", OnChain).Error
 }
+
+// ContentCID returns the CID of the latest knowledge. The agent CID itself stays the on-chain identity.
+func (a *Agents) ContentCID() string {
+	if a.KnowledgeCID != "" {
+		return a.KnowledgeCID
+	}
+	return a.CID
+}
+
+// CurrentVersion returns the knowledge version, agents created before versioning are at version 1
+func (a *Agents) CurrentVersion() int {
+	if a.KnowledgeVersion < 1 {
+		return 1
+	}
+	return a.KnowledgeVersion
+}
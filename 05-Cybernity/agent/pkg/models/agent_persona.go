@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"cybernity/pkg/core/pg"
+
+	"gorm.io/gorm"
+)
+
+// AgentPersonas is the prompt template and answering style a creator configured for an agent
+type AgentPersonas struct {
+	AgentCID       string   `json:"agent_cid" gorm:"column:agent_cid"`
+	Template       string   `json:"template"`
+	Language       string   `json:"language"`
+	Tone           string   `json:"tone"`
+	RefusalPolicy  string   `json:"refusal_policy"`
+	Temperature    *float32 `json:"temperature"`
+	LLMModel       string   `json:"model" gorm:"column:model"`
+	Route          string   `json:"route"`
+	Tools          string   `json:"tools"` // comma separated
+	ResponseSchema string   `json:"response_schema"`
+	gorm.Model
+}
+
+func (AgentPersonas) TableName() string {
+	return "agent_personas"
+}
+
+func (p *AgentPersonas) Get(ctx context.Context, agentCID string) (*AgentPersonas, error) {
+	var persona AgentPersonas
+	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).Where("agent_cid = ?", agentCID).First(&persona).Error
+	return &persona, err
+}
+
+// Save replaces the persona of the agent
+func (p *AgentPersonas) Save(ctx context.Context) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("agent_cid = ?", p.AgentCID).Delete(&AgentPersonas{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(p).Error
+	})
+}
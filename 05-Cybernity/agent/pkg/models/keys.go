@@ -2,9 +2,25 @@ package models
 
 import "encoding/json"
 
+// Encryption schemes an agent's knowledge can be encrypted with
+const (
+	EncryptionSchemeRSAOAEP        = "rsa-oaep"        // RSA-OAEP + AES-GCM hybrid
+	EncryptionSchemeECIESSecp256k1 = "ecies-secp256k1" // ECIES to the agent's Ethereum key
+	EncryptionSchemeECIESX25519    = "ecies-x25519"    // ECIES to a dedicated X25519 key
+)
+
 type AgentKeys struct {
-	EncryptionPrivateKey string `json:"encryption_private_key"` // RSA key in PEM format
-	BlockchainPrivateKey string `json:"blockchain_private_key"` // Ethereum key in hex format
+	EncryptionScheme     string `json:"encryption_scheme,omitempty"` // empty means rsa-oaep
+	EncryptionPrivateKey string `json:"encryption_private_key"`      // RSA key in PEM format, X25519 key in hex format
+	BlockchainPrivateKey string `json:"blockchain_private_key"`      // Ethereum key in hex format
+}
+
+// Scheme returns the encryption scheme, defaulting to rsa-oaep for keys created before schemes existed
+func (k *AgentKeys) Scheme() string {
+	if k.EncryptionScheme == "" {
+		return EncryptionSchemeRSAOAEP
+	}
+	return k.EncryptionScheme
 }
 
 func (k *AgentKeys) ToJSON() (string, error) {
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"cybernity/pkg/core/pg"
+
+	"gorm.io/gorm"
+)
+
+// KnowledgeIndexes stores the retrieval index of a knowledge CID, encrypted to the agent
+// since its chunks are the plaintext knowledge
+type KnowledgeIndexes struct {
+	AgentCID     string `json:"agent_cid" gorm:"column:agent_cid"`
+	KnowledgeCID string `json:"knowledge_cid" gorm:"column:knowledge_cid"`
+	Embedder     string `json:"embedder"`
+	Chunks       int    `json:"chunks"`
+	Sealed       []byte `json:"-"`
+	gorm.Model
+}
+
+func (KnowledgeIndexes) TableName() string {
+	return "knowledge_indexes"
+}
+
+func (i *KnowledgeIndexes) Get(ctx context.Context, knowledgeCID, embedder string) (*KnowledgeIndexes, error) {
+	var index KnowledgeIndexes
+	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).
+		Where("knowledge_cid = ? AND embedder = ?", knowledgeCID, embedder).First(&index).Error
+	return &index, err
+}
+
+// Save replaces any index of the same knowledge CID built by the same embedder
+func (i *KnowledgeIndexes) Save(ctx context.Context) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("knowledge_cid = ? AND embedder = ?", i.KnowledgeCID, i.Embedder).
+			Delete(&KnowledgeIndexes{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(i).Error
+	})
+}
+
+// DeleteByKnowledgeCID drops the indexes of content that is no longer pinned
+func (i *KnowledgeIndexes) DeleteByKnowledgeCID(ctx context.Context, knowledgeCID string) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Unscoped().
+		Where("knowledge_cid = ?", knowledgeCID).Delete(&KnowledgeIndexes{}).Error
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"cybernity/pkg/core/pg"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// KnowledgeVersions is the history of the knowledge published for an agent
+type KnowledgeVersions struct {
+	AgentCID        string `json:"agent_cid" gorm:"column:agent_cid"`
+	Version         int    `json:"version"`
+	CID             string `json:"cid" gorm:"column:cid"`
+	KnowledgeFormat string `json:"knowledge_format"`
+	Files           int    `json:"files"`
+	Size            int64  `json:"size"` // encrypted size
+	Change          string `json:"change"`
+	gorm.Model
+}
+
+func (KnowledgeVersions) TableName() string {
+	return "knowledge_versions"
+}
+
+// How a knowledge version was produced
+const (
+	KnowledgeChangeCreate  = "create"
+	KnowledgeChangeAppend  = "append"
+	KnowledgeChangeReplace = "replace"
+)
+
+// ErrVersionConflict is returned when the agent was updated concurrently
+var ErrVersionConflict = errors.New("knowledge was updated concurrently, retry")
+
+func (v *KnowledgeVersions) Create(ctx context.Context) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Create(v).Error
+}
+
+// Publish records the version and makes it the agent's latest knowledge,
+// failing with ErrVersionConflict if the agent is no longer at the previous version
+func (v *KnowledgeVersions) Publish(ctx context.Context, previous int) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Agents{}).
+			Where("cid = ? AND coalesce(knowledge_version, 0) IN ?", v.AgentCID, []int{previous, previousOrLegacy(previous)}).
+			Updates(map[string]interface{}{
+				"knowledge_cid":     v.CID,
+				"knowledge_format":  v.KnowledgeFormat,
+				"knowledge_version": v.Version,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return tx.Create(v).Error
+	})
+}
+
+// previousOrLegacy maps version 1 to 0, the version stored for agents created before versioning
+func previousOrLegacy(version int) int {
+	if version == 1 {
+		return 0
+	}
+	return version
+}
+
+func (v *KnowledgeVersions) ListByAgent(ctx context.Context, agentCID string) ([]*KnowledgeVersions, error) {
+	var versions []*KnowledgeVersions
+	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).Where("agent_cid = ?", agentCID).Order("version desc").Find(&versions).Error
+	return versions, err
+}
+
+func (v *KnowledgeVersions) Get(ctx context.Context, agentCID string, version int) (*KnowledgeVersions, error) {
+	var kv KnowledgeVersions
+	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).Where("agent_cid = ? AND version = ?", agentCID, version).First(&kv).Error
+	return &kv, err
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"cybernity/pkg/core/pg"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Pins records every CID we pinned, so the content can be accounted for and unpinned later
+type Pins struct {
+	CID            string     `json:"cid" gorm:"column:cid"`
+	Name           string     `json:"name"`
+	Purpose        string     `json:"purpose"`
+	AgentCID       string     `json:"agent_cid" gorm:"column:agent_cid"` // knowledge CID of the owning agent
+	CreatorAddress string     `json:"creator_address"`
+	Size           int64      `json:"size"`
+	Backend        string     `json:"backend"`
+	SupersededAt   *time.Time `json:"superseded_at"` // replaced by newer content, eligible for unpinning
+	UnpinnedAt     *time.Time `json:"unpinned_at"`
+	gorm.Model
+}
+
+func (Pins) TableName() string {
+	return "pins"
+}
+
+// What a pinned CID holds
+const (
+	PinPurposeKnowledge = "knowledge"
+	PinPurposeAnswer    = "answer"
+)
+
+// PinUsage is the pinned content of a creator for one purpose
+type PinUsage struct {
+	Purpose string `json:"purpose"`
+	Count   int64  `json:"count"`
+	Size    int64  `json:"size"`
+}
+
+func (p *Pins) Create(ctx context.Context) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Create(p).Error
+}
+
+func (p *Pins) Get(ctx context.Context, cid string) (*Pins, error) {
+	var pin Pins
+	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).Where("cid = ?", cid).First(&pin).Error
+	return &pin, err
+}
+
+func (p *Pins) ListByCreator(ctx context.Context, creatorAddress string) ([]*Pins, error) {
+	var pins []*Pins
+	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).
+		Where("lower(creator_address) = lower(?) AND unpinned_at IS NULL", creatorAddress).
+		Order("created_at desc").Find(&pins).Error
+	return pins, err
+}
+
+// UsageByCreator sums the content still pinned for a creator, per purpose
+func (p *Pins) UsageByCreator(ctx context.Context, creatorAddress string) ([]*PinUsage, error) {
+	var usage []*PinUsage
+	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).Model(&Pins{}).
+		Select("purpose, count(*) AS count, coalesce(sum(size), 0) AS size").
+		Where("lower(creator_address) = lower(?) AND unpinned_at IS NULL", creatorAddress).
+		Group("purpose").Order("purpose").Scan(&usage).Error
+	return usage, err
+}
+
+// ListOffChainBefore returns pins of agents that were never put on-chain and were pinned before the given time
+func (p *Pins) ListOffChainBefore(ctx context.Context, before time.Time) ([]*Pins, error) {
+	var pins []*Pins
+	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).
+		Joins("JOIN agents ON agents.cid = pins.agent_cid AND agents.deleted_at IS NULL").
+		Where("coalesce(agents.on_chain, ?) = ? AND pins.unpinned_at IS NULL AND pins.created_at < ?", OffChain, OffChain, before).
+		Find(&pins).Error
+	return pins, err
+}
+
+// ListSupersededBefore returns pins that were superseded before the given time
+func (p *Pins) ListSupersededBefore(ctx context.Context, before time.Time) ([]*Pins, error) {
+	var pins []*Pins
+	err := pg.GetManager().GetClient("cybernity").GetDB(ctx).
+		Where("unpinned_at IS NULL AND superseded_at < ?", before).
+		Find(&pins).Error
+	return pins, err
+}
+
+func (p *Pins) MarkSuperseded(ctx context.Context, cid string) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Model(&Pins{}).
+		Where("cid = ? AND superseded_at IS NULL", cid).Update("superseded_at", time.Now()).Error
+}
+
+func (p *Pins) MarkUnpinned(ctx context.Context, cid string) error {
+	return pg.GetManager().GetClient("cybernity").GetDB(ctx).Model(&Pins{}).
+		Where("cid = ? AND unpinned_at IS NULL", cid).Update("unpinned_at", time.Now()).Error
+}
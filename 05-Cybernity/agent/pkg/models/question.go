@@ -17,6 +17,7 @@ type Questions struct {
 	TransactionHash string `json:"transaction_hash"`
 	Question        string `json:"question" gorm:"type:text"`
 	Answer          string `json:"answer" gorm:"type:text"`
+	AnswerEncrypted bool   `json:"answer_encrypted"` // answer on IPFS is encrypted to the questioner
 	gorm.Model
 }
 
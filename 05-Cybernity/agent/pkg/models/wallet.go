@@ -3,10 +3,14 @@ package models
 import (
 	"context"
 	"cybernity/pkg/core/pg"
+	"errors"
 
 	"gorm.io/gorm"
 )
 
+// ErrWalletUsed is returned when reserved agent keys are already bound to an agent
+var ErrWalletUsed = errors.New("agent keys have already been used")
+
 type Wallet struct {
 	CreatorAddress  string `json:"creator_address"`
 	CID             string `json:"cid" gorm:"column:cid"`
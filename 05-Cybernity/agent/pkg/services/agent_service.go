@@ -2,7 +2,13 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"cybernity/pkg/core/cache"
+	"cybernity/pkg/core/knowledge"
 	"cybernity/pkg/models"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"sync"
 )
 
@@ -21,11 +27,18 @@ func NewAgentService() *agentService {
 }
 
 type CreateAgentSvcRequest struct {
-	Name           string `json:"name"`
-	Description    string `json:"description"`
-	CID            string `json:"cid"`
-	CreatorAddress string `json:"creator_address"`
-	AgentAddress   string `json:"agent_address"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	CID             string `json:"cid"`
+	CreatorAddress  string `json:"creator_address"`
+	AgentAddress    string `json:"agent_address"`
+	EncryptionMode  string `json:"encryption_mode"`
+	WrappedKey      string `json:"wrapped_key"`
+	KnowledgeFormat string `json:"knowledge_format"`
+	KnowledgeFiles  int    `json:"knowledge_files"`
+	KnowledgeSize   int64  `json:"knowledge_size"`
+	// ReservedWallet binds the wallet reserved for the agent address to the agent as it is created
+	ReservedWallet bool `json:"-"`
 }
 
 type CreateWalletSvcRequest struct {
@@ -48,13 +61,29 @@ func (s *agentService) CreateWallet(ctx context.Context, req *CreateWalletSvcReq
 
 func (s *agentService) CreateAgent(ctx context.Context, req *CreateAgentSvcRequest) error {
 	agent := &models.Agents{
-		Name:           req.Name,
-		Description:    req.Description,
-		CID:            req.CID,
-		CreatorAddress: req.CreatorAddress,
-		AgentAddress:   req.AgentAddress,
+		Name:             req.Name,
+		Description:      req.Description,
+		CID:              req.CID,
+		CreatorAddress:   req.CreatorAddress,
+		AgentAddress:     req.AgentAddress,
+		EncryptionMode:   req.EncryptionMode,
+		WrappedKey:       req.WrappedKey,
+		KnowledgeFormat:  req.KnowledgeFormat,
+		KnowledgeVersion: 1,
+	}
+	version := &models.KnowledgeVersions{
+		AgentCID:        req.CID,
+		Version:         1,
+		CID:             req.CID,
+		KnowledgeFormat: req.KnowledgeFormat,
+		Files:           req.KnowledgeFiles,
+		Size:            req.KnowledgeSize,
+		Change:          models.KnowledgeChangeCreate,
+	}
+	if req.ReservedWallet {
+		return agent.CreateWithWallet(ctx, version)
 	}
-	return agent.Create(ctx)
+	return agent.Create(ctx, version)
 }
 func (s *agentService) ListAgent(ctx context.Context) ([]*models.Agents, error) {
 	return (&models.Agents{}).List(ctx)
@@ -66,3 +95,148 @@ func (s *agentService) GetAgent(ctx context.Context, cid string) (*models.Agents
 func (s *agentService) UpdateOnChain(ctx context.Context, cid string) error {
 	return (&models.Agents{}).OnChain(ctx, cid)
 }
+
+// GetKnowledge returns the agent's decrypted knowledge, from the cache when possible.
+// Cached entries are versioned by the agent's keys, so rotating them invalidates the entry.
+func (s *agentService) GetKnowledge(ctx context.Context, agent *models.Agents) ([]byte, error) {
+	wallet, err := NewWalletService().GetWalletForAgent(ctx, agent.AgentAddress)
+	if err != nil {
+		return nil, err
+	}
+	version, err := knowledgeVersion(wallet, agent)
+	if err != nil {
+		return nil, err
+	}
+
+	knowledgeCache := cache.GetCache()
+	if corpus, ok := knowledgeCache.Get(agent.ContentCID(), version); ok {
+		return corpus, nil
+	}
+
+	docs, err := s.GetTexts(ctx, agent)
+	if err != nil {
+		return nil, err
+	}
+	corpus := knowledge.Corpus(docs)
+
+	knowledgeCache.Set(agent.ContentCID(), version, corpus)
+	return corpus, nil
+}
+
+// GetTexts returns the documents of the agent's knowledge with their content replaced by the extracted text
+func (s *agentService) GetTexts(ctx context.Context, agent *models.Agents) ([]knowledge.Document, error) {
+	docs, err := s.GetDocuments(ctx, agent)
+	if err != nil {
+		return nil, err
+	}
+	// Hand the model text rather than raw PDF or DOCX bytes
+	for i, doc := range docs {
+		text, _, err := knowledge.Extract(doc.Name, doc.ContentType, doc.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract text from %s: %w", doc.Name, err)
+		}
+		docs[i].Content = []byte(text)
+	}
+	return docs, nil
+}
+
+// GetDocuments downloads and decrypts every document of the agent's knowledge
+func (s *agentService) GetDocuments(ctx context.Context, agent *models.Agents) ([]knowledge.Document, error) {
+	ipfsService := NewIpfsService()
+	cid := agent.ContentCID()
+
+	if agent.KnowledgeFormat != models.KnowledgeFormatDirectory {
+		ciphertext, err := ipfsService.DownloadFile(ctx, cid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download knowledge: %w", err)
+		}
+		plaintext, err := s.DecryptKnowledge(ctx, agent, []byte(ciphertext))
+		if err != nil {
+			return nil, err
+		}
+		// The pin keeps the uploaded file name, agents created before pins were recorded fall back to the agent name
+		name := agent.Name
+		if pin, err := (&models.Pins{}).Get(ctx, cid); err == nil && pin.Name != "" {
+			name = pin.Name
+		}
+		return []knowledge.Document{{Name: name, Content: plaintext}}, nil
+	}
+
+	manifestJSON, err := ipfsService.DownloadDirectoryFile(ctx, cid, knowledge.ManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download manifest: %w", err)
+	}
+	manifest, err := knowledge.ParseManifest(manifestJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]knowledge.Document, 0, len(manifest.Files))
+	for _, f := range manifest.Files {
+		ciphertext, err := ipfsService.DownloadDirectoryFile(ctx, cid, f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", f.Name, err)
+		}
+		plaintext, err := s.DecryptKnowledge(ctx, agent, ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", f.Name, err)
+		}
+		docs = append(docs, knowledge.Document{Name: f.Name, ContentType: f.ContentType, Content: plaintext})
+	}
+	return docs, nil
+}
+
+// InvalidateKnowledge drops any cached plaintext of the knowledge at cid, including its retrieval index
+func (s *agentService) InvalidateKnowledge(cid string) {
+	cache.GetCache().Invalidate(cid)
+	cache.GetCache().Invalidate(indexCacheKey(cid))
+}
+
+// knowledgeVersion fingerprints everything needed to decrypt the knowledge. Versions are stored in the clear
+// next to cached entries, so the agent's keys are fingerprinted by their public key.
+func knowledgeVersion(wallet *models.Wallet, agent *models.Agents) (string, error) {
+	keys, err := models.AgentKeysFromJSON(wallet.AgentPrivateKey)
+	if err != nil {
+		return "", err
+	}
+	publicKey, err := NewEncryptService().AgentPublicKey(keys)
+	if err != nil {
+		return "", fmt.Errorf("failed to get agent public key: %w", err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(keys.Scheme()))
+	h.Write([]byte{0})
+	h.Write(publicKey)
+	h.Write([]byte{0})
+	h.Write([]byte(agent.EncryptionMode))
+	h.Write([]byte{0})
+	h.Write([]byte(agent.WrappedKey))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DecryptKnowledge decrypts the knowledge blob stored at the agent's CID according to its encryption mode
+func (s *agentService) DecryptKnowledge(ctx context.Context, agent *models.Agents, ciphertext []byte) ([]byte, error) {
+	keys, err := NewWalletService().GetKeysForAgent(ctx, agent.AgentAddress)
+	if err != nil {
+		return nil, err
+	}
+	return decryptKnowledge(agent, keys, ciphertext)
+}
+
+func decryptKnowledge(agent *models.Agents, keys *models.AgentKeys, ciphertext []byte) ([]byte, error) {
+	encryptSvc := NewEncryptService()
+	if agent.EncryptionMode != models.EncryptionModeWrappedKey {
+		return encryptSvc.DecryptWithAgentKeys(ciphertext, keys)
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(agent.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	contentKey, err := encryptSvc.DecryptWithAgentKeys(wrappedKey, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap content key: %w", err)
+	}
+	return encryptSvc.DecryptAESGCM(ciphertext, contentKey)
+}
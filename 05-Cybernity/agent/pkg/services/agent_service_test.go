@@ -0,0 +1,110 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"cybernity/pkg/models"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestDecryptKnowledge(t *testing.T) {
+	encryptSvc := NewEncryptService()
+	newKeys := func() (*models.AgentKeys, []byte) {
+		ethKey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("GenerateKey() error = %v", err)
+		}
+		keys, pub, err := encryptSvc.GenerateAgentKeys(models.EncryptionSchemeECIESX25519, ethKey)
+		if err != nil {
+			t.Fatalf("GenerateAgentKeys() error = %v", err)
+		}
+		return keys, pub
+	}
+	keys, pub := newKeys()
+	otherKeys, otherPub := newKeys()
+	msg := []byte("knowledge the creator encrypted in the browser")
+
+	// As the browser does: to the published public key, or under a content key wrapped to it
+	toAgent, err := encryptSvc.EncryptWithScheme(models.EncryptionSchemeECIESX25519, msg, pub)
+	if err != nil {
+		t.Fatalf("EncryptWithScheme() error = %v", err)
+	}
+	contentKey := make([]byte, 32)
+	rand.Read(contentKey)
+	underContentKey, err := encryptSvc.EncryptAESGCM(msg, contentKey)
+	if err != nil {
+		t.Fatalf("EncryptAESGCM() error = %v", err)
+	}
+	wrap := func(pub []byte) string {
+		wrapped, err := encryptSvc.EncryptWithScheme(models.EncryptionSchemeECIESX25519, contentKey, pub)
+		if err != nil {
+			t.Fatalf("EncryptWithScheme() error = %v", err)
+		}
+		return base64.StdEncoding.EncodeToString(wrapped)
+	}
+
+	tests := []struct {
+		name       string
+		agent      *models.Agents
+		keys       *models.AgentKeys
+		ciphertext []byte
+		wantErr    bool
+	}{
+		{"client", &models.Agents{EncryptionMode: models.EncryptionModeClient}, keys, toAgent, false},
+		{"wrapped key", &models.Agents{EncryptionMode: models.EncryptionModeWrappedKey, WrappedKey: wrap(pub)}, keys, underContentKey, false},
+		{"client to another agent", &models.Agents{EncryptionMode: models.EncryptionModeClient}, otherKeys, toAgent, true},
+		{"key wrapped to another agent", &models.Agents{EncryptionMode: models.EncryptionModeWrappedKey, WrappedKey: wrap(otherPub)}, keys, underContentKey, true},
+		{"invalid wrapped key", &models.Agents{EncryptionMode: models.EncryptionModeWrappedKey, WrappedKey: "not base64!"}, keys, underContentKey, true},
+		{"wrapped key without content key", &models.Agents{EncryptionMode: models.EncryptionModeClient}, keys, underContentKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := decryptKnowledge(tt.agent, tt.keys, tt.ciphertext)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("decryptKnowledge() = %q, want an error", plaintext)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(plaintext, msg) {
+				t.Errorf("decryptKnowledge() = %q, %v, want %q", plaintext, err, msg)
+			}
+		})
+	}
+}
+
+func TestKnowledgeVersion(t *testing.T) {
+	newWallet := func() *models.Wallet {
+		ethKey, _ := crypto.GenerateKey()
+		keys, _, err := NewEncryptService().GenerateAgentKeys(models.EncryptionSchemeECIESX25519, ethKey)
+		if err != nil {
+			t.Fatalf("GenerateAgentKeys() error = %v", err)
+		}
+		keysJSON, _ := keys.ToJSON()
+		return &models.Wallet{AgentPrivateKey: keysJSON}
+	}
+	wallet := newWallet()
+	agent := &models.Agents{EncryptionMode: models.EncryptionModeWrappedKey, WrappedKey: "d3JhcHBlZA=="}
+
+	version, err := knowledgeVersion(wallet, agent)
+	if err != nil {
+		t.Fatalf("knowledgeVersion() error = %v", err)
+	}
+	keys, _ := models.AgentKeysFromJSON(wallet.AgentPrivateKey)
+	if strings.Contains(version, keys.EncryptionPrivateKey) {
+		t.Error("knowledgeVersion() reveals the private key")
+	}
+	if again, _ := knowledgeVersion(&models.Wallet{AgentPrivateKey: wallet.AgentPrivateKey}, agent); again != version {
+		t.Errorf("knowledgeVersion() = %s, then %s for the same keys", version, again)
+	}
+	if rotated, _ := knowledgeVersion(newWallet(), agent); rotated == version {
+		t.Error("knowledgeVersion() did not change with the agent's keys")
+	}
+	if rewrapped, _ := knowledgeVersion(wallet, &models.Agents{EncryptionMode: models.EncryptionModeWrappedKey, WrappedKey: "b3RoZXI="}); rewrapped == version {
+		t.Error("knowledgeVersion() did not change with the wrapped key")
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type answerService struct{}
+
+var (
+	AnswerService     *answerService
+	answerServiceOnce sync.Once
+)
+
+func NewAnswerService() *answerService {
+	answerServiceOnce.Do(func() {
+		AnswerService = &answerService{}
+	})
+	return AnswerService
+}
+
+// senderRecoverer recovers the public key that signed a transaction, as ethService does from the chain
+type senderRecoverer interface {
+	SenderPublicKey(ctx context.Context, txHash common.Hash) (*ecdsa.PublicKey, common.Address, error)
+}
+
+// EncryptForQuestioner encrypts the answer to the public key recovered from the askQuestion transaction.
+// The recovered signer must match the questioner emitted in the event.
+func (s *answerService) EncryptForQuestioner(ctx context.Context, ethSvc senderRecoverer, txHash common.Hash, questioner common.Address, answer []byte) ([]byte, error) {
+	publicKey, sender, err := ethSvc.SenderPublicKey(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover questioner public key: %w", err)
+	}
+	if sender != questioner {
+		return nil, fmt.Errorf("transaction signer %s does not match questioner %s", sender.Hex(), questioner.Hex())
+	}
+
+	return NewEncryptService().EncryptECIESSecp256k1(answer, publicKey)
+}
+
+// DecryptAnswer decrypts an answer encrypted by EncryptForQuestioner with the questioner's private key
+func (s *answerService) DecryptAnswer(ciphertext []byte, questionerPrivateKey *ecdsa.PrivateKey) ([]byte, error) {
+	return NewEncryptService().DecryptECIESSecp256k1(ciphertext, questionerPrivateKey)
+}
+
+// DecryptAnswerHex is DecryptAnswer for a hex encoded private key
+func (s *answerService) DecryptAnswerHex(ciphertext []byte, questionerPrivateKeyHex string) ([]byte, error) {
+	privateKey, err := crypto.HexToECDSA(questionerPrivateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return s.DecryptAnswer(ciphertext, privateKey)
+}
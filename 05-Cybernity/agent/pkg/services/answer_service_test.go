@@ -0,0 +1,64 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"cybernity/pkg/core/eth"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// signedTx recovers the sender of a transaction signed in the test, as the chain would
+type signedTx struct {
+	tx      *types.Transaction
+	chainID *big.Int
+}
+
+func (s signedTx) SenderPublicKey(ctx context.Context, txHash common.Hash) (*ecdsa.PublicKey, common.Address, error) {
+	return eth.RecoverPublicKey(s.tx, s.chainID)
+}
+
+func TestEncryptForQuestioner(t *testing.T) {
+	questionerKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	questioner := crypto.PubkeyToAddress(questionerKey.PublicKey)
+	chainID := big.NewInt(11155111)
+	to := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{ChainID: chainID, To: &to, Gas: 21000}),
+		types.LatestSignerForChainID(chainID), questionerKey)
+	if err != nil {
+		t.Fatalf("SignTx() error = %v", err)
+	}
+	answer := []byte("42")
+
+	answerSvc := NewAnswerService()
+	ciphertext, err := answerSvc.EncryptForQuestioner(context.Background(), signedTx{tx, chainID}, tx.Hash(), questioner, answer)
+	if err != nil {
+		t.Fatalf("EncryptForQuestioner() error = %v", err)
+	}
+	if bytes.Contains(ciphertext, answer) {
+		t.Error("EncryptForQuestioner() left the answer readable")
+	}
+
+	plaintext, err := answerSvc.DecryptAnswerHex(ciphertext, common.Bytes2Hex(crypto.FromECDSA(questionerKey)))
+	if err != nil || !bytes.Equal(plaintext, answer) {
+		t.Errorf("DecryptAnswerHex() = %q, %v, want %q", plaintext, err, answer)
+	}
+
+	otherKey, _ := crypto.GenerateKey()
+	if _, err := answerSvc.DecryptAnswer(ciphertext, otherKey); err == nil {
+		t.Error("DecryptAnswer() decrypted with another key")
+	}
+	// The event's questioner must have signed the transaction the key is recovered from
+	other := crypto.PubkeyToAddress(otherKey.PublicKey)
+	if _, err := answerSvc.EncryptForQuestioner(context.Background(), signedTx{tx, chainID}, tx.Hash(), other, answer); err == nil {
+		t.Error("EncryptForQuestioner() encrypted to a transaction signed by someone else")
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"cybernity/internal/config"
+	"cybernity/pkg/core/answerdoc"
+	"cybernity/pkg/core/attestation"
+	"cybernity/pkg/core/llm"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type attestationService struct{}
+
+var (
+	AttestationService     *attestationService
+	attestationServiceOnce sync.Once
+)
+
+func NewAttestationService() *attestationService {
+	attestationServiceOnce.Do(func() {
+		AttestationService = &attestationService{}
+	})
+	return AttestationService
+}
+
+type AttestSvcRequest struct {
+	AgentBlockchainKey string   // operator key in hex format
+	QuestionID         *big.Int // on-chain question id
+	AgentCID           string
+	KnowledgeCID       string
+	Question           string
+	Answer             []byte // plaintext answer
+	Model              string
+	Usage              llm.Usage
+	Citations          []answerdoc.Citation
+	Ciphertext         []byte // published and hashed into the attestation instead of the plaintext when not nil
+}
+
+// Attest builds the answer document and signs an attestation for it with the agent's operator key
+func (s *attestationService) Attest(ctx context.Context, req *AttestSvcRequest) (*answerdoc.Document, error) {
+	privateKey, err := crypto.HexToECDSA(req.AgentBlockchainKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent key: %w", err)
+	}
+
+	now := time.Now().UTC()
+	doc := &answerdoc.Document{
+		Schema:       answerdoc.SchemaV1,
+		QuestionID:   req.QuestionID.String(),
+		QuestionHash: answerdoc.HashQuestion(req.Question),
+		AgentCID:     req.AgentCID,
+		KnowledgeCID: req.KnowledgeCID,
+		Answer:       string(req.Answer),
+		Model:        req.Model,
+		Usage: &answerdoc.Usage{
+			PromptTokens:     req.Usage.PromptTokens,
+			CompletionTokens: req.Usage.CompletionTokens,
+			TotalTokens:      req.Usage.TotalTokens,
+		},
+		CreatedAt: now,
+		Citations: req.Citations,
+		Attestation: &attestation.Attestation{
+			Version:      attestation.Version,
+			QuestionID:   req.QuestionID.String(),
+			AgentCID:     req.AgentCID,
+			KnowledgeCID: req.KnowledgeCID,
+			AnswerHash:   attestation.HashAnswer(req.Answer),
+			Model:        req.Model,
+			Timestamp:    now.Unix(),
+		},
+	}
+	if req.Ciphertext != nil {
+		doc.Answer = base64.StdEncoding.EncodeToString(req.Ciphertext)
+		doc.Encrypted = true
+		doc.Attestation.AnswerHash = attestation.HashAnswer(req.Ciphertext)
+	}
+
+	if err := doc.Attestation.Sign(privateKey); err != nil {
+		return nil, fmt.Errorf("failed to sign attestation: %w", err)
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid answer document: %w", err)
+	}
+	return doc, nil
+}
+
+type AttestationVerification struct {
+	Valid           bool   `json:"valid"`
+	Signer          string `json:"signer"`
+	Operator        string `json:"operator"`
+	AnswerHashValid bool   `json:"answer_hash_valid"` // of the ciphertext for encrypted answers
+	Reason          string `json:"reason,omitempty"`
+}
+
+// Verify checks the document's attestation against the operator registered on-chain for the agent CID
+func (s *attestationService) Verify(ctx context.Context, doc *answerdoc.Document) (*AttestationVerification, error) {
+	if doc.Attestation == nil {
+		return &AttestationVerification{Reason: "answer document has no attestation"}, nil
+	}
+	signer, err := doc.Attestation.Signer()
+	if err != nil {
+		return &AttestationVerification{Reason: "invalid signature: " + err.Error()}, nil
+	}
+
+	operator, err := NewEthService(config.AppConfig.Eth).GetAgentOperator(ctx, doc.Attestation.AgentCID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get on-chain operator: %w", err)
+	}
+
+	verification := &AttestationVerification{
+		Signer:   signer.Hex(),
+		Operator: operator.Hex(),
+	}
+	if answer, err := doc.PublishedAnswer(); err == nil {
+		verification.AnswerHashValid = attestation.HashAnswer(answer) == doc.Attestation.AnswerHash
+	}
+
+	switch {
+	case signer != operator:
+		verification.Reason = "signer is not the on-chain operator of the agent"
+	case !strings.EqualFold(doc.Attestation.AgentAddress, signer.Hex()):
+		verification.Reason = "agent address does not match signer"
+	case doc.Attestation.KnowledgeCID != doc.KnowledgeCID:
+		verification.Reason = "knowledge CID does not match the attested knowledge CID"
+	case !verification.AnswerHashValid:
+		verification.Reason = "answer does not match answer hash"
+	default:
+		verification.Valid = true
+	}
+	return verification, nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"cybernity/pkg/core/shamir"
+	"cybernity/pkg/models"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+)
+
+const BackupVersion = 1
+
+type backupService struct{}
+
+var (
+	BackupService     *backupService
+	backupServiceOnce sync.Once
+)
+
+func NewBackupService() *backupService {
+	backupServiceOnce.Do(func() {
+		BackupService = &backupService{}
+	})
+	return BackupService
+}
+
+// Custodian holds one share of every agent's key material
+type Custodian struct {
+	Name      string
+	PublicKey *ecdsa.PublicKey // secp256k1 key the share file is encrypted to
+}
+
+// BackupShareFile is the file handed to a custodian, its shares are encrypted to the custodian's key
+type BackupShareFile struct {
+	Version    int    `json:"version"`
+	Custodian  string `json:"custodian"`
+	Threshold  int    `json:"threshold"`
+	Total      int    `json:"total"`
+	CreatedAt  int64  `json:"created_at"`
+	Ciphertext string `json:"ciphertext"` // base64 ECIES encrypted JSON of []BackupShare
+}
+
+// BackupShare is one custodian's share of a single agent's key material
+type BackupShare struct {
+	AgentAddress   string `json:"agent_address"`
+	CID            string `json:"cid"`
+	CreatorAddress string `json:"creator_address"`
+	Share          string `json:"share"` // hex
+}
+
+// Export splits every agent's key material into threshold-of-len(custodians) shares
+// and returns one encrypted share file per custodian, in the order of custodians.
+func (s *backupService) Export(ctx context.Context, custodians []Custodian, threshold int) ([]*BackupShareFile, error) {
+	wallets, err := (&models.Wallet{}).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.split(wallets, custodians, threshold)
+}
+
+func (s *backupService) split(wallets []*models.Wallet, custodians []Custodian, threshold int) ([]*BackupShareFile, error) {
+	custodianShares := make([][]BackupShare, len(custodians))
+	for _, wallet := range wallets {
+		parts, err := shamir.Split([]byte(wallet.AgentPrivateKey), len(custodians), threshold)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split keys of agent %s: %w", wallet.AgentAddress, err)
+		}
+		for i, part := range parts {
+			custodianShares[i] = append(custodianShares[i], BackupShare{
+				AgentAddress:   wallet.AgentAddress,
+				CID:            wallet.CID,
+				CreatorAddress: wallet.CreatorAddress,
+				Share:          hex.EncodeToString(part),
+			})
+		}
+	}
+
+	encryptSvc := NewEncryptService()
+	createdAt := time.Now().Unix()
+	files := make([]*BackupShareFile, len(custodians))
+	for i, custodian := range custodians {
+		plaintext, err := json.Marshal(custodianShares[i])
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := encryptSvc.EncryptECIESSecp256k1(plaintext, custodian.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt shares for %s: %w", custodian.Name, err)
+		}
+		files[i] = &BackupShareFile{
+			Version:    BackupVersion,
+			Custodian:  custodian.Name,
+			Threshold:  threshold,
+			Total:      len(custodians),
+			CreatedAt:  createdAt,
+			Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+		}
+	}
+	return files, nil
+}
+
+// OpenShareFile decrypts a custodian's share file with the custodian's private key
+func (s *backupService) OpenShareFile(file *BackupShareFile, privateKey *ecdsa.PrivateKey) ([]BackupShare, error) {
+	if file.Version != BackupVersion {
+		return nil, fmt.Errorf("unsupported backup version: %d", file.Version)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(file.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid share file ciphertext: %w", err)
+	}
+	plaintext, err := NewEncryptService().DecryptECIESSecp256k1(ciphertext, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt share file of %s: %w", file.Custodian, err)
+	}
+
+	var shares []BackupShare
+	if err := json.Unmarshal(plaintext, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// Reconstruct combines the decrypted shares of at least threshold custodians into wallets
+func (s *backupService) Reconstruct(custodianShares [][]BackupShare) ([]*models.Wallet, error) {
+	if len(custodianShares) < 2 {
+		return nil, shamir.ErrNotEnoughShares
+	}
+
+	byAgent := make(map[string][][]byte)
+	var wallets []*models.Wallet
+	for _, shares := range custodianShares {
+		for _, share := range shares {
+			part, err := hex.DecodeString(share.Share)
+			if err != nil {
+				return nil, fmt.Errorf("invalid share for agent %s: %w", share.AgentAddress, err)
+			}
+			if _, ok := byAgent[share.AgentAddress]; !ok {
+				wallets = append(wallets, &models.Wallet{
+					AgentAddress:   share.AgentAddress,
+					CID:            share.CID,
+					CreatorAddress: share.CreatorAddress,
+				})
+			}
+			byAgent[share.AgentAddress] = append(byAgent[share.AgentAddress], part)
+		}
+	}
+
+	for _, wallet := range wallets {
+		secret, err := shamir.Combine(byAgent[wallet.AgentAddress])
+		if err != nil {
+			return nil, fmt.Errorf("failed to combine shares of agent %s: %w", wallet.AgentAddress, err)
+		}
+
+		// Shares below the threshold combine to garbage, so check the result is the agent's key
+		keys, err := models.AgentKeysFromJSON(string(secret))
+		if err != nil {
+			return nil, fmt.Errorf("not enough shares to recover agent %s", wallet.AgentAddress)
+		}
+		ethKey, err := crypto.HexToECDSA(keys.BlockchainPrivateKey)
+		if err != nil || crypto.PubkeyToAddress(ethKey.PublicKey).Hex() != wallet.AgentAddress {
+			return nil, fmt.Errorf("recovered keys do not match agent %s", wallet.AgentAddress)
+		}
+		wallet.AgentPrivateKey = string(secret)
+	}
+	return wallets, nil
+}
+
+// Restore writes reconstructed wallets that are missing from the database and returns how many were created
+func (s *backupService) Restore(ctx context.Context, wallets []*models.Wallet) (int, error) {
+	restored := 0
+	for _, wallet := range wallets {
+		_, err := (&models.Wallet{}).GetWalletByAgentAddress(ctx, wallet.AgentAddress)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return restored, err
+		}
+		if err := wallet.Create(ctx); err != nil {
+			return restored, fmt.Errorf("failed to restore agent %s: %w", wallet.AgentAddress, err)
+		}
+		restored++
+	}
+	return restored, nil
+}
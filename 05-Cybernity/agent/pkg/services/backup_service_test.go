@@ -0,0 +1,87 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"cybernity/pkg/core/shamir"
+	"cybernity/pkg/models"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	backupSvc := NewBackupService()
+	var wallets []*models.Wallet
+	for _, scheme := range []string{models.EncryptionSchemeECIESX25519, models.EncryptionSchemeECIESSecp256k1} {
+		ethKey, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatalf("GenerateKey() error = %v", err)
+		}
+		keys, _, err := NewEncryptService().GenerateAgentKeys(scheme, ethKey)
+		if err != nil {
+			t.Fatalf("GenerateAgentKeys() error = %v", err)
+		}
+		keysJSON, _ := keys.ToJSON()
+		wallets = append(wallets, &models.Wallet{
+			AgentAddress:    crypto.PubkeyToAddress(ethKey.PublicKey).Hex(),
+			CID:             "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+			CreatorAddress:  "0x0000000000000000000000000000000000000001",
+			AgentPrivateKey: keysJSON,
+		})
+	}
+
+	custodianKeys := make([]*ecdsa.PrivateKey, 3)
+	custodians := make([]Custodian, 3)
+	for i := range custodians {
+		custodianKeys[i], _ = crypto.GenerateKey()
+		custodians[i] = Custodian{Name: string(rune('a' + i)), PublicKey: &custodianKeys[i].PublicKey}
+	}
+	files, err := backupSvc.split(wallets, custodians, 2)
+	if err != nil {
+		t.Fatalf("split() error = %v", err)
+	}
+
+	open := func(i int) []BackupShare {
+		shares, err := backupSvc.OpenShareFile(files[i], custodianKeys[i])
+		if err != nil {
+			t.Fatalf("OpenShareFile(%d) error = %v", i, err)
+		}
+		return shares
+	}
+	// Any two of the three custodians recover every wallet
+	restored, err := backupSvc.Reconstruct([][]BackupShare{open(0), open(2)})
+	if err != nil {
+		t.Fatalf("Reconstruct() error = %v", err)
+	}
+	if len(restored) != len(wallets) {
+		t.Fatalf("Reconstruct() = %d wallets, want %d", len(restored), len(wallets))
+	}
+	for i, w := range restored {
+		want := wallets[i]
+		if w.AgentAddress != want.AgentAddress || w.CID != want.CID || w.CreatorAddress != want.CreatorAddress || w.AgentPrivateKey != want.AgentPrivateKey {
+			t.Errorf("Reconstruct()[%d] = %+v, want %+v", i, w, want)
+		}
+	}
+
+	if _, err := backupSvc.Reconstruct([][]BackupShare{open(1)}); !errors.Is(err, shamir.ErrNotEnoughShares) {
+		t.Errorf("Reconstruct(one custodian) error = %v, want ErrNotEnoughShares", err)
+	}
+	if _, err := backupSvc.OpenShareFile(files[0], custodianKeys[1]); err == nil {
+		t.Error("OpenShareFile() opened a share file with another custodian's key")
+	}
+	stale := *files[0]
+	stale.Version = BackupVersion + 1
+	if _, err := backupSvc.OpenShareFile(&stale, custodianKeys[0]); err == nil {
+		t.Error("OpenShareFile() accepted an unknown backup version")
+	}
+
+	// Shares below the threshold combine to garbage, which must not be restored as keys
+	files, err = backupSvc.split(wallets, custodians, 3)
+	if err != nil {
+		t.Fatalf("split() error = %v", err)
+	}
+	if _, err := backupSvc.Reconstruct([][]BackupShare{open(0), open(1)}); err == nil {
+		t.Error("Reconstruct() recovered keys from fewer shares than the threshold")
+	}
+}
@@ -3,17 +3,27 @@ package services
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"cybernity/pkg/models"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/hkdf"
 )
 
+// eciesInfo is the HKDF info label binding derived keys to this ECIES construction
+const eciesInfo = "cybernity-ecies-v1"
+
 type encryptService struct{}
 
 var (
@@ -145,6 +155,58 @@ func (s *encryptService) DecryptHybrid(ciphertext []byte, priv *rsa.PrivateKey)
 	return plaintext, nil
 }
 
+// EncryptAESGCM encrypts data with a 256-bit content key, returning nonce + ciphertext
+func (s *encryptService) EncryptAESGCM(msg []byte, key []byte) ([]byte, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("content key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, msg, nil), nil
+}
+
+// DecryptAESGCM decrypts nonce + ciphertext produced with a 256-bit content key
+func (s *encryptService) DecryptAESGCM(ciphertext []byte, key []byte) ([]byte, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("content key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt message with AES-GCM: %w", err)
+	}
+
+	return plaintext, nil
+}
+
 // PrivateKeyToBytes private key to bytes
 func (s *encryptService) PrivateKeyToBytes(priv *rsa.PrivateKey) []byte {
 	privBytes := pem.EncodeToMemory(
@@ -201,3 +263,270 @@ func (s *encryptService) BytesToPublicKey(pub []byte) (*rsa.PublicKey, error) {
 	}
 	return key, nil
 }
+
+// GenerateAgentKeys generates the encryption key material for a new agent using the given scheme.
+// It returns the agent keys to persist and the serialized public key knowledge is encrypted to.
+// ecies-secp256k1 encrypts to the agent's Ethereum key, so no separate encryption key is generated.
+func (s *encryptService) GenerateAgentKeys(scheme string, ethPrivateKey *ecdsa.PrivateKey) (*models.AgentKeys, []byte, error) {
+	keys := &models.AgentKeys{
+		EncryptionScheme:     scheme,
+		BlockchainPrivateKey: hex.EncodeToString(crypto.FromECDSA(ethPrivateKey)),
+	}
+
+	switch scheme {
+	case models.EncryptionSchemeRSAOAEP:
+		privateKey, err := s.GenerateKeyPair(2048)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey, err := s.PublicKeyToBytes(&privateKey.PublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys.EncryptionPrivateKey = string(s.PrivateKeyToBytes(privateKey))
+		return keys, publicKey, nil
+	case models.EncryptionSchemeECIESSecp256k1:
+		return keys, crypto.CompressPubkey(&ethPrivateKey.PublicKey), nil
+	case models.EncryptionSchemeECIESX25519:
+		privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys.EncryptionPrivateKey = hex.EncodeToString(privateKey.Bytes())
+		return keys, privateKey.PublicKey().Bytes(), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported encryption scheme: %s", scheme)
+	}
+}
+
+// AgentPublicKey returns the serialized public key that data for the agent is encrypted to
+func (s *encryptService) AgentPublicKey(keys *models.AgentKeys) ([]byte, error) {
+	switch keys.Scheme() {
+	case models.EncryptionSchemeRSAOAEP:
+		privateKey, err := s.BytesToPrivateKey([]byte(keys.EncryptionPrivateKey))
+		if err != nil {
+			return nil, err
+		}
+		return s.PublicKeyToBytes(&privateKey.PublicKey)
+	case models.EncryptionSchemeECIESSecp256k1:
+		privateKey, err := crypto.HexToECDSA(keys.BlockchainPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.CompressPubkey(&privateKey.PublicKey), nil
+	case models.EncryptionSchemeECIESX25519:
+		privateKey, err := s.x25519PrivateKey(keys.EncryptionPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return privateKey.PublicKey().Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported encryption scheme: %s", keys.Scheme())
+	}
+}
+
+// EncryptWithScheme encrypts data to a serialized public key as returned by GenerateAgentKeys
+func (s *encryptService) EncryptWithScheme(scheme string, msg []byte, pub []byte) ([]byte, error) {
+	switch scheme {
+	case models.EncryptionSchemeRSAOAEP:
+		publicKey, err := s.BytesToPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		return s.EncryptHybrid(msg, publicKey)
+	case models.EncryptionSchemeECIESSecp256k1:
+		publicKey, err := crypto.DecompressPubkey(pub)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secp256k1 public key: %w", err)
+		}
+		return s.EncryptECIESSecp256k1(msg, publicKey)
+	case models.EncryptionSchemeECIESX25519:
+		publicKey, err := ecdh.X25519().NewPublicKey(pub)
+		if err != nil {
+			return nil, fmt.Errorf("invalid X25519 public key: %w", err)
+		}
+		return s.EncryptECIESX25519(msg, publicKey)
+	default:
+		return nil, fmt.Errorf("unsupported encryption scheme: %s", scheme)
+	}
+}
+
+// DecryptWithAgentKeys decrypts data with the agent's key material according to its scheme
+func (s *encryptService) DecryptWithAgentKeys(ciphertext []byte, keys *models.AgentKeys) ([]byte, error) {
+	switch keys.Scheme() {
+	case models.EncryptionSchemeRSAOAEP:
+		privateKey, err := s.BytesToPrivateKey([]byte(keys.EncryptionPrivateKey))
+		if err != nil {
+			return nil, err
+		}
+		return s.DecryptHybrid(ciphertext, privateKey)
+	case models.EncryptionSchemeECIESSecp256k1:
+		privateKey, err := crypto.HexToECDSA(keys.BlockchainPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return s.DecryptECIESSecp256k1(ciphertext, privateKey)
+	case models.EncryptionSchemeECIESX25519:
+		privateKey, err := s.x25519PrivateKey(keys.EncryptionPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return s.DecryptECIESX25519(ciphertext, privateKey)
+	default:
+		return nil, fmt.Errorf("unsupported encryption scheme: %s", keys.Scheme())
+	}
+}
+
+// EncryptECIESX25519 encrypts data using ECIES (X25519 + HKDF-SHA256 + AES-GCM)
+func (s *encryptService) EncryptECIESX25519(msg []byte, pub *ecdh.PublicKey) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	return sealECIES(shared, ephemeral.PublicKey().Bytes(), pub.Bytes(), msg)
+}
+
+// DecryptECIESX25519 decrypts data using ECIES (X25519 + HKDF-SHA256 + AES-GCM)
+func (s *encryptService) DecryptECIESX25519(ciphertext []byte, priv *ecdh.PrivateKey) ([]byte, error) {
+	// X25519 public keys are 32 bytes
+	ephemeralSize := 32
+	if len(ciphertext) < ephemeralSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(ciphertext[:ephemeralSize])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	shared, err := priv.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	return openECIES(shared, ciphertext[:ephemeralSize], priv.PublicKey().Bytes(), ciphertext[ephemeralSize:])
+}
+
+// EncryptECIESSecp256k1 encrypts data using ECIES (secp256k1 ECDH + HKDF-SHA256 + AES-GCM)
+func (s *encryptService) EncryptECIESSecp256k1(msg []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	ephemeral, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := secp256k1SharedSecret(ephemeral, pub)
+	if err != nil {
+		return nil, err
+	}
+	return sealECIES(shared, crypto.CompressPubkey(&ephemeral.PublicKey), crypto.CompressPubkey(pub), msg)
+}
+
+// DecryptECIESSecp256k1 decrypts data using ECIES (secp256k1 ECDH + HKDF-SHA256 + AES-GCM)
+func (s *encryptService) DecryptECIESSecp256k1(ciphertext []byte, priv *ecdsa.PrivateKey) ([]byte, error) {
+	// Compressed secp256k1 public keys are 33 bytes
+	ephemeralSize := 33
+	if len(ciphertext) < ephemeralSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	ephemeral, err := crypto.DecompressPubkey(ciphertext[:ephemeralSize])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	shared, err := secp256k1SharedSecret(priv, ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	return openECIES(shared, ciphertext[:ephemeralSize], crypto.CompressPubkey(&priv.PublicKey), ciphertext[ephemeralSize:])
+}
+
+func (s *encryptService) x25519PrivateKey(hexKey string) (*ecdh.PrivateKey, error) {
+	keyBytes, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid X25519 private key: %w", err)
+	}
+	return ecdh.X25519().NewPrivateKey(keyBytes)
+}
+
+// secp256k1SharedSecret computes the x coordinate of the ECDH point on secp256k1
+func secp256k1SharedSecret(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey) ([]byte, error) {
+	curve := crypto.S256()
+	if !curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, errors.New("public key is not on secp256k1 curve")
+	}
+	x, _ := curve.ScalarMult(pub.X, pub.Y, priv.D.Bytes())
+	if x == nil || x.Sign() == 0 {
+		return nil, errors.New("failed to compute shared secret")
+	}
+	return x.FillBytes(make([]byte, 32)), nil
+}
+
+// deriveECIESKey derives an AES-256 key from the ECDH shared secret, bound to both public keys
+func deriveECIESKey(shared, ephemeralPub, recipientPub []byte) ([]byte, error) {
+	info := append([]byte(eciesInfo), recipientPub...)
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, ephemeralPub, info), key); err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
+
+// sealECIES returns ephemeral public key + nonce + AES-GCM ciphertext
+func sealECIES(shared, ephemeralPub, recipientPub, msg []byte) ([]byte, error) {
+	key, err := deriveECIESKey(shared, ephemeralPub, recipientPub)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	var result []byte
+	result = append(result, ephemeralPub...)
+	result = append(result, nonce...)
+	result = gcm.Seal(result, nonce, msg, ephemeralPub)
+
+	return result, nil
+}
+
+// openECIES decrypts nonce + AES-GCM ciphertext produced by sealECIES
+func openECIES(shared, ephemeralPub, recipientPub, data []byte) ([]byte, error) {
+	key, err := deriveECIESKey(shared, ephemeralPub, recipientPub)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], ephemeralPub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt message with AES-GCM: %w", err)
+	}
+
+	return plaintext, nil
+}
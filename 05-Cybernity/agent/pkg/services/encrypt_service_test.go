@@ -0,0 +1,94 @@
+package services
+
+import (
+	"bytes"
+	"cybernity/pkg/models"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestEncryptWithSchemeRoundTrip(t *testing.T) {
+	encryptSvc := NewEncryptService()
+	msg := []byte("知识库 knowledge base content")
+
+	tests := []struct {
+		name   string
+		scheme string
+	}{
+		{name: "rsa-oaep", scheme: models.EncryptionSchemeRSAOAEP},
+		{name: "ecies-secp256k1", scheme: models.EncryptionSchemeECIESSecp256k1},
+		{name: "ecies-x25519", scheme: models.EncryptionSchemeECIESX25519},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ethKey, err := crypto.GenerateKey()
+			if err != nil {
+				t.Fatalf("GenerateKey() error = %v", err)
+			}
+			keys, pub, err := encryptSvc.GenerateAgentKeys(tt.scheme, ethKey)
+			if err != nil {
+				t.Fatalf("GenerateAgentKeys() error = %v", err)
+			}
+
+			ciphertext, err := encryptSvc.EncryptWithScheme(tt.scheme, msg, pub)
+			if err != nil {
+				t.Fatalf("EncryptWithScheme() error = %v", err)
+			}
+
+			// Keys must survive the JSON round trip through the wallets table
+			keysJSON, err := keys.ToJSON()
+			if err != nil {
+				t.Fatalf("ToJSON() error = %v", err)
+			}
+			stored, err := models.AgentKeysFromJSON(keysJSON)
+			if err != nil {
+				t.Fatalf("AgentKeysFromJSON() error = %v", err)
+			}
+
+			plaintext, err := encryptSvc.DecryptWithAgentKeys(ciphertext, stored)
+			if err != nil {
+				t.Fatalf("DecryptWithAgentKeys() error = %v", err)
+			}
+			if !bytes.Equal(plaintext, msg) {
+				t.Errorf("DecryptWithAgentKeys() = %q, want %q", plaintext, msg)
+			}
+
+			storedPub, err := encryptSvc.AgentPublicKey(stored)
+			if err != nil {
+				t.Fatalf("AgentPublicKey() error = %v", err)
+			}
+			if !bytes.Equal(storedPub, pub) {
+				t.Errorf("AgentPublicKey() does not match generated public key")
+			}
+
+			ciphertext[len(ciphertext)-1] ^= 0xff
+			if _, err := encryptSvc.DecryptWithAgentKeys(ciphertext, stored); err == nil {
+				t.Error("DecryptWithAgentKeys() accepted tampered ciphertext")
+			}
+		})
+	}
+}
+
+func TestDecryptLegacyRSAKeys(t *testing.T) {
+	encryptSvc := NewEncryptService()
+	privateKey, err := encryptSvc.GenerateKeyPair(2048)
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	ciphertext, err := encryptSvc.EncryptHybrid([]byte("legacy"), &privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("EncryptHybrid() error = %v", err)
+	}
+
+	// Wallets created before encryption schemes existed have no scheme set
+	keys := &models.AgentKeys{EncryptionPrivateKey: string(encryptSvc.PrivateKeyToBytes(privateKey))}
+	plaintext, err := encryptSvc.DecryptWithAgentKeys(ciphertext, keys)
+	if err != nil {
+		t.Fatalf("DecryptWithAgentKeys() error = %v", err)
+	}
+	if string(plaintext) != "legacy" {
+		t.Errorf("DecryptWithAgentKeys() = %q, want %q", plaintext, "legacy")
+	}
+}
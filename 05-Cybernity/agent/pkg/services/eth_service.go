@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"cybernity/pkg/core/eth"
 	"math/big"
 	"sync"
@@ -30,3 +31,11 @@ func NewEthService(cfg eth.Config) *ethService {
 func (s *ethService) SubmitAnswer(ctx context.Context, agentPrivateKey string, questionId *big.Int, answerCID string) (common.Hash, error) {
 	return s.client.SubmitAnswer(ctx, agentPrivateKey, questionId, answerCID)
 }
+
+func (s *ethService) SenderPublicKey(ctx context.Context, txHash common.Hash) (*ecdsa.PublicKey, common.Address, error) {
+	return s.client.SenderPublicKey(ctx, txHash)
+}
+
+func (s *ethService) GetAgentOperator(ctx context.Context, cid string) (common.Address, error) {
+	return s.client.GetAgentOperator(ctx, cid)
+}
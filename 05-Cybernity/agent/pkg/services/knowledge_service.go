@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"cybernity/pkg/core/authz"
+	"cybernity/pkg/core/knowledge"
+	"cybernity/pkg/core/logger"
+	"cybernity/pkg/core/storage"
+	"cybernity/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type knowledgeService struct{}
+
+var (
+	KnowledgeService     *knowledgeService
+	knowledgeServiceOnce sync.Once
+)
+
+func NewKnowledgeService() *knowledgeService {
+	knowledgeServiceOnce.Do(func() {
+		KnowledgeService = &knowledgeService{}
+	})
+	return KnowledgeService
+}
+
+type PublishKnowledgeSvcRequest struct {
+	Scheme         string // agent encryption scheme
+	PublicKey      []byte // agent public key
+	Name           string // pin name of a multi-file knowledge base
+	AgentCID       string // empty for a new agent
+	CreatorAddress string
+	Files          []knowledge.Document
+}
+
+type PublishedKnowledge struct {
+	CID    string
+	Format string
+	Files  int
+	Size   int64 // encrypted size
+}
+
+// Publish encrypts the documents to the agent and pins them,
+// a single document as is and several as a directory with a manifest
+func (s *knowledgeService) Publish(ctx context.Context, req *PublishKnowledgeSvcRequest) (*PublishedKnowledge, error) {
+	if err := knowledge.CheckLimits(req.Files); err != nil {
+		return nil, err
+	}
+
+	encryptSvc := NewEncryptService()
+	pinReq := &PinSvcRequest{
+		Purpose:        models.PinPurposeKnowledge,
+		AgentCID:       req.AgentCID,
+		CreatorAddress: req.CreatorAddress,
+	}
+	published := &PublishedKnowledge{Format: models.KnowledgeFormatFile, Files: len(req.Files)}
+
+	if len(req.Files) == 1 {
+		encrypted, err := encryptSvc.EncryptWithScheme(req.Scheme, req.Files[0].Content, req.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt file: %w", err)
+		}
+		pinReq.Name = req.Files[0].Name
+		pinReq.Content = encrypted
+		published.Size = int64(len(encrypted))
+	} else {
+		published.Format = models.KnowledgeFormatDirectory
+		pinReq.Name = req.Name
+		manifest := knowledge.Manifest{Version: knowledge.ManifestVersion, CreatedAt: time.Now().UTC()}
+		for i, f := range req.Files {
+			encrypted, err := encryptSvc.EncryptWithScheme(req.Scheme, f.Content, req.PublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encrypt file %s: %w", f.Name, err)
+			}
+			entry := knowledge.ManifestFile{
+				Name:          f.Name,
+				Path:          knowledge.FilePath(i, f.Name),
+				Size:          int64(len(f.Content)),
+				EncryptedSize: int64(len(encrypted)),
+				ContentType:   f.ContentType,
+			}
+			manifest.Files = append(manifest.Files, entry)
+			pinReq.Files = append(pinReq.Files, storage.File{Name: entry.Path, Content: encrypted})
+			published.Size += entry.EncryptedSize
+		}
+		manifestJSON, err := json.Marshal(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+		}
+		pinReq.Files = append(pinReq.Files, storage.File{Name: knowledge.ManifestName, Content: manifestJSON})
+	}
+
+	cid, err := NewPinService().Pin(ctx, pinReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload to IPFS: %w", err)
+	}
+	published.CID = cid
+	return published, nil
+}
+
+type UpdateKnowledgeSvcRequest struct {
+	AgentCID    string
+	BaseVersion int    // version the change applies to
+	Change      string // models.KnowledgeChangeAppend or models.KnowledgeChangeReplace
+	Files       []knowledge.Document
+	Signature   authz.Signature // the creator's, see updateRequest
+}
+
+// Update publishes a new knowledge version for an agent. Appended documents replace existing ones with the same name.
+// The previous version stays pinned, marked as superseded, and listed in the history.
+func (s *knowledgeService) Update(ctx context.Context, req *UpdateKnowledgeSvcRequest) (*models.KnowledgeVersions, error) {
+	if req.Change != models.KnowledgeChangeAppend && req.Change != models.KnowledgeChangeReplace {
+		return nil, fmt.Errorf("unknown change %q, must be append or replace", req.Change)
+	}
+
+	agentSvc := NewAgentService()
+	agent, err := agentSvc.GetAgent(ctx, req.AgentCID)
+	if err != nil {
+		return nil, fmt.Errorf("agent not found: %w", err)
+	}
+	if err := updateRequest(req).Verify(agent.CreatorAddress, time.Now()); err != nil {
+		return nil, err
+	}
+	// The signature is only good for the version the creator saw
+	if req.BaseVersion != agent.CurrentVersion() {
+		return nil, models.ErrVersionConflict
+	}
+	// The server can't re-encrypt content whose key only the creator holds
+	if agent.EncryptionMode != models.EncryptionModeServer {
+		return nil, errors.New("knowledge encrypted by the creator can't be updated by the server")
+	}
+
+	keys, err := NewWalletService().GetKeysForAgent(ctx, agent.AgentAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent keys: %w", err)
+	}
+	publicKey, err := NewEncryptService().AgentPublicKey(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent public key: %w", err)
+	}
+
+	files := req.Files
+	if req.Change == models.KnowledgeChangeAppend {
+		existing, err := agentSvc.GetDocuments(ctx, agent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read current knowledge: %w", err)
+		}
+		files = mergeDocuments(existing, req.Files)
+	}
+
+	if err := s.ensureHistory(ctx, agent); err != nil {
+		return nil, err
+	}
+
+	published, err := s.Publish(ctx, &PublishKnowledgeSvcRequest{
+		Scheme:         keys.Scheme(),
+		PublicKey:      publicKey,
+		Name:           agent.Name,
+		AgentCID:       agent.CID,
+		CreatorAddress: agent.CreatorAddress,
+		Files:          files,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	previous := req.BaseVersion
+	version := &models.KnowledgeVersions{
+		AgentCID:        agent.CID,
+		Version:         previous + 1,
+		CID:             published.CID,
+		KnowledgeFormat: published.Format,
+		Files:           published.Files,
+		Size:            published.Size,
+		Change:          req.Change,
+	}
+	if err := version.Publish(ctx, previous); err != nil {
+		// Nothing references the new content, don't keep paying for it
+		if unpinErr := NewPinService().Unpin(ctx, published.CID); unpinErr != nil {
+			err = errors.Join(err, unpinErr)
+		}
+		return nil, err
+	}
+
+	// The new version is live, failing to mark the old one only delays its unpinning
+	if err := NewPinService().Supersede(ctx, agent.ContentCID()); err != nil {
+		logger.Errorf(ctx, "Failed to mark knowledge %s superseded: %v", agent.ContentCID(), err)
+	}
+	return version, nil
+}
+
+// updateRequest is what the creator signs to update the knowledge: the change, the version it applies to
+// and the hash of every uploaded file in upload order
+func updateRequest(req *UpdateKnowledgeSvcRequest) *authz.Request {
+	auth := &authz.Request{
+		Action:  authz.ActionUpdateKnowledge,
+		Subject: req.AgentCID,
+		Fields: []authz.Field{
+			{Name: "change", Value: req.Change},
+			{Name: "base_version", Value: strconv.Itoa(req.BaseVersion)},
+		},
+		Signature: req.Signature,
+	}
+	for _, f := range req.Files {
+		auth.Fields = append(auth.Fields, authz.Field{Name: "file", Value: authz.Hash(f.Content)})
+	}
+	return auth
+}
+
+func (s *knowledgeService) ListVersions(ctx context.Context, agentCID string) ([]*models.KnowledgeVersions, error) {
+	return (&models.KnowledgeVersions{}).ListByAgent(ctx, agentCID)
+}
+
+func (s *knowledgeService) GetVersion(ctx context.Context, agentCID string, version int) (*models.KnowledgeVersions, error) {
+	return (&models.KnowledgeVersions{}).Get(ctx, agentCID, version)
+}
+
+// ensureHistory records the initial version of agents created before versioning
+func (s *knowledgeService) ensureHistory(ctx context.Context, agent *models.Agents) error {
+	versions, err := s.ListVersions(ctx, agent.CID)
+	if err != nil {
+		return err
+	}
+	if len(versions) > 0 {
+		return nil
+	}
+	return (&models.KnowledgeVersions{
+		AgentCID:        agent.CID,
+		Version:         1,
+		CID:             agent.CID,
+		KnowledgeFormat: agent.KnowledgeFormat,
+		Change:          models.KnowledgeChangeCreate,
+	}).Create(ctx)
+}
+
+// mergeDocuments appends docs to existing, replacing existing documents with the same name
+func mergeDocuments(existing, docs []knowledge.Document) []knowledge.Document {
+	replaced := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		replaced[doc.Name] = true
+	}
+
+	merged := make([]knowledge.Document, 0, len(existing)+len(docs))
+	for _, doc := range existing {
+		if !replaced[doc.Name] {
+			merged = append(merged, doc)
+		}
+	}
+	return append(merged, docs...)
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"cybernity/pkg/core/authz"
+	"cybernity/pkg/core/knowledge"
+	"cybernity/pkg/models"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestMergeDocuments(t *testing.T) {
+	existing := []knowledge.Document{
+		{Name: "a.md", Content: []byte("old a")},
+		{Name: "b.md", Content: []byte("b")},
+	}
+	merged := mergeDocuments(existing, []knowledge.Document{
+		{Name: "a.md", Content: []byte("new a")},
+		{Name: "c.md", Content: []byte("c")},
+	})
+
+	want := []string{"b.md:b", "a.md:new a", "c.md:c"}
+	if len(merged) != len(want) {
+		t.Fatalf("mergeDocuments() = %d documents, want %d", len(merged), len(want))
+	}
+	for i, doc := range merged {
+		if got := doc.Name + ":" + string(doc.Content); got != want[i] {
+			t.Errorf("mergeDocuments()[%d] = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestUpdateRequest(t *testing.T) {
+	creator, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	address := crypto.PubkeyToAddress(creator.PublicKey).Hex()
+	now := time.Now()
+
+	// As the creator's wallet signs it
+	signed := func() *UpdateKnowledgeSvcRequest {
+		req := &UpdateKnowledgeSvcRequest{
+			AgentCID:    "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+			BaseVersion: 3,
+			Change:      models.KnowledgeChangeAppend,
+			Files:       []knowledge.Document{{Name: "a.md", Content: []byte("a")}, {Name: "b.md", Content: []byte("b")}},
+			Signature:   authz.Signature{Timestamp: now.Unix()},
+		}
+		auth := updateRequest(req)
+		if err := auth.Sign(creator); err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		req.Signature = auth.Signature
+		return req
+	}
+
+	if err := updateRequest(signed()).Verify(address, now); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		change func(*UpdateKnowledgeSvcRequest)
+	}{
+		{"other agent", func(r *UpdateKnowledgeSvcRequest) {
+			r.AgentCID = "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+		}},
+		{"other base version", func(r *UpdateKnowledgeSvcRequest) { r.BaseVersion = 4 }},
+		{"replace instead of append", func(r *UpdateKnowledgeSvcRequest) { r.Change = models.KnowledgeChangeReplace }},
+		{"other file", func(r *UpdateKnowledgeSvcRequest) { r.Files[1].Content = []byte("forged") }},
+		{"extra file", func(r *UpdateKnowledgeSvcRequest) { r.Files = append(r.Files, knowledge.Document{Name: "c.md"}) }},
+		{"reordered files", func(r *UpdateKnowledgeSvcRequest) { r.Files[0], r.Files[1] = r.Files[1], r.Files[0] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := signed()
+			tt.change(req)
+			if err := updateRequest(req).Verify(address, now); !errors.Is(err, authz.ErrUnauthorized) {
+				t.Errorf("Verify() error = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
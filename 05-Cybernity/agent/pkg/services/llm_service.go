@@ -4,7 +4,12 @@ import (
 	"context"
 	"cybernity/pkg/core/llm"
 	"cybernity/pkg/core/logger"
+	"cybernity/pkg/core/prompt"
+	"cybernity/pkg/core/rag"
+	"cybernity/pkg/core/tool"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 )
 
@@ -22,41 +27,301 @@ func NewLlmService() *llmService {
 	return LLMService
 }
 
-func (s *llmService) GetAnswer(ctx context.Context, name, desc, question, knowledge string) (string, error) {
-	client, err := llm.GetManager().GetClient("default")
-	if err != nil {
-		return "", fmt.Errorf("failed to get LLM client: %w", err)
+// Answer is an LLM answer along with the model that produced it
+type Answer struct {
+	Content string
+	Model   string
+	Usage   llm.Usage
+	Trace   []ToolStep // tools called on the way to the answer
+	Repairs int        // replies sent back for not matching the response schema
+}
+
+type GetAnswerSvcRequest struct {
+	Name        string
+	Description string
+	Question    string
+	Chunks      []rag.Result    // retrieved for the question, most relevant first
+	Persona     *prompt.Persona // nil for the default persona
+	Tools       tool.Set        // the agent may call, none to answer at once
+}
+
+// AnswerRoute is the LLM route questions are answered on
+const AnswerRoute = "answer"
+
+// GetAnswer answers the question from the chunks of the agent's knowledge retrieved for it, in the agent's persona.
+// Clients of the answer route, or the route the agent is pinned to, are tried in turn until one answers.
+func (s *llmService) GetAnswer(ctx context.Context, req *GetAnswerSvcRequest) (*Answer, error) {
+	router := llm.GetRouter()
+	if router == nil {
+		return nil, fmt.Errorf("LLM router is not initialized")
+	}
+	persona := req.Persona
+	if persona == nil {
+		persona = prompt.Default()
 	}
 
 	// 构建系统提示词
-	systemPrompt := `你是%s,你的描述是%s,现在你在进行一次知识付费，你的知识库是%s,请根据知识库回答问题。`
-
-	chatRequest := llm.ChatCompletionRequest{
-		Messages: []llm.Message{
+	vars := prompt.Vars{Name: req.Name, Description: req.Description, Question: req.Question}
+	if _, err := persona.SystemPrompt(vars); err != nil {
+		return nil, err
+	}
+	responseFormat, schema, err := persona.ResponseFormat()
+	if err != nil {
+		return nil, err
+	}
+	buildMessages := func(chunks []rag.Result) ([]llm.Message, error) {
+		vars.Knowledge = formatChunks(chunks)
+		// Only the knowledge changes, the template rendered above but may render too long with it
+		systemPrompt, err := persona.SystemPrompt(vars)
+		if err != nil {
+			return nil, err
+		}
+		return []llm.Message{
 			{
 				Role:    "system",
-				Content: fmt.Sprintf(systemPrompt, name, desc, knowledge),
+				Content: systemPrompt,
 			},
 			{
 				Role:    "user",
-				Content: question,
+				Content: req.Question,
 			},
+		}, nil
+	}
+
+	// An agent pinned to a route is only answered there, never by the shared clients
+	route := AnswerRoute
+	if persona.Route != "" {
+		if !router.Has(persona.Route) {
+			return nil, fmt.Errorf("LLM route %s is not configured", persona.Route)
+		}
+		route = persona.Route
+	}
+
+	var answer *Answer
+	err = router.Do(ctx, route, func(ctx context.Context, key string, client llm.Client) error {
+		// The model the creator picked is only sent to clients serving it, the others answer with their own
+		model := client.GetConfig().ModelFor(persona.Model)
+		// Keep the most relevant knowledge that fits the model's context window along with the answer
+		budget := client.GetConfig().Budget(model)
+		condense := func(c rag.Result, tokens int) (string, error) {
+			return condenseChunk(ctx, client, model, req.Question, c, budget, tokens)
+		}
+		chunks, err := fitChunks(budget, buildMessages, req.Chunks, condense)
+		if err != nil {
+			return err
+		}
+		messages, err := buildMessages(chunks)
+		if err != nil {
+			return err
+		}
+		maxTokens, err := budget.MaxTokens(budget.CountMessages(messages))
+		if err != nil {
+			return fmt.Errorf("question is too long: %w", err)
+		}
+
+		chatRequest := llm.ChatCompletionRequest{
+			Model:          model,
+			Messages:       messages,
+			MaxTokens:      maxTokens,
+			Temperature:    llm.Temperature(persona.GetTemperature()),
+			ResponseFormat: responseFormat,
+		}
+		logger.Debug(ctx, "GetAnswer", "client", key, "chatRequest", chatRequest)
+		// 发送请求
+		var trace []ToolStep
+		var response *llm.ChatCompletionResponse
+		if len(req.Tools) > 0 {
+			response, err = runTools(ctx, client, chatRequest, req.Tools, budget, tool.GetConfig(), func(step ToolStep) {
+				trace = append(trace, step)
+				logger.Info(ctx, "GetAnswer tool call", "client", key, "agent", req.Name, "iteration", step.Iteration,
+					"tool", step.Tool, "arguments", step.Arguments, "error", step.Error, "duration", step.Duration)
+				logger.Debug(ctx, "GetAnswer tool result", "client", key, "tool", step.Tool, "result", step.Result)
+			})
+		} else {
+			response, err = client.ChatCompletion(ctx, chatRequest)
+			if err != nil {
+				err = fmt.Errorf("chat completion failed: %w", err)
+			}
+		}
+		if err != nil {
+			logger.Warn(ctx, "GetAnswer", "client", key, "error", err)
+			return err
+		}
+
+		if len(response.Choices) == 0 {
+			return fmt.Errorf("no response from AI")
+		}
+		var repairs int
+		if schema != nil {
+			response, err = repairReply(ctx, client, chatRequest, response, schema, budget, *prompt.GetConfig().MaxRepairs, func(err error) {
+				repairs++
+				logger.Info(ctx, "GetAnswer repair", "client", key, "agent", req.Name, "attempt", repairs, "error", err)
+			})
+			if err != nil {
+				logger.Warn(ctx, "GetAnswer", "client", key, "error", err)
+				return err
+			}
+		}
+
+		// 处理返回内容，保留原始内容以便调试
+		logger.Info(ctx, "GetAnswer", "client", key, "Content", response.Choices[0].Message.Content)
+		// An empty answer can't be published, let the next client answer instead
+		if strings.TrimSpace(response.Choices[0].Message.Content) == "" {
+			return fmt.Errorf("empty answer from AI")
+		}
+
+		if response.Model != "" {
+			model = response.Model
+		} else if model == "" {
+			model = client.GetConfig().Model
+		}
+		answer = &Answer{
+			Content: response.Choices[0].Message.Content,
+			Model:   model,
+			Usage:   response.Usage,
+			Trace:   trace,
+			Repairs: repairs,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// repairReply validates the reply against the schema, sending a reply that doesn't match back to the model
+// with the error, at most maxRepairs times. The reply content is left as the JSON value alone.
+// It fails with llm.ErrInvalidOutput when no reply matches.
+func repairReply(ctx context.Context, client llm.Client, request llm.ChatCompletionRequest, response *llm.ChatCompletionResponse,
+	schema *llm.Schema, budget *llm.Budget, maxRepairs int, onRepair func(error)) (*llm.ChatCompletionResponse, error) {
+	// Repairs only fix the format, the tools were called already
+	request.Tools, request.ToolChoice = nil, ""
+	messages := request.Messages
+	usage := response.Usage
+	for attempt := 0; ; attempt++ {
+		reply := response.Choices[0].Message.Content
+		content, err := schema.Validate(reply)
+		if err == nil {
+			response.Choices[0].Message.Content = content
+			response.Usage = usage
+			return response, nil
+		}
+		if attempt >= maxRepairs {
+			return nil, fmt.Errorf("no valid reply after %d repairs: %w", attempt, err)
+		}
+		onRepair(err)
+
+		// Only the last invalid reply is sent back, earlier ones would take room for nothing
+		request.Messages = append(slices.Clip(messages),
+			llm.Message{Role: "assistant", Content: reply},
+			llm.Message{Role: "user", Content: fmt.Sprintf("你的回复不符合要求的 JSON Schema：%v。请只回复符合 Schema 的 JSON，不要包含其他内容。", err)},
+		)
+		maxTokens, err := budget.MaxTokens(budget.CountMessages(request.Messages))
+		if err != nil {
+			return nil, fmt.Errorf("reply leaves no room for a repair: %w", err)
+		}
+		request.MaxTokens = maxTokens
+
+		response, err = client.ChatCompletion(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("chat completion failed: %w", err)
+		}
+		if len(response.Choices) == 0 {
+			return nil, fmt.Errorf("no response from AI")
+		}
+		usage.PromptTokens += response.Usage.PromptTokens
+		usage.CompletionTokens += response.Usage.CompletionTokens
+		usage.TotalTokens += response.Usage.TotalTokens
+	}
+}
+
+// minChunkTokens is the least of a chunk worth keeping when it has to be cut to fit
+const minChunkTokens = 50
+
+// fitChunks keeps the most relevant chunks that fit the budget. The last one that only partly fits is
+// condensed to the room left, or cut when condense is nil or fails.
+// It fails with llm.ErrContextOverflow when the prompt leaves no room for an answer even without knowledge.
+func fitChunks(budget *llm.Budget, buildMessages func([]rag.Result) ([]llm.Message, error), chunks []rag.Result,
+	condense func(c rag.Result, tokens int) (string, error)) ([]rag.Result, error) {
+	messages, err := buildMessages(nil)
+	if err != nil {
+		return nil, err
+	}
+	base := budget.CountMessages(messages)
+	if _, err := budget.MaxTokens(base); err != nil {
+		return nil, fmt.Errorf("question is too long: %w", err)
+	}
+
+	room := budget.PromptTokens() - base
+	var fitted []rag.Result
+	for _, c := range chunks {
+		cost := budget.Count(formatChunk(c)) + 1 // blank line between chunks
+		if cost > room {
+			header := cost - budget.Count(c.Text)
+			if tokens := room - header; tokens >= minChunkTokens {
+				var condensed string
+				if condense != nil {
+					if text, err := condense(c, tokens); err == nil && budget.Count(text) <= tokens {
+						condensed = text
+					}
+				}
+				if condensed == "" {
+					condensed = budget.Truncate(c.Text, tokens)
+				}
+				c.Text = condensed
+				fitted = append(fitted, c)
+			}
+			break
+		}
+		fitted = append(fitted, c)
+		room -= cost
+	}
+	return fitted, nil
+}
+
+// condenseChunk asks the model to summarize a chunk in at most tokens, keeping what answers the question
+func condenseChunk(ctx context.Context, client llm.Client, model, question string, c rag.Result,
+	budget *llm.Budget, tokens int) (string, error) {
+	messages := []llm.Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf("请将以下知识库片段压缩为不超过 %d 个 token 的摘要，保留与问题相关的事实，只回复摘要。问题：%s", tokens, question),
 		},
-		Temperature: 0.7,
+		{
+			Role:    "user",
+			Content: c.Text,
+		},
+	}
+	if _, err := budget.MaxTokens(budget.CountMessages(messages)); err != nil {
+		return "", err
 	}
-	logger.Debug(ctx, "GetAnswer", "chatRequest", chatRequest)
-	// 发送请求
-	response, err := client.ChatCompletion(ctx, chatRequest)
+	response, err := client.ChatCompletion(ctx, llm.ChatCompletionRequest{
+		Model:     model,
+		Messages:  messages,
+		MaxTokens: tokens,
+	})
 	if err != nil {
 		return "", fmt.Errorf("chat completion failed: %w", err)
 	}
-
 	if len(response.Choices) == 0 {
 		return "", fmt.Errorf("no response from AI")
 	}
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
 
-	// 处理返回内容，保留原始内容以便调试
-	logger.Info(ctx, "GetAnswer", "Content", response.Choices[0].Message.Content)
+// formatChunks lists the retrieved chunks, each under its source
+func formatChunks(chunks []rag.Result) string {
+	if len(chunks) == 0 {
+		return "（知识库中没有与问题相关的内容）"
+	}
+	parts := make([]string, len(chunks))
+	for i, c := range chunks {
+		parts[i] = formatChunk(c)
+	}
+	return strings.Join(parts, "\n\n")
+}
 
-	return response.Choices[0].Message.Content, nil
+func formatChunk(c rag.Result) string {
+	return "[" + c.Source() + "]\n" + c.Text
 }
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"cybernity/pkg/core/llm"
+	"cybernity/pkg/core/rag"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFitChunks(t *testing.T) {
+	budget := &llm.Budget{
+		ModelLimits:     llm.ModelLimits{ContextLength: 700, MaxOutputTokens: 200},
+		MinOutputTokens: 100,
+		Tokenizer:       llm.EstimateTokenizer{},
+	}
+	build := func(question string) func([]rag.Result) ([]llm.Message, error) {
+		return func(chunks []rag.Result) ([]llm.Message, error) {
+			return []llm.Message{{Role: "system", Content: formatChunks(chunks)}, {Role: "user", Content: question}}, nil
+		}
+	}
+	chunk := func(doc string) rag.Result {
+		return rag.Result{Chunk: rag.Chunk{Document: doc, Text: strings.Repeat("knowledge ", 80)}}
+	}
+	chunks := []rag.Result{chunk("best.md"), chunk("second.md"), chunk("third.md")}
+
+	fitted, err := fitChunks(budget, build("short question?"), chunks, nil)
+	if err != nil {
+		t.Fatalf("fitChunks() error = %v", err)
+	}
+	if len(fitted) != 3 || fitted[0].Document != "best.md" {
+		t.Fatalf("fitChunks() kept %d chunks, want the 2 whole ones and a cut one", len(fitted))
+	}
+	if len(fitted[2].Text) >= len(chunks[2].Text) {
+		t.Error("fitChunks() did not cut the last chunk")
+	}
+	messages, _ := build("short question?")(fitted)
+	if n := budget.CountMessages(messages); n > budget.PromptTokens() {
+		t.Errorf("prompt has %d tokens, want at most %d", n, budget.PromptTokens())
+	}
+
+	// The chunk that only partly fits is condensed rather than cut, unless the summary doesn't fit either
+	condense := func(summary string) func(rag.Result, int) (string, error) {
+		return func(c rag.Result, tokens int) (string, error) {
+			if c.Document != "third.md" {
+				t.Errorf("condensed %s, want third.md", c.Document)
+			}
+			return summary, nil
+		}
+	}
+	fitted, err = fitChunks(budget, build("short question?"), chunks, condense("the gist"))
+	if err != nil || len(fitted) != 3 || fitted[2].Text != "the gist" {
+		t.Errorf("fitChunks() with condense = %d chunks, %v, want the last one condensed", len(fitted), err)
+	}
+	fitted, err = fitChunks(budget, build("short question?"), chunks, condense(strings.Repeat("gist ", 500)))
+	if err != nil || len(fitted) != 3 || !strings.HasPrefix(chunks[2].Text, fitted[2].Text) {
+		t.Errorf("fitChunks() with a long summary = %d chunks, %v, want the last one cut", len(fitted), err)
+	}
+
+	// A long question leaves less room for knowledge
+	fitted, err = fitChunks(budget, build(strings.Repeat("why ", 470)), chunks, nil)
+	if err != nil || len(fitted) != 0 {
+		t.Errorf("fitChunks() = %d chunks, %v, want none", len(fitted), err)
+	}
+
+	// A question that leaves no room for an answer is refused
+	if _, err := fitChunks(budget, build(strings.Repeat("why ", 650)), chunks, nil); !errors.Is(err, llm.ErrContextOverflow) {
+		t.Errorf("fitChunks() error = %v, want ErrContextOverflow", err)
+	}
+}
+
+func TestRepairReply(t *testing.T) {
+	schema := llm.MustParseSchema(`{"type": "object", "properties": {"answer": {"type": "string"}}, "required": ["answer"]}`)
+	request := llm.ChatCompletionRequest{
+		Messages:   []llm.Message{{Role: "user", Content: "Hi"}},
+		Tools:      []llm.Tool{llm.NewFunctionTool("calculator", "", nil)},
+		ToolChoice: llm.ToolChoiceAuto,
+	}
+	first := &llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: "Hello!"}}},
+		Usage:   llm.Usage{TotalTokens: 11},
+	}
+
+	client := &scriptedClient{responses: []llm.Message{
+		{Role: "assistant", Content: `{"reply": "Hello!"}`},
+		{Role: "assistant", Content: "```json\n{\"answer\": \"Hello!\"}\n```"},
+	}}
+	var repairs []error
+	response, err := repairReply(context.Background(), client, request, first, schema, testBudget(), 2,
+		func(err error) { repairs = append(repairs, err) })
+	if err != nil {
+		t.Fatalf("repairReply() error = %v", err)
+	}
+	if got := response.Choices[0].Message.Content; got != `{"answer": "Hello!"}` || response.Usage.TotalTokens != 33 {
+		t.Errorf("repairReply() = %q, usage %d, want the JSON alone with the usage of every call", got, response.Usage.TotalTokens)
+	}
+	if len(repairs) != 2 || len(client.requests) != 2 {
+		t.Fatalf("repairs = %v, requests = %d, want 2 of each", repairs, len(client.requests))
+	}
+	// Each repair sends the last invalid reply back along with the error, without the tools
+	second := client.requests[1]
+	if len(second.Messages) != 3 || second.Messages[1].Content != `{"reply": "Hello!"}` ||
+		!strings.Contains(second.Messages[2].Content, "$.answer is required") || second.Tools != nil || second.ToolChoice != "" {
+		t.Errorf("repair request = %+v", second)
+	}
+
+	client = &scriptedClient{responses: []llm.Message{{Role: "assistant", Content: "Still not JSON"}}}
+	first.Choices[0].Message.Content = "Hello!"
+	if _, err := repairReply(context.Background(), client, request, first, schema, testBudget(), 1, func(error) {}); !errors.Is(err, llm.ErrInvalidOutput) || len(client.requests) != 1 {
+		t.Errorf("repairReply() error = %v after %d repairs, want ErrInvalidOutput after 1", err, len(client.requests))
+	}
+}
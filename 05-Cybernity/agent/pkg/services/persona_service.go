@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"cybernity/pkg/core/authz"
+	"cybernity/pkg/core/prompt"
+	"cybernity/pkg/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type personaService struct{}
+
+var (
+	PersonaService     *personaService
+	personaServiceOnce sync.Once
+)
+
+func NewPersonaService() *personaService {
+	personaServiceOnce.Do(func() {
+		PersonaService = &personaService{}
+	})
+	return PersonaService
+}
+
+// Get returns the persona of an agent and whether its creator configured it, the default persona otherwise
+func (s *personaService) Get(ctx context.Context, agentCID string) (*prompt.Persona, bool, error) {
+	record, err := (&models.AgentPersonas{}).Get(ctx, agentCID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return prompt.Default(), false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &prompt.Persona{
+		Template:       record.Template,
+		Language:       record.Language,
+		Tone:           record.Tone,
+		RefusalPolicy:  record.RefusalPolicy,
+		Temperature:    record.Temperature,
+		Model:          record.LLMModel,
+		Route:          record.Route,
+		Tools:          splitList(record.Tools),
+		ResponseSchema: record.ResponseSchema,
+	}, true, nil
+}
+
+type SavePersonaSvcRequest struct {
+	AgentCID  string
+	Persona   prompt.Persona
+	Signature authz.Signature // the creator's, see personaRequest
+}
+
+// Save validates the persona and makes it the agent's, only its creator can configure an agent
+func (s *personaService) Save(ctx context.Context, req *SavePersonaSvcRequest) error {
+	agent, err := NewAgentService().GetAgent(ctx, req.AgentCID)
+	if err != nil {
+		return fmt.Errorf("agent not found: %w", err)
+	}
+	if err := personaRequest(req).Verify(agent.CreatorAddress, time.Now()); err != nil {
+		return err
+	}
+
+	persona := req.Persona
+	if persona.Tone == "" {
+		persona.Tone = prompt.ToneNeutral
+	}
+	if persona.RefusalPolicy == "" {
+		persona.RefusalPolicy = prompt.RefusalStrict
+	}
+	if err := persona.Validate(); err != nil {
+		return fmt.Errorf("invalid persona: %w", err)
+	}
+
+	record := &models.AgentPersonas{
+		AgentCID:       agent.CID,
+		Template:       persona.Template,
+		Language:       persona.Language,
+		Tone:           persona.Tone,
+		RefusalPolicy:  persona.RefusalPolicy,
+		Temperature:    persona.Temperature,
+		LLMModel:       persona.Model,
+		Route:          persona.Route,
+		Tools:          strings.Join(persona.Tools, ","),
+		ResponseSchema: persona.ResponseSchema,
+	}
+	return record.Save(ctx)
+}
+
+// personaRequest is what the creator signs to configure an agent: every field of the persona as sent,
+// with the template and response schema hashed and the tools comma separated
+func personaRequest(req *SavePersonaSvcRequest) *authz.Request {
+	persona := req.Persona
+	var temperature string
+	if persona.Temperature != nil {
+		temperature = strconv.FormatFloat(float64(*persona.Temperature), 'f', -1, 32)
+	}
+	return &authz.Request{
+		Action:  authz.ActionSavePersona,
+		Subject: req.AgentCID,
+		Fields: []authz.Field{
+			{Name: "template", Value: authz.Hash([]byte(persona.Template))},
+			{Name: "language", Value: persona.Language},
+			{Name: "tone", Value: persona.Tone},
+			{Name: "refusal_policy", Value: persona.RefusalPolicy},
+			{Name: "temperature", Value: temperature},
+			{Name: "model", Value: persona.Model},
+			{Name: "route", Value: persona.Route},
+			{Name: "tools", Value: strings.Join(persona.Tools, ",")},
+			{Name: "response_schema", Value: authz.Hash([]byte(persona.ResponseSchema))},
+		},
+		Signature: req.Signature,
+	}
+}
+
+// splitList splits a comma separated list, empty for an empty string
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"cybernity/pkg/core/authz"
+	"cybernity/pkg/core/prompt"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestPersonaRequest(t *testing.T) {
+	creator, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	address := crypto.PubkeyToAddress(creator.PublicKey).Hex()
+	now := time.Now()
+
+	// As the creator's wallet signs it
+	signed := func() *SavePersonaSvcRequest {
+		temperature := float32(0.2)
+		req := &SavePersonaSvcRequest{
+			AgentCID: "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+			Persona: prompt.Persona{
+				Template:    "{{.Knowledge}}",
+				Tone:        prompt.ToneFormal,
+				Temperature: &temperature,
+				Tools:       []string{"calculator", "search_knowledge"},
+			},
+			Signature: authz.Signature{Timestamp: now.Unix()},
+		}
+		auth := personaRequest(req)
+		if err := auth.Sign(creator); err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		req.Signature = auth.Signature
+		return req
+	}
+
+	if err := personaRequest(signed()).Verify(address, now); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		change func(*SavePersonaSvcRequest)
+	}{
+		{"other agent", func(r *SavePersonaSvcRequest) {
+			r.AgentCID = "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+		}},
+		{"other template", func(r *SavePersonaSvcRequest) { r.Persona.Template = "{{.Knowledge}} forged" }},
+		{"other temperature", func(r *SavePersonaSvcRequest) { r.Persona.Temperature = nil }},
+		{"other model", func(r *SavePersonaSvcRequest) { r.Persona.Model = "gpt-4o" }},
+		{"extra tool", func(r *SavePersonaSvcRequest) { r.Persona.Tools = append(r.Persona.Tools, "http_get") }},
+		{"response schema", func(r *SavePersonaSvcRequest) { r.Persona.ResponseSchema = prompt.AnswerSchema }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := signed()
+			tt.change(req)
+			if err := personaRequest(req).Verify(address, now); !errors.Is(err, authz.ErrUnauthorized) {
+				t.Errorf("Verify() error = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
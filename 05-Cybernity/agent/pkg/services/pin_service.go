@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"cybernity/internal/config"
+	"cybernity/pkg/core/logger"
+	"cybernity/pkg/core/storage"
+	"cybernity/pkg/models"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type pinService struct{}
+
+var (
+	PinService     *pinService
+	pinServiceOnce sync.Once
+)
+
+func NewPinService() *pinService {
+	pinServiceOnce.Do(func() {
+		PinService = &pinService{}
+	})
+	return PinService
+}
+
+type PinSvcRequest struct {
+	Content        []byte
+	Files          []storage.File // published as a directory instead of Content when set
+	Name           string
+	Purpose        string
+	AgentCID       string // empty for knowledge, whose own CID is the agent CID
+	CreatorAddress string
+}
+
+// Pin uploads content and records the pin against its owning agent and creator.
+// Content whose pin can't be recorded is unpinned again.
+func (s *pinService) Pin(ctx context.Context, req *PinSvcRequest) (string, error) {
+	var cid string
+	var err error
+	size := int64(len(req.Content))
+	if len(req.Files) > 0 {
+		cid, err = NewIpfsService().UploadDirectory(ctx, req.Files)
+		size = 0
+		for _, f := range req.Files {
+			size += int64(len(f.Content))
+		}
+	} else {
+		cid, err = NewIpfsService().UploadFileRaw(ctx, req.Content, req.Name)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	agentCID := req.AgentCID
+	if agentCID == "" {
+		agentCID = cid
+	}
+	pin := &models.Pins{
+		CID:            cid,
+		Name:           req.Name,
+		Purpose:        req.Purpose,
+		AgentCID:       agentCID,
+		CreatorAddress: req.CreatorAddress,
+		Size:           size,
+		Backend:        config.AppConfig.Storage.Backend,
+	}
+	if err := pin.Create(ctx); err != nil {
+		err = fmt.Errorf("failed to record pin %s: %w", cid, err)
+		// Untracked content would never be unpinned, don't leave it on the backend
+		if deleteErr := NewIpfsService().DeleteFile(ctx, cid); deleteErr != nil && !errors.Is(deleteErr, storage.ErrNotFound) {
+			err = errors.Join(err, fmt.Errorf("failed to unpin %s: %w", cid, deleteErr))
+		}
+		return "", err
+	}
+	return cid, nil
+}
+
+// Unpin removes the content from the storage backend and marks the pin as unpinned
+func (s *pinService) Unpin(ctx context.Context, cid string) error {
+	if err := NewIpfsService().DeleteFile(ctx, cid); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("failed to unpin %s: %w", cid, err)
+	}
+	NewAgentService().InvalidateKnowledge(cid)
+	if err := (&models.KnowledgeIndexes{}).DeleteByKnowledgeCID(ctx, cid); err != nil {
+		return fmt.Errorf("failed to delete the index of %s: %w", cid, err)
+	}
+	return (&models.Pins{}).MarkUnpinned(ctx, cid)
+}
+
+// Supersede marks content as replaced by a newer version, so the superseded policy can unpin it
+func (s *pinService) Supersede(ctx context.Context, cid string) error {
+	return (&models.Pins{}).MarkSuperseded(ctx, cid)
+}
+
+func (s *pinService) ListPins(ctx context.Context, creatorAddress string) ([]*models.Pins, error) {
+	return (&models.Pins{}).ListByCreator(ctx, creatorAddress)
+}
+
+func (s *pinService) Usage(ctx context.Context, creatorAddress string) ([]*models.PinUsage, error) {
+	return (&models.Pins{}).UsageByCreator(ctx, creatorAddress)
+}
+
+// ApplyPolicies unpins everything the policies no longer require and returns how many pins were removed
+func (s *pinService) ApplyPolicies(ctx context.Context, cfg *storage.PinPolicyConfig) (int, error) {
+	var candidates []*models.Pins
+	now := time.Now()
+
+	if cfg.OffChainAgentTTL > 0 {
+		pins, err := (&models.Pins{}).ListOffChainBefore(ctx, now.Add(-cfg.OffChainAgentTTL))
+		if err != nil {
+			return 0, fmt.Errorf("failed to list off-chain pins: %w", err)
+		}
+		candidates = append(candidates, pins...)
+	}
+	if cfg.SupersededTTL > 0 {
+		pins, err := (&models.Pins{}).ListSupersededBefore(ctx, now.Add(-cfg.SupersededTTL))
+		if err != nil {
+			return 0, fmt.Errorf("failed to list superseded pins: %w", err)
+		}
+		candidates = append(candidates, pins...)
+	}
+
+	unpinned := 0
+	seen := make(map[string]bool)
+	var errs []error
+	for _, pin := range candidates {
+		if seen[pin.CID] {
+			continue
+		}
+		seen[pin.CID] = true
+		if err := s.Unpin(ctx, pin.CID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		unpinned++
+	}
+	return unpinned, errors.Join(errs...)
+}
+
+// RunPolicies applies the pin policies every interval until the context is done
+func (s *pinService) RunPolicies(ctx context.Context, cfg *storage.PinPolicyConfig) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		unpinned, err := s.ApplyPolicies(ctx, cfg)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to apply pin policies: %v", err)
+		}
+		if unpinned > 0 {
+			logger.Infof(ctx, "Unpinned %d pins", unpinned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
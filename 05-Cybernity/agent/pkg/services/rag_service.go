@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"cybernity/pkg/core/cache"
+	"cybernity/pkg/core/logger"
+	"cybernity/pkg/core/rag"
+	"cybernity/pkg/models"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type ragService struct {
+	// queue holds the CIDs of the agents waiting to be indexed by RunIndexer
+	queue chan string
+}
+
+var (
+	RagService     *ragService
+	ragServiceOnce sync.Once
+)
+
+// ErrIndexQueueFull is returned when more agents wait to be indexed than the queue holds
+var ErrIndexQueueFull = errors.New("index queue is full")
+
+func NewRagService() *ragService {
+	ragServiceOnce.Do(func() {
+		RagService = &ragService{queue: make(chan string, rag.GetConfig().IndexQueueSize)}
+	})
+	return RagService
+}
+
+// IndexAgent chunks and embeds the agent's current knowledge, storing the index encrypted to the agent
+func (s *ragService) IndexAgent(ctx context.Context, agent *models.Agents) (*rag.Index, error) {
+	docs, err := NewAgentService().GetTexts(ctx, agent)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := rag.GetConfig()
+	embedder, err := s.embedder(ctx, agent)
+	if err != nil {
+		return nil, err
+	}
+	index, err := rag.Build(ctx, embedder, rag.Split(docs, cfg.ChunkSize, cfg.ChunkOverlap))
+	if err != nil {
+		return nil, err
+	}
+	data, err := index.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	walletService := NewWalletService()
+	scheme, publicKey, err := walletService.GetPublicKeyForAgent(ctx, agent.AgentAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent public key: %w", err)
+	}
+	sealed, err := NewEncryptService().EncryptWithScheme(scheme, data, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt index: %w", err)
+	}
+	record := &models.KnowledgeIndexes{
+		AgentCID:     agent.CID,
+		KnowledgeCID: agent.ContentCID(),
+		Embedder:     index.Embedder,
+		Chunks:       len(index.Chunks),
+		Sealed:       sealed,
+	}
+	if err := record.Save(ctx); err != nil {
+		return nil, fmt.Errorf("failed to save index: %w", err)
+	}
+
+	wallet, err := walletService.GetWalletForAgent(ctx, agent.AgentAddress)
+	if err != nil {
+		return nil, err
+	}
+	version, err := indexVersion(wallet, agent, embedder)
+	if err != nil {
+		return nil, err
+	}
+	cache.GetCache().Set(indexCacheKey(agent.ContentCID()), version, data)
+	return index, nil
+}
+
+// Retrieve returns the chunks of the agent's knowledge most relevant to the question,
+// indexing the knowledge first when it has no index yet
+func (s *ragService) Retrieve(ctx context.Context, agent *models.Agents, question string) ([]rag.Result, error) {
+	embedder, err := s.embedder(ctx, agent)
+	if err != nil {
+		return nil, err
+	}
+	index, err := s.getIndex(ctx, agent, embedder)
+	if err != nil {
+		return nil, err
+	}
+	cfg := rag.GetConfig()
+	return index.Retrieve(ctx, embedder, question, cfg.TopK, cfg.MinScore)
+}
+
+// embedder returns the configured embedder, or the embedder of the route the agent is pinned to
+// so its knowledge is only ever sent there
+func (s *ragService) embedder(ctx context.Context, agent *models.Agents) (rag.Embedder, error) {
+	persona, _, err := NewPersonaService().Get(ctx, agent.CID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get persona: %w", err)
+	}
+	if persona.Route != "" {
+		return rag.GetRouteEmbedder(persona.Route)
+	}
+	return rag.GetEmbedder()
+}
+
+func (s *ragService) getIndex(ctx context.Context, agent *models.Agents, embedder rag.Embedder) (*rag.Index, error) {
+	walletService := NewWalletService()
+	wallet, err := walletService.GetWalletForAgent(ctx, agent.AgentAddress)
+	if err != nil {
+		return nil, err
+	}
+	key := indexCacheKey(agent.ContentCID())
+	version, err := indexVersion(wallet, agent, embedder)
+	if err != nil {
+		return nil, err
+	}
+
+	indexCache := cache.GetCache()
+	if data, ok := indexCache.Get(key, version); ok {
+		return rag.Unmarshal(data)
+	}
+
+	record, err := (&models.KnowledgeIndexes{}).Get(ctx, agent.ContentCID(), embedder.Name())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.IndexAgent(ctx, agent)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index: %w", err)
+	}
+	data, err := walletService.DecryptForAgent(ctx, agent.AgentAddress, record.Sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt index: %w", err)
+	}
+	index, err := rag.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	indexCache.Set(key, version, data)
+	return index, nil
+}
+
+func indexCacheKey(knowledgeCID string) string {
+	return "index:" + knowledgeCID
+}
+
+// indexVersion versions a cached index by the agent's keys, like the knowledge it was built from, and the embedder
+func indexVersion(wallet *models.Wallet, agent *models.Agents, embedder rag.Embedder) (string, error) {
+	version, err := knowledgeVersion(wallet, agent)
+	if err != nil {
+		return "", err
+	}
+	return version + ":" + embedder.Name(), nil
+}
+
+// IndexInBackground queues the latest knowledge of an agent to be indexed by RunIndexer without
+// holding up the upload, answering indexes it on demand if it's dropped or fails
+func (s *ragService) IndexInBackground(agentCID string) error {
+	select {
+	case s.queue <- agentCID:
+		return nil
+	default:
+		return ErrIndexQueueFull
+	}
+}
+
+// RunIndexer indexes the queued agents with the given number of workers until the context is done,
+// returning once the workers have stopped
+func (s *ragService) RunIndexer(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case agentCID := <-s.queue:
+					if err := s.indexQueued(ctx, agentCID); err != nil && ctx.Err() == nil {
+						logger.Errorf(ctx, "Failed to index the knowledge of agent %s: %v", agentCID, err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *ragService) indexQueued(ctx context.Context, agentCID string) error {
+	agent, err := NewAgentService().GetAgent(ctx, agentCID)
+	if err != nil {
+		return err
+	}
+	_, err = s.IndexAgent(ctx, agent)
+	return err
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIndexQueue(t *testing.T) {
+	s := &ragService{queue: make(chan string, 1)}
+	if err := s.IndexInBackground("first"); err != nil {
+		t.Fatalf("IndexInBackground() error = %v", err)
+	}
+	if err := s.IndexInBackground("second"); !errors.Is(err, ErrIndexQueueFull) {
+		t.Fatalf("IndexInBackground() on a full queue error = %v, want %v", err, ErrIndexQueueFull)
+	}
+
+	<-s.queue
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		s.RunIndexer(ctx, 2)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunIndexer() didn't return after its context was done")
+	}
+}
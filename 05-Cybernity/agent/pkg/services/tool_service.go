@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"cybernity/pkg/core/llm"
+	"cybernity/pkg/core/tool"
+	"cybernity/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type toolService struct{}
+
+var (
+	ToolService     *toolService
+	toolServiceOnce sync.Once
+)
+
+func NewToolService() *toolService {
+	toolServiceOnce.Do(func() {
+		ToolService = &toolService{}
+	})
+	return ToolService
+}
+
+// ForAgent returns the built-in tools the agent's persona allows, leaving out those no longer enabled
+func (s *toolService) ForAgent(agent *models.Agents, names []string) tool.Set {
+	cfg := tool.GetConfig()
+	var tools []tool.Tool
+	for _, name := range names {
+		if !cfg.IsEnabled(name) {
+			continue
+		}
+		switch name {
+		case tool.Calculator:
+			tools = append(tools, tool.NewCalculator())
+		case tool.KnowledgeSearch:
+			tools = append(tools, s.knowledgeSearch(agent))
+		case tool.AgentLookup:
+			tools = append(tools, s.agentLookup())
+		}
+	}
+	return tool.NewSet(tools...)
+}
+
+// knowledgeSearch searches the agent's own knowledge, e.g. for a part of the question the prompt's chunks miss
+func (s *toolService) knowledgeSearch(agent *models.Agents) tool.Tool {
+	return &tool.Func{
+		Tool: llm.NewFunctionTool(tool.KnowledgeSearch,
+			"Searches the knowledge base for the passages most relevant to a query.",
+			map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{"type": "string"},
+				},
+				"required": []string{"query"},
+			}),
+		Fn: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Query string `json:"query"`
+			}
+			if err := tool.DecodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			if args.Query == "" {
+				return "", errors.New("query is required")
+			}
+			chunks, err := NewRagService().Retrieve(ctx, agent, args.Query)
+			if err != nil {
+				return "", err
+			}
+			return formatChunks(chunks), nil
+		},
+	}
+}
+
+// agentLookup looks up another agent by CID, along with its operator registered on-chain
+func (s *toolService) agentLookup() tool.Tool {
+	return &tool.Func{
+		Tool: llm.NewFunctionTool(tool.AgentLookup,
+			"Looks up a knowledge agent by its CID: name, description, creator and on-chain operator.",
+			map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"cid": map[string]any{"type": "string"},
+				},
+				"required": []string{"cid"},
+			}),
+		Fn: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				CID string `json:"cid"`
+			}
+			if err := tool.DecodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			agent, err := NewAgentService().GetAgent(ctx, args.CID)
+			if err != nil {
+				return "", fmt.Errorf("agent %s not found", args.CID)
+			}
+			result := map[string]any{
+				"cid":             agent.CID,
+				"name":            agent.Name,
+				"description":     agent.Description,
+				"creator_address": agent.CreatorAddress,
+				"agent_address":   agent.AgentAddress,
+			}
+			// The server starts the eth service before answering, without it the operator can't be looked up
+			if EthService == nil {
+				return "", fmt.Errorf("failed to look up the agent on-chain: eth service is not initialized")
+			}
+			operator, err := EthService.GetAgentOperator(ctx, agent.CID)
+			if err != nil {
+				return "", fmt.Errorf("failed to look up the agent on-chain: %w", err)
+			}
+			result["operator_address"] = operator.Hex()
+			data, err := json.Marshal(result)
+			return string(data), err
+		},
+	}
+}
+
+// ToolStep is a tool call made while answering
+type ToolStep struct {
+	Iteration int           `json:"iteration"`
+	CallID    string        `json:"call_id"`
+	Tool      string        `json:"tool"`
+	Arguments string        `json:"arguments"`
+	Result    string        `json:"result,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// runTools completes the chat, calling the tools the model asks for and sending back their results,
+// until the model answers. After cfg.MaxIterations rounds of calls the model is asked once more to answer,
+// without tools, and it fails when the model still doesn't. onStep is called after each tool call.
+func runTools(ctx context.Context, client llm.Client, request llm.ChatCompletionRequest, tools tool.Set,
+	budget *llm.Budget, cfg *tool.Config, onStep func(ToolStep)) (*llm.ChatCompletionResponse, error) {
+	request.Tools = tools.Definitions()
+	definitions, _ := json.Marshal(request.Tools)
+	toolTokens := budget.Count(string(definitions))
+
+	var usage llm.Usage
+	for iteration := 0; ; iteration++ {
+		final := iteration >= cfg.MaxIterations
+		request.ToolChoice = llm.ToolChoiceAuto
+		if final {
+			// Models may keep calling tools they are offered whatever the tool choice, so they aren't
+			request.Tools, request.ToolChoice, toolTokens = nil, "", 0
+			request.Messages = append(request.Messages, llm.Message{Role: "user", Content: "工具调用次数已达上限。请根据以上工具结果直接回答问题，不要再调用工具。"})
+		}
+		maxTokens, err := budget.MaxTokens(budget.CountMessages(request.Messages) + toolTokens)
+		if err != nil {
+			return nil, fmt.Errorf("tool results leave no room for the answer: %w", err)
+		}
+		request.MaxTokens = maxTokens
+
+		response, err := client.ChatCompletion(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("chat completion failed: %w", err)
+		}
+		if len(response.Choices) == 0 {
+			return nil, fmt.Errorf("no response from AI")
+		}
+		usage.PromptTokens += response.Usage.PromptTokens
+		usage.CompletionTokens += response.Usage.CompletionTokens
+		usage.TotalTokens += response.Usage.TotalTokens
+
+		message := response.Choices[0].Message
+		if final && strings.TrimSpace(message.Content) == "" {
+			return nil, fmt.Errorf("no answer after %d rounds of tool calls", cfg.MaxIterations)
+		}
+		if final || len(message.ToolCalls) == 0 {
+			response.Usage = usage
+			return response, nil
+		}
+
+		request.Messages = append(request.Messages, llm.Message{
+			Role:      "assistant",
+			Content:   message.Content,
+			ToolCalls: message.ToolCalls,
+		})
+		for _, call := range message.ToolCalls {
+			step := callTool(ctx, tools, call, cfg)
+			step.Iteration = iteration
+			onStep(step)
+
+			content := step.Result
+			if step.Error != "" {
+				content = "error: " + step.Error
+			}
+			request.Messages = append(request.Messages, llm.Message{Role: "tool", ToolCallID: call.ID, Content: content})
+		}
+	}
+}
+
+// callTool runs a call of the model within the tool timeout, the model can only call the tools it was given
+func callTool(ctx context.Context, tools tool.Set, call llm.ToolCall, cfg *tool.Config) ToolStep {
+	step := ToolStep{CallID: call.ID, Tool: call.Function.Name, Arguments: call.Function.Arguments}
+	t, ok := tools[call.Function.Name]
+	if !ok {
+		step.Error = fmt.Sprintf("tool %s is not available", call.Function.Name)
+		return step
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	start := time.Now()
+	result, err := t.Call(ctx, call.Function.Arguments)
+	step.Duration = time.Since(start)
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	step.Result = truncateRunes(result, cfg.MaxResultLength)
+	return step
+}
+
+// truncateRunes cuts s to at most n runes
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n]) + "…"
+}
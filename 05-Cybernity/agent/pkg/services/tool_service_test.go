@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"cybernity/pkg/core/llm"
+	"cybernity/pkg/core/tool"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedClient answers chat completions with its responses in turn, recording the requests
+type scriptedClient struct {
+	llm.Client
+	responses []llm.Message
+	requests  []llm.ChatCompletionRequest
+}
+
+func (c *scriptedClient) ChatCompletion(ctx context.Context, request llm.ChatCompletionRequest) (*llm.ChatCompletionResponse, error) {
+	request.Messages = append([]llm.Message(nil), request.Messages...)
+	c.requests = append(c.requests, request)
+	message := c.responses[min(len(c.requests), len(c.responses))-1]
+	return &llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: message}},
+		Usage:   llm.Usage{PromptTokens: 10, CompletionTokens: 1, TotalTokens: 11},
+	}, nil
+}
+
+func toolCall(id, name, arguments string) llm.ToolCall {
+	return llm.ToolCall{ID: id, Type: "function", Function: llm.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+func testBudget() *llm.Budget {
+	return &llm.Budget{
+		ModelLimits:     llm.ModelLimits{ContextLength: 8192, MaxOutputTokens: 512},
+		MinOutputTokens: 100,
+		Tokenizer:       llm.EstimateTokenizer{},
+	}
+}
+
+func TestRunTools(t *testing.T) {
+	client := &scriptedClient{responses: []llm.Message{
+		{Role: "assistant", ToolCalls: []llm.ToolCall{
+			toolCall("call_1", tool.Calculator, `{"expression":"6*7"}`),
+			toolCall("call_2", "shell", `{"command":"ls"}`),
+		}},
+		{Role: "assistant", Content: "42"},
+	}}
+	request := llm.ChatCompletionRequest{Messages: []llm.Message{{Role: "user", Content: "6*7?"}}}
+	cfg := (&tool.Config{}).MergeDefault()
+
+	var trace []ToolStep
+	response, err := runTools(context.Background(), client, request, tool.NewSet(tool.NewCalculator()), testBudget(), cfg,
+		func(step ToolStep) { trace = append(trace, step) })
+	if err != nil {
+		t.Fatalf("runTools() error = %v", err)
+	}
+	if response.Choices[0].Message.Content != "42" || response.Usage.TotalTokens != 22 {
+		t.Errorf("runTools() = %+v, want the answer with the usage of both calls", response)
+	}
+
+	if len(client.requests) != 2 {
+		t.Fatalf("runTools() sent %d requests, want 2", len(client.requests))
+	}
+	first := client.requests[0]
+	if len(first.Tools) != 1 || first.Tools[0].Function.Name != tool.Calculator || first.ToolChoice != llm.ToolChoiceAuto || first.MaxTokens == 0 {
+		t.Errorf("first request = %+v, want the calculator offered", first)
+	}
+	messages := client.requests[1].Messages
+	if len(messages) != 4 || len(messages[1].ToolCalls) != 2 {
+		t.Fatalf("second request messages = %+v, want the calls and their results", messages)
+	}
+	if m := messages[2]; m.Role != "tool" || m.ToolCallID != "call_1" || m.Content != "42" {
+		t.Errorf("calculator result = %+v", messages[2])
+	}
+	if messages[3].ToolCallID != "call_2" || !strings.Contains(messages[3].Content, "error: tool shell is not available") {
+		t.Errorf("shell result = %+v, want the tool refused", messages[3])
+	}
+
+	if len(trace) != 2 || trace[0].Tool != tool.Calculator || trace[0].Result != "42" || trace[1].Error == "" {
+		t.Errorf("trace = %+v", trace)
+	}
+}
+
+func TestRunToolsIterationLimit(t *testing.T) {
+	call := llm.Message{Role: "assistant", ToolCalls: []llm.ToolCall{toolCall("call_1", tool.Calculator, `{"expression":"1+1"}`)}}
+	request := llm.ChatCompletionRequest{Messages: []llm.Message{{Role: "user", Content: "loop"}}}
+	cfg := (&tool.Config{MaxIterations: 2}).MergeDefault()
+
+	client := &scriptedClient{responses: []llm.Message{call, call, {Role: "assistant", Content: "2"}}}
+	steps := 0
+	response, err := runTools(context.Background(), client, request, tool.NewSet(tool.NewCalculator()), testBudget(), cfg,
+		func(ToolStep) { steps++ })
+	if err != nil {
+		t.Fatalf("runTools() error = %v", err)
+	}
+	if len(client.requests) != 3 || steps != 2 {
+		t.Fatalf("runTools() sent %d requests and called %d tools, want 3 and 2", len(client.requests), steps)
+	}
+	last := client.requests[2]
+	if len(last.Tools) != 0 || last.ToolChoice != "" || last.Messages[len(last.Messages)-1].Role != "user" {
+		t.Errorf("last request = %+v, want no tools and an instruction to answer", last)
+	}
+	if response.Choices[0].Message.Content != "2" || response.Usage.TotalTokens != 33 {
+		t.Errorf("runTools() = %+v, want the answer with the usage of every call", response)
+	}
+
+	// A model that still doesn't answer fails rather than publishing an empty answer
+	client = &scriptedClient{responses: []llm.Message{call}}
+	if _, err := runTools(context.Background(), client, request, tool.NewSet(tool.NewCalculator()), testBudget(), cfg,
+		func(ToolStep) {}); err == nil {
+		t.Error("runTools() returned tool calls without an answer")
+	}
+}
+
+func TestCallTool(t *testing.T) {
+	slow := &tool.Func{
+		Tool: llm.NewFunctionTool("slow", "", nil),
+		Fn: func(ctx context.Context, arguments string) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+	long := &tool.Func{
+		Tool: llm.NewFunctionTool("long", "", nil),
+		Fn: func(ctx context.Context, arguments string) (string, error) {
+			return strings.Repeat("知", 20), nil
+		},
+	}
+	tools := tool.NewSet(slow, long)
+	cfg := &tool.Config{Timeout: 10 * time.Millisecond, MaxResultLength: 5}
+
+	if step := callTool(context.Background(), tools, toolCall("1", "slow", "{}"), cfg); !strings.Contains(step.Error, "deadline") {
+		t.Errorf("callTool(slow) = %+v, want the timeout", step)
+	}
+	if step := callTool(context.Background(), tools, toolCall("2", "long", "{}"), cfg); step.Result != "知知知知知…" {
+		t.Errorf("callTool(long) result = %q, want it cut to 5 characters", step.Result)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rsa"
 	"cybernity/pkg/models"
+	"fmt"
 	"sync"
 )
 
@@ -22,14 +23,12 @@ func NewWalletService() *walletService {
 }
 
 func (s *walletService) GetPrivateKeyForAgent(ctx context.Context, agentAddress string) (*rsa.PrivateKey, error) {
-	wallet, err := (&models.Wallet{}).GetWalletByAgentAddress(ctx, agentAddress)
+	keys, err := s.GetKeysForAgent(ctx, agentAddress)
 	if err != nil {
 		return nil, err
 	}
-
-	keys, err := models.AgentKeysFromJSON(wallet.AgentPrivateKey)
-	if err != nil {
-		return nil, err
+	if keys.Scheme() != models.EncryptionSchemeRSAOAEP {
+		return nil, fmt.Errorf("agent uses %s, not an RSA key", keys.Scheme())
 	}
 
 	encryptSvc := NewEncryptService()
@@ -54,3 +53,40 @@ func (s *walletService) GetBlockchainPrivateKeyForAgent(ctx context.Context, age
 
 	return keys.BlockchainPrivateKey, nil
 }
+
+func (s *walletService) GetWalletForAgent(ctx context.Context, agentAddress string) (*models.Wallet, error) {
+	return (&models.Wallet{}).GetWalletByAgentAddress(ctx, agentAddress)
+}
+
+func (s *walletService) GetKeysForAgent(ctx context.Context, agentAddress string) (*models.AgentKeys, error) {
+	wallet, err := (&models.Wallet{}).GetWalletByAgentAddress(ctx, agentAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.AgentKeysFromJSON(wallet.AgentPrivateKey)
+}
+
+// DecryptForAgent decrypts data encrypted to the agent, whichever scheme the agent uses
+func (s *walletService) DecryptForAgent(ctx context.Context, agentAddress string, ciphertext []byte) ([]byte, error) {
+	keys, err := s.GetKeysForAgent(ctx, agentAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEncryptService().DecryptWithAgentKeys(ciphertext, keys)
+}
+
+// GetPublicKeyForAgent returns the agent's encryption scheme and the serialized public key data for it is encrypted to
+func (s *walletService) GetPublicKeyForAgent(ctx context.Context, agentAddress string) (string, []byte, error) {
+	keys, err := s.GetKeysForAgent(ctx, agentAddress)
+	if err != nil {
+		return "", nil, err
+	}
+
+	publicKey, err := NewEncryptService().AgentPublicKey(keys)
+	if err != nil {
+		return "", nil, err
+	}
+	return keys.Scheme(), publicKey, nil
+}